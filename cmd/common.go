@@ -1,12 +1,16 @@
 package cmd
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/mcuadros/ascode/starlark/runtime"
+	"github.com/mcuadros/ascode/starlark/sandbox"
 	"github.com/mcuadros/ascode/terraform"
+	"go.starlark.net/starlark"
 )
 
 func init() {
@@ -14,13 +18,112 @@ func init() {
 }
 
 type commonCmd struct {
-	PluginDir string `long:"plugin-dir" description:"directory containing plugin binaries" default:"$HOME/.terraform.d/plugins"`
+	PluginDir        string   `long:"plugin-dir" description:"directory containing plugin binaries" default:"$HOME/.terraform.d/plugins"`
+	AllowStateWrites bool     `long:"allow-state-writes" description:"allows to persist changes made to the backend states"`
+	AllowExec        bool     `long:"allow-exec" description:"allows the execution of external commands"`
+	AllowNet         bool     `long:"allow-net" description:"allows network access"`
+	AllowEnv         bool     `long:"allow-env" description:"allows to read and write environment variables"`
+	AllowWrite       []string `long:"allow-write" description:"allows to write files inside of the given directory" value-name:"dir"`
+	Vars             []string `long:"var" description:"sets a variable available to the script as vars.<key>" value-name:"key=value"`
+	VarFiles         []string `long:"var-file" description:"loads variables from a JSON, YAML or Starlark file" value-name:"file"`
+	CacheDir         string   `long:"cache-dir" description:"directory where the remote modules are cached"`
+	Offline          bool     `long:"offline" description:"loads the remote modules only from the cache"`
 
+	pm      *terraform.PluginManager
 	runtime *runtime.Runtime
 }
 
-func (c *commonCmd) init() {
-	c.runtime = runtime.NewRuntime(&terraform.PluginManager{
-		Path: os.ExpandEnv(c.PluginDir)},
-	)
+func (c *commonCmd) init() error {
+	c.pm = &terraform.PluginManager{Path: os.ExpandEnv(c.PluginDir)}
+
+	var err error
+	c.runtime, err = c.newRuntime()
+	return err
+}
+
+// newRuntime returns a new runtime.Runtime configured by the flags.
+func (c *commonCmd) newRuntime() (*runtime.Runtime, error) {
+	r := runtime.NewRuntime(c.pm)
+
+	r.AllowStateWrites = c.AllowStateWrites
+	r.Cache.Offline = c.Offline
+	if c.CacheDir != "" {
+		r.Cache.Dir = os.ExpandEnv(c.CacheDir)
+	}
+
+	r.Permissions = sandbox.Permissions{
+		Exec:  c.AllowExec,
+		Net:   c.AllowNet,
+		Env:   c.AllowEnv,
+		Write: c.AllowWrite,
+	}
+
+	for _, filename := range c.VarFiles {
+		if err := r.Vars.LoadFile(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range c.Vars {
+		if err := r.Vars.SetFlag(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// execFiles executes the given files or directories, if an
+// starlark.EvalError happens the backtrace is printed and the process exits.
+func (c *commonCmd) execFiles(filenames ...string) error {
+	err := c.runtime.ExecFiles(filenames...)
+	if err != nil {
+		if err, ok := err.(*starlark.EvalError); ok {
+			fmt.Println(err.Backtrace())
+			os.Exit(1)
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// starlarkFiles returns the given files, and the `.star` files contained by
+// the given directories, recursively. Unlike runtime.ExpandFiles, used by the
+// commands executing the files, where a directory stands for its entrypoint,
+// the commands handling the source, such as fmt, lint or docs, need every
+// file, including the libraries only loaded by others.
+func starlarkFiles(paths []string) ([]string, error) {
+	var filenames []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			filenames = append(filenames, path)
+			continue
+		}
+
+		err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() && filepath.Ext(path) == ".star" {
+				filenames = append(filenames, path)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filenames, nil
 }
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mcuadros/ascode/starlark/docs"
+)
+
+// Command descriptions used in the flags.Parser.AddCommand.
+const (
+	DocsCmdShortDescription = "Docs generates the documentation of Starlark files."
+	DocsCmdLongDescription  = DocsCmdShortDescription + "\n\n" +
+		"The given files, or the `.star` files of the given directories, \n" +
+		"recursively, are documented from the docstring of the module and \n" +
+		"of its public functions, the ones not starting by underscore, \n" +
+		"including the parameters of each function.\n\n" +
+		"The resource and data source types created by every function are \n" +
+		"discovered executing the file and calling the function, using as \n" +
+		"value of every required parameter its own name, eg.: name=\"name\". \n" +
+		"This can be disabled using `--no-resources`.\n\n" +
+		"The documentation is printed as Markdown or HTML, using `--format`, \n" +
+		"to the standard output or to the file given to `--output`.\n"
+)
+
+// DocsCmd implements the command `docs`.
+type DocsCmd struct {
+	commonCmd
+
+	Format         string `long:"format" description:"output format" choice:"markdown" choice:"html" default:"markdown"`
+	Output         string `long:"output" short:"o" description:"writes the documentation to the given file"`
+	NoResources    bool   `long:"no-resources" description:"skips the discovery of the resources created by the functions"`
+	PositionalArgs struct {
+		Files []string `positional-arg-name:"file" description:"starlark source files or directories, by default the current directory"`
+	} `positional-args:"true"`
+}
+
+// Execute honors the flags.Commander interface.
+func (c *DocsCmd) Execute(args []string) error {
+	paths := c.PositionalArgs.Files
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	filenames, err := starlarkFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	var modules []*docs.Module
+	for _, filename := range filenames {
+		m, err := docs.Parse(filename, nil)
+		if err != nil {
+			return err
+		}
+
+		if err := c.discoverResources(m); err != nil {
+			return err
+		}
+
+		modules = append(modules, m)
+	}
+
+	var out []byte
+	if c.Format == "html" {
+		out, err = docs.HTML(modules)
+	} else {
+		out, err = docs.Markdown(modules)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if c.Output == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+
+	return ioutil.WriteFile(c.Output, out, 0644)
+}
+
+// discoverResources executes the file of the given module, in its own
+// runtime, to discover the resources created by its functions. If the file
+// can't be executed a warning is printed, without resources.
+func (c *DocsCmd) discoverResources(m *docs.Module) error {
+	if c.NoResources || len(m.Functions) == 0 {
+		return nil
+	}
+
+	if err := c.init(); err != nil {
+		return err
+	}
+
+	globals, err := c.runtime.ExecFile(m.Filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: unable to discover resources: %s\n", m.Filename, err)
+		return nil
+	}
+
+	m.DiscoverResources(c.runtime.NewThread("docs"), c.runtime.Terraform, globals)
+	return nil
+}
+
+var _ flags.Commander = &DocsCmd{}
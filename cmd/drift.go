@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mcuadros/ascode/starlark/types"
+)
+
+// Command descriptions used in the flags.Parser.AddCommand.
+const (
+	DriftCmdShortDescription = "Drift compares the resources of a Starlark file with the state."
+	DriftCmdLongDescription  = DriftCmdShortDescription + "\n\n" +
+		"Executes the given Starlark file and compares each resource with the\n" +
+		"matching resource instance at the state of the configured backend,\n" +
+		"if no backend is defined the `local` backend is used.\n\n" +
+		"The arguments added, removed or changed are reported, the\n" +
+		"computed-only attributes are ignored. Every instance of the resources\n" +
+		"with count or for_each is compared, the data sources are not.\n"
+)
+
+// DriftCmd implements the command `drift`.
+type DriftCmd struct {
+	commonCmd
+
+	Workspace        string `long:"workspace" description:"backend workspace" default:"default"`
+	DetailedExitCode bool   `long:"detailed-exitcode" description:"returns exit code 2 if drift is detected"`
+	PositionalArgs   struct {
+		Files []string `positional-arg-name:"file" description:"starlark source files or directories"`
+	} `positional-args:"true" required:"1"`
+}
+
+// Execute honors the flags.Commander interface.
+func (c *DriftCmd) Execute(args []string) error {
+	if err := c.init(); err != nil {
+		return err
+	}
+
+	if err := c.execFiles(c.PositionalArgs.Files...); err != nil {
+		return err
+	}
+
+	b := c.runtime.Terraform.Backend()
+	if b == nil {
+		var err error
+		b, err = types.NewBackend(c.pm, "local", nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	s, err := b.State("", c.Workspace)
+	if err != nil {
+		return err
+	}
+
+	if s == nil {
+		return fmt.Errorf("unable to find any state at %s", b)
+	}
+
+	drifts, err := c.runtime.Terraform.Drift(s)
+	if err != nil {
+		return err
+	}
+
+	printDrifts(drifts)
+	if len(drifts) != 0 && c.DetailedExitCode {
+		os.Exit(2)
+	}
+
+	return nil
+}
+
+func printDrifts(drifts []*types.Drift) {
+	if len(drifts) == 0 {
+		fmt.Println("No drift detected.")
+		return
+	}
+
+	for _, d := range drifts {
+		addr := d.Address()
+		if d.Missing {
+			fmt.Printf("+ %s: not found in the state\n", addr)
+			continue
+		}
+
+		fmt.Printf("~ %s:\n", addr)
+		for _, diff := range d.Differences {
+			fmt.Printf("    %s\n", diff)
+		}
+	}
+}
+
+var _ flags.Commander = &DriftCmd{}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mcuadros/ascode/starlark/types"
+	"github.com/mcuadros/ascode/terraform"
+)
+
+// Command descriptions used in the flags.Parser.AddCommand.
+const (
+	ExplainErrorsCmdShortDescription = "Explain-errors translates Terraform diagnostics to Starlark positions."
+	ExplainErrorsCmdLongDescription  = ExplainErrorsCmdShortDescription + "\n\n" +
+		"Reads the diagnostics written by Terraform using the `-json` flag, \n" +
+		"from the given file or the standard input, and prints them with the \n" +
+		"call stack of the Starlark code that generated the failing block.\n\n" +
+		"The source map is generated by the run command using the flag \n" +
+		"`--source-map`. Eg.: terraform validate -json | ascode explain-errors\n\n" +
+		"Returns exit code 1 if any error is found.\n"
+)
+
+// ExplainErrorsCmd implements the command `explain-errors`.
+type ExplainErrorsCmd struct {
+	SourceMap      string `long:"source-map" description:"source map generated by run" default:"ascode.map.json"`
+	PositionalArgs struct {
+		File string `positional-arg-name:"file" description:"terraform json diagnostics, by default read from stdin"`
+	} `positional-args:"true"`
+}
+
+// Execute honors the flags.Commander interface.
+func (c *ExplainErrorsCmd) Execute(args []string) error {
+	m, err := types.ReadSourceMap(c.SourceMap)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if c.PositionalArgs.File != "" {
+		f, err := os.Open(c.PositionalArgs.File)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		r = f
+	}
+
+	diags, err := terraform.ReadDiagnostics(r)
+	if err != nil {
+		return err
+	}
+
+	var failed bool
+	for i, d := range diags {
+		if i != 0 {
+			fmt.Println()
+		}
+
+		fmt.Println(m.Explain(d))
+		failed = failed || d.Severity == "error"
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+var _ flags.Commander = &ExplainErrorsCmd{}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mcuadros/ascode/starlark/analysis"
+	"github.com/mcuadros/ascode/starlark/format"
+	"github.com/pmezard/go-difflib/difflib"
+	"go.starlark.net/syntax"
+)
+
+// Command descriptions used in the flags.Parser.AddCommand.
+const (
+	FmtCmdShortDescription = "Fmt rewrites Starlark files to the canonical format."
+	FmtCmdLongDescription  = FmtCmdShortDescription + "\n\n" +
+		"The given files, or the `.star` files of the given directories, \n" +
+		"recursively, are rewritten using four spaces indentation, spaces \n" +
+		"around the operators and trailing commas when the elements are \n" +
+		"written in several lines. The comments are preserved.\n\n" +
+		"Using `--schema-order` the keyword arguments of the providers and \n" +
+		"resources, resolvable without executing the file, are sorted \n" +
+		"following its schema: required, optional and computed arguments, \n" +
+		"and nested blocks.\n\n" +
+		"Using `--check` the files are not written, and the command returns \n" +
+		"exit code 1 if any file is not formatted. Using `--diff` the \n" +
+		"differences are printed instead of writing the files.\n"
+)
+
+// FmtCmd implements the command `fmt`.
+type FmtCmd struct {
+	commonCmd
+
+	Check          bool `long:"check" description:"checks if the files are formatted without writing them"`
+	Diff           bool `long:"diff" description:"prints the differences of the formatting changes without writing the files"`
+	SchemaOrder    bool `long:"schema-order" description:"sorts the keyword arguments of the resources following its schema"`
+	PositionalArgs struct {
+		Files []string `positional-arg-name:"file" description:"starlark source files or directories, by default the current directory"`
+	} `positional-args:"true"`
+}
+
+// Execute honors the flags.Commander interface.
+func (c *FmtCmd) Execute(args []string) error {
+	if err := c.init(); err != nil {
+		return err
+	}
+
+	paths := c.PositionalArgs.Files
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	filenames, err := starlarkFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	var unformatted bool
+	for _, filename := range filenames {
+		changed, err := c.format(filename)
+		if err != nil {
+			return err
+		}
+
+		unformatted = unformatted || changed
+	}
+
+	if c.Check && unformatted {
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+// format formats the given file, returning true if the file was not
+// formatted.
+func (c *FmtCmd) format(filename string) (bool, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	f, err := syntax.Parse(filename, src, syntax.RetainComments)
+	if err != nil {
+		return false, err
+	}
+
+	opts := &format.Options{}
+	if c.SchemaOrder {
+		opts.KeywordOrder, err = format.SchemaKeywordOrder(f, analysis.NewProviderFunc(c.pm))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	out := format.File(f, opts)
+	if bytes.Equal(src, out) {
+		return false, nil
+	}
+
+	fmt.Println(filename)
+	if c.Diff {
+		if err := printDiff(filename, src, out); err != nil {
+			return false, err
+		}
+	}
+
+	if c.Check || c.Diff {
+		return true, nil
+	}
+
+	return true, ioutil.WriteFile(filename, out, 0644)
+}
+
+func printDiff(filename string, a, b []byte) error {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(a)),
+		B:        difflib.SplitLines(string(b)),
+		FromFile: filename,
+		ToFile:   filename,
+		Context:  3,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(diff)
+	return nil
+}
+
+var _ flags.Commander = &FmtCmd{}
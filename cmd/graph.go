@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// Command descriptions used in the flags.Parser.AddCommand.
+const (
+	GraphCmdShortDescription = "Graph prints the dependency graph of the resources."
+	GraphCmdLongDescription  = GraphCmdShortDescription + "\n\n" +
+		"The given files are executed, as in `run`, and the providers, \n" +
+		"resources and data sources are printed as a graph, where the edges \n" +
+		"are the references between them, the `depends_on` calls and the \n" +
+		"provider of each resource.\n\n" +
+		"The graph can be printed as Graphviz DOT, Mermaid or JSON, using \n" +
+		"`--format`, to the standard output or to the file given to \n" +
+		"`--output`.\n\n" +
+		"The dependency cycles, and the references to resources not defined \n" +
+		"by any provider, are reported as validation errors, with the call \n" +
+		"stacks of the resources involved, returning exit code 1.\n"
+)
+
+// GraphCmd implements the command `graph`.
+type GraphCmd struct {
+	commonCmd
+
+	Format         string `long:"format" description:"output format" choice:"dot" choice:"mermaid" choice:"json" default:"dot"`
+	Output         string `long:"output" short:"o" description:"writes the graph to the given file"`
+	PositionalArgs struct {
+		Files []string `positional-arg-name:"file" description:"starlark source files or directories"`
+	} `positional-args:"true" required:"1"`
+}
+
+// Execute honors the flags.Commander interface.
+func (c *GraphCmd) Execute(args []string) error {
+	if err := c.init(); err != nil {
+		return err
+	}
+
+	if err := c.execFiles(c.PositionalArgs.Files...); err != nil {
+		return err
+	}
+
+	g := c.runtime.Terraform.Graph()
+
+	var out []byte
+	switch c.Format {
+	case "mermaid":
+		out = []byte(g.Mermaid())
+	case "json":
+		var err error
+		out, err = g.JSON()
+		if err != nil {
+			return err
+		}
+
+		out = append(out, '\n')
+	default:
+		out = []byte(g.DOT())
+	}
+
+	if c.Output == "" {
+		os.Stdout.Write(out)
+	} else if err := ioutil.WriteFile(c.Output, out, 0644); err != nil {
+		return err
+	}
+
+	errs := g.Validate()
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err.Backtrace())
+	}
+
+	if len(errs) != 0 {
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+var _ flags.Commander = &GraphCmd{}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mcuadros/ascode/starlark/analysis"
+	"github.com/mcuadros/ascode/starlark/lint"
+	"github.com/mcuadros/ascode/terraform"
+)
+
+// Command descriptions used in the flags.Parser.AddCommand.
+const (
+	LintCmdShortDescription = "Lint reports the issues of Starlark files without executing them."
+	LintCmdLongDescription  = LintCmdShortDescription + "\n\n" +
+		"The given files, or the `.star` files of the given directories, \n" +
+		"recursively, are checked against the schemas of the providers, \n" +
+		"reporting every issue found with its rule ID:\n\n" +
+		"  unknown-resource-type  resource or data source type not defined by the provider\n" +
+		"  unknown-argument       argument or nested block not defined by the schema\n" +
+		"  computed-argument      computed only argument being set\n" +
+		"  unused-load            symbol loaded but never used\n\n" +
+		"An issue is suppressed by a `# lint:ignore <rule>` comment at the \n" +
+		"end of the line or in the previous line, several rules can be \n" +
+		"given separated by commas, without rules all of them are ignored.\n\n" +
+		"Returns exit code 1 if any issue is found.\n"
+)
+
+// LintCmd implements the command `lint`.
+type LintCmd struct {
+	PluginDir      string `long:"plugin-dir" description:"directory containing plugin binaries" default:"$HOME/.terraform.d/plugins"`
+	Format         string `long:"format" description:"output format" choice:"text" choice:"json" default:"text"`
+	PositionalArgs struct {
+		Files []string `positional-arg-name:"file" description:"starlark source files or directories, by default the current directory"`
+	} `positional-args:"true"`
+}
+
+// Execute honors the flags.Commander interface.
+func (c *LintCmd) Execute(args []string) error {
+	paths := c.PositionalArgs.Files
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	filenames, err := starlarkFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	pm := &terraform.PluginManager{Path: os.ExpandEnv(c.PluginDir)}
+	l := lint.NewLinter(analysis.NewProviderFunc(pm))
+
+	issues := make([]*lint.Issue, 0)
+	for _, filename := range filenames {
+		found, err := l.File(filename, nil)
+		if err != nil {
+			return err
+		}
+
+		issues = append(issues, found...)
+	}
+
+	if err := c.print(issues); err != nil {
+		return err
+	}
+
+	if len(issues) != 0 {
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func (c *LintCmd) print(issues []*lint.Issue) error {
+	if c.Format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(issues)
+	}
+
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+
+	return nil
+}
+
+var _ flags.Commander = &LintCmd{}
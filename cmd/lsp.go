@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mcuadros/ascode/starlark/lsp"
+)
+
+// Command descriptions used in the flags.Parser.AddCommand.
+const (
+	LSPCmdShortDescription = "LSP runs a language server for Starlark files."
+	LSPCmdLongDescription  = LSPCmdShortDescription + "\n\n" +
+		"Implements the Language Server Protocol, communicating through the \n" +
+		"standard input and output, providing completion of providers, \n" +
+		"resources, arguments and nested blocks, hover documentation from \n" +
+		"the provider schemas, diagnostics and go to definition across load \n" +
+		"statements.\n\n" +
+		"The documents are executed on every change, using the same flags \n" +
+		"as the run command, by default without any permission.\n"
+)
+
+// LSPCmd implements the command `lsp`.
+type LSPCmd struct {
+	commonCmd
+}
+
+// Execute honors the flags.Commander interface.
+func (c *LSPCmd) Execute(args []string) error {
+	if err := c.init(); err != nil {
+		return err
+	}
+
+	return lsp.NewServer(c.newRuntime).Serve(os.Stdin, os.Stdout)
+}
+
+var _ flags.Commander = &LSPCmd{}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mcuadros/ascode/starlark/runtime"
+)
+
+// Command descriptions used in the flags.Parser.AddCommand.
+const (
+	ModCmdShortDescription = "Mod manages the packages declared at the ascode.mod manifest."
+	ModCmdLongDescription  = ModCmdShortDescription + "\n\n" +
+		"The ascode.mod manifest declares the packages, shared Starlark\n" +
+		"libraries, available to be loaded using `load(\"@<name>//<path>\")`.\n" +
+		"The git packages are resolved to a commit and pinned at the\n" +
+		"ascode.lock file.\n"
+
+	ModDownloadCmdShortDescription = "Download resolves and fetches the packages."
+	ModDownloadCmdLongDescription  = ModDownloadCmdShortDescription + "\n\n" +
+		"Resolves the git packages not pinned at ascode.lock, or the ones not\n" +
+		"satisfying the manifest, and fetches them to the cache. Using\n" +
+		"`--vendor` the packages are copied to the vendor directory.\n"
+
+	ModTidyCmdShortDescription = "Tidy removes the packages not used."
+	ModTidyCmdLongDescription  = ModTidyCmdShortDescription + "\n\n" +
+		"Removes from the manifest the packages not loaded by any Starlark\n" +
+		"file of the directory, fails if any package is loaded but not\n" +
+		"declared, and downloads the remaining packages.\n"
+)
+
+// ModCmd implements the command `mod`, containing the subcommands
+// `download` and `tidy`.
+type ModCmd struct{}
+
+type modCmd struct {
+	commonCmd
+
+	PositionalArgs struct {
+		Dir string `positional-arg-name:"dir" description:"directory containing the ascode.mod"`
+	} `positional-args:"true"`
+}
+
+func (c *modCmd) packages() (*runtime.Packages, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+
+	dir := c.PositionalArgs.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	return runtime.NewPackages(dir, c.runtime.Cache)
+}
+
+// ModDownloadCmd implements the command `mod download`.
+type ModDownloadCmd struct {
+	modCmd
+
+	Vendor bool `long:"vendor" description:"copies the packages to the vendor directory"`
+}
+
+// Execute honors the flags.Commander interface.
+func (c *ModDownloadCmd) Execute(args []string) error {
+	p, err := c.packages()
+	if err != nil {
+		return err
+	}
+
+	return p.Download(c.Vendor)
+}
+
+// ModTidyCmd implements the command `mod tidy`.
+type ModTidyCmd struct {
+	modCmd
+}
+
+// Execute honors the flags.Commander interface.
+func (c *ModTidyCmd) Execute(args []string) error {
+	p, err := c.packages()
+	if err != nil {
+		return err
+	}
+
+	removed, err := p.Tidy()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range removed {
+		fmt.Printf("removed unused package %q\n", name)
+	}
+
+	return p.Download(false)
+}
+
+var _ flags.Commander = &ModDownloadCmd{}
+var _ flags.Commander = &ModTidyCmd{}
@@ -1,26 +1,42 @@
 package cmd
 
-import "github.com/jessevdk/go-flags"
+import (
+	"os"
+
+	"github.com/jessevdk/go-flags"
+)
 
 // Command descriptions used in the flags.Parser.AddCommand.
 const (
 	REPLCmdShortDescription = "Run as interactive shell."
 	REPLCmdLongDescription  = REPLCmdShortDescription + "\n\n" +
 		"The REPL shell provides the same capabilities as the regular `run`\n" +
-		"command."
+		"command, with tab completion of the values defined, including the\n" +
+		"providers, resources and its arguments, and persistent history.\n\n" +
+		"Besides Starlark statements, the following commands are available:\n" +
+		"  :help [<value>]  shows the help, or the documentation of a value\n" +
+		"  :hcl             prints the HCL encoding of the resources\n" +
+		"  :load <file>     executes the given file, defining its globals\n" +
+		"  :quit            exits the shell\n"
 )
 
 // REPLCmd implements the command `repl`.
 type REPLCmd struct {
 	commonCmd
+
+	History string `long:"history" description:"file where the history is persisted" default:"$HOME/.ascode_history"`
 }
 
 // Execute honors the flags.Commander interface.
 func (c *REPLCmd) Execute(args []string) error {
-	c.init()
-	c.runtime.REPL()
+	if err := c.init(); err != nil {
+		return err
+	}
+
+	repl := c.runtime.NewREPL()
+	repl.HistoryFile = os.ExpandEnv(c.History)
 
-	return nil
+	return repl.Run()
 }
 
 var _ flags.Commander = &REPLCmd{}
@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/jessevdk/go-flags"
+	"github.com/mcuadros/ascode/starlark/types"
 	"go.starlark.net/starlark"
 )
 
@@ -14,43 +17,70 @@ import (
 const (
 	RunCmdShortDescription = "Run parses, resolves, and executes a Starlark file."
 	RunCmdLongDescription  = RunCmdShortDescription + "\n\n" +
+		"Several files or directories can be given, all of them are executed \n" +
+		"in order into the same Terraform. A directory is executed from its \n" +
+		"`main.star`, if present, otherwise all its `.star` files are \n" +
+		"executed sorted by name. A resource address can't be defined in \n" +
+		"more than one file.\n\n" +
 		"When a provider is instantiated is automatically installed, at the \n" +
 		"default location (~/.terraform.d/plugins), this can be overrided \n" +
 		"using the flag `--plugin-dir=<PATH>`. \n\n" +
 		"The Starlark file can be \"transpiled\" to a HCL file using the flag \n" +
 		"`--to-hcl=<FILE>`. This file can be used directly with Terraform init \n" +
-		"and plan commands.\n"
+		"and plan commands.\n\n" +
+		"Using `--to-hcl-dir=<DIR>` the resources are split in several files, \n" +
+		"by `provider`, by the Starlark `file` instantiating them or by the \n" +
+		"name of a function, receiving a resource and returning a file name, \n" +
+		"given to `--split`. The backend and the provider versions are written \n" +
+		"to `backend.tf` and `versions.tf`. The previously generated files, \n" +
+		"not generated anymore, are deleted.\n\n" +
+		"Using `--source-map` every generated block is annotated with the \n" +
+		"Starlark position where it was defined, and a source map, \n" +
+		"`ascode.map.json`, is written next to the generated files.\n"
 )
 
+// GeneratedHeader is the first line of the files written by `--to-hcl-dir`,
+// only the files starting with it are deleted when stale.
+const GeneratedHeader = "# Code generated by ascode. DO NOT EDIT."
+
 // RunCmd implements the command `run`.
 type RunCmd struct {
 	commonCmd
 
 	ToHCL          string `long:"to-hcl" description:"dumps resources to a hcl file"`
+	ToHCLDir       string `long:"to-hcl-dir" description:"dumps resources to several hcl files in the given directory"`
+	Split          string `long:"split" description:"strategy to split the resources in files: provider, file or a function name" default:"provider"`
 	PrintHCL       bool   `long:"print-hcl" description:"prints resources to a hcl file"`
+	SourceMap      bool   `long:"source-map" description:"annotates the hcl blocks with its starlark position and writes a source map"`
 	NoValidate     bool   `long:"no-validate" description:"skips the validation of the resources"`
 	PositionalArgs struct {
-		File string `positional-arg-name:"file" description:"starlark source file"`
+		Files []string `positional-arg-name:"file" description:"starlark source files or directories"`
 	} `positional-args:"true" required:"1"`
+
+	// sourceMaps by output directory, shared by `--to-hcl` and `--to-hcl-dir`.
+	sourceMaps map[string]*types.SourceMap
 }
 
 // Execute honors the flags.Commander interface.
 func (c *RunCmd) Execute(args []string) error {
-	c.init()
-
-	_, err := c.runtime.ExecFile(c.PositionalArgs.File)
-	if err != nil {
-		if err, ok := err.(*starlark.EvalError); ok {
-			fmt.Println(err.Backtrace())
-			os.Exit(1)
-			return nil
-		}
+	if err := c.init(); err != nil {
+		return err
+	}
 
+	if err := c.execFiles(c.PositionalArgs.Files...); err != nil {
 		return err
 	}
 
 	c.validate()
-	return c.dumpToHCL()
+	if err := c.dumpToHCL(); err != nil {
+		return err
+	}
+
+	if err := c.dumpToHCLDir(); err != nil {
+		return err
+	}
+
+	return c.writeSourceMaps()
 }
 
 func (c *RunCmd) validate() {
@@ -60,7 +90,7 @@ func (c *RunCmd) validate() {
 
 	errs := c.runtime.Terraform.Validate()
 	for _, err := range errs {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, err.Backtrace())
 	}
 
 	if len(errs) != 0 {
@@ -84,7 +114,141 @@ func (c *RunCmd) dumpToHCL() error {
 		return nil
 	}
 
-	return ioutil.WriteFile(c.ToHCL, f.Bytes(), 0644)
+	m := c.sourceMap(filepath.Dir(c.ToHCL))
+	content, err := c.annotate(m, filepath.Base(c.ToHCL), f.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.ToHCL, content, 0644)
+}
+
+func (c *RunCmd) dumpToHCLDir() error {
+	if c.ToHCLDir == "" {
+		return nil
+	}
+
+	split, err := c.splitFunc()
+	if err != nil {
+		return err
+	}
+
+	files, err := c.runtime.Terraform.ToHCLFiles(split)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.ToHCLDir, 0755); err != nil {
+		return err
+	}
+
+	if err := c.removeStaleFiles(files); err != nil {
+		return err
+	}
+
+	m := c.sourceMap(c.ToHCLDir)
+	for name, f := range files {
+		content, err := c.annotate(m, name, append([]byte(GeneratedHeader+"\n\n"), f.Bytes()...))
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(c.ToHCLDir, name), content, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// annotate adds the source comments to the given file, if `--source-map` is
+// enabled.
+func (c *RunCmd) annotate(m *types.SourceMap, filename string, src []byte) ([]byte, error) {
+	if !c.SourceMap {
+		return src, nil
+	}
+
+	return c.runtime.Terraform.AnnotateHCL(filename, src, m)
+}
+
+// sourceMap returns the SourceMap of the given output directory, the files
+// written to the same directory share a single source map.
+func (c *RunCmd) sourceMap(dir string) *types.SourceMap {
+	dir = filepath.Clean(dir)
+	if c.sourceMaps == nil {
+		c.sourceMaps = make(map[string]*types.SourceMap)
+	}
+
+	if _, ok := c.sourceMaps[dir]; !ok {
+		c.sourceMaps[dir] = types.NewSourceMap()
+	}
+
+	return c.sourceMaps[dir]
+}
+
+func (c *RunCmd) writeSourceMaps() error {
+	if !c.SourceMap {
+		return nil
+	}
+
+	for dir, m := range c.sourceMaps {
+		if err := m.Write(filepath.Join(dir, types.SourceMapFileName)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *RunCmd) splitFunc() (types.SplitFunc, error) {
+	switch c.Split {
+	case "provider":
+		return types.SplitByProvider, nil
+	case "file":
+		return types.SplitByFile, nil
+	}
+
+	v, ok := c.runtime.Global(c.Split)
+	if !ok {
+		return nil, fmt.Errorf("invalid split %q, function not found", c.Split)
+	}
+
+	fn, ok := v.(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("invalid split %q, expected function, got %s", c.Split, v.Type())
+	}
+
+	return types.SplitByFunction(c.runtime.NewThread("split"), fn), nil
+}
+
+// removeStaleFiles deletes the files at the output directory, starting with
+// GeneratedHeader, not present in the given files.
+func (c *RunCmd) removeStaleFiles(files map[string]*hclwrite.File) error {
+	matches, err := filepath.Glob(filepath.Join(c.ToHCLDir, "*.tf"))
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range matches {
+		if _, ok := files[filepath.Base(filename)]; ok {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.HasPrefix(content, []byte(GeneratedHeader)) {
+			continue
+		}
+
+		if err := os.Remove(filename); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 var _ flags.Commander = &RunCmd{}
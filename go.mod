@@ -5,6 +5,7 @@ go 1.16
 require (
 	github.com/Masterminds/goutils v1.1.1 // indirect
 	github.com/Masterminds/semver/v3 v3.1.1
+	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e
 	github.com/containers/image/v5 v5.10.5
 	github.com/coreos/go-systemd v0.0.0-20190321100706-95778dfbb74e // indirect
 	github.com/fatih/color v1.10.0 // indirect
@@ -23,6 +24,7 @@ require (
 	github.com/mitchellh/cli v1.1.2
 	github.com/mitchellh/copystructure v1.1.1 // indirect
 	github.com/oklog/ulid/v2 v2.0.2
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/posener/complete v1.2.3 // indirect
 	github.com/prometheus/procfs v0.0.5 // indirect
 	github.com/qri-io/starlib v0.4.2
@@ -31,6 +33,7 @@ require (
 	github.com/smartystreets/goconvey v0.0.0-20181108003508-044398e4856c // indirect
 	github.com/stretchr/testify v1.7.0
 	github.com/zclconf/go-cty v1.8.1
+	github.com/zclconf/go-cty-yaml v1.0.1
 	go.starlark.net v0.0.0-20210406145628-7a1108eaa012
 	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2 // indirect
 	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4 // indirect
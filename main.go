@@ -14,6 +14,16 @@ func main() {
 	parser := flags.NewNamedParser("ascode", flags.Default)
 	parser.LongDescription = "AsCode - Terraform Alternative Syntax."
 	parser.AddCommand("run", cmd.RunCmdShortDescription, cmd.RunCmdLongDescription, &cmd.RunCmd{})
+	parser.AddCommand("docs", cmd.DocsCmdShortDescription, cmd.DocsCmdLongDescription, &cmd.DocsCmd{})
+	parser.AddCommand("drift", cmd.DriftCmdShortDescription, cmd.DriftCmdLongDescription, &cmd.DriftCmd{})
+	parser.AddCommand("fmt", cmd.FmtCmdShortDescription, cmd.FmtCmdLongDescription, &cmd.FmtCmd{})
+	parser.AddCommand("explain-errors", cmd.ExplainErrorsCmdShortDescription, cmd.ExplainErrorsCmdLongDescription, &cmd.ExplainErrorsCmd{})
+	parser.AddCommand("graph", cmd.GraphCmdShortDescription, cmd.GraphCmdLongDescription, &cmd.GraphCmd{})
+	parser.AddCommand("lint", cmd.LintCmdShortDescription, cmd.LintCmdLongDescription, &cmd.LintCmd{})
+	parser.AddCommand("lsp", cmd.LSPCmdShortDescription, cmd.LSPCmdLongDescription, &cmd.LSPCmd{})
+	mod, _ := parser.AddCommand("mod", cmd.ModCmdShortDescription, cmd.ModCmdLongDescription, &cmd.ModCmd{})
+	mod.AddCommand("download", cmd.ModDownloadCmdShortDescription, cmd.ModDownloadCmdLongDescription, &cmd.ModDownloadCmd{})
+	mod.AddCommand("tidy", cmd.ModTidyCmdShortDescription, cmd.ModTidyCmdLongDescription, &cmd.ModTidyCmd{})
 	parser.AddCommand("repl", cmd.REPLCmdShortDescription, cmd.REPLCmdLongDescription, &cmd.REPLCmd{})
 	parser.AddCommand("version", cmd.VersionCmdShortDescription, cmd.VersionCmdLongDescription, &cmd.VersionCmd{})
 
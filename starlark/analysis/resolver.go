@@ -0,0 +1,269 @@
+// Package analysis implements the static resolution of the values defined by
+// a Starlark file, such as providers, resource collections and resources,
+// without executing it.
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/mcuadros/ascode/starlark/types"
+	"github.com/mcuadros/ascode/terraform"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// ProviderFunc returns a new Provider for the given type and version, the
+// version can be empty.
+type ProviderFunc func(typ, version string) (*types.Provider, error)
+
+// NewProviderFunc returns a ProviderFunc instantiating the providers with the
+// given PluginManager.
+func NewProviderFunc(pm *terraform.PluginManager) ProviderFunc {
+	return func(typ, version string) (*types.Provider, error) {
+		return types.NewProvider(pm, typ, version, "", nil)
+	}
+}
+
+// Resolver resolves the expressions of a file to the values they evaluate
+// to, when they can be known statically: the calls to `tf.provider` with
+// literal arguments, the attributes of the resolved values, the calls to
+// resolved resource collections, and the variables assigned only once to any
+// of them.
+//
+// The resources returned are not the ones created by the execution of the
+// file, they are created without arguments and are only valid to inspect
+// its schema.
+type Resolver struct {
+	provider  ProviderFunc
+	providers map[string]*types.Provider
+	bindings  map[*syntax.Ident][]syntax.Expr
+	values    map[syntax.Expr]starlark.Value
+	visiting  map[syntax.Expr]bool
+	thread    *starlark.Thread
+}
+
+// NewResolver returns a new Resolver for the given file, the file is resolved
+// using the go.starlark.net/resolve package, setting the bindings of its
+// identifiers. The undefined names are considered predeclared.
+func NewResolver(f *syntax.File, fn ProviderFunc) *Resolver {
+	r := &Resolver{
+		provider:  fn,
+		providers: make(map[string]*types.Provider),
+		bindings:  make(map[*syntax.Ident][]syntax.Expr),
+		values:    make(map[syntax.Expr]starlark.Value),
+		visiting:  make(map[syntax.Expr]bool),
+		thread:    &starlark.Thread{Name: "analysis"},
+	}
+
+	// the resolution errors are ignored, the bindings are defined anyway.
+	isPredeclared := func(string) bool { return true }
+	resolve.File(f, isPredeclared, starlark.Universe.Has)
+
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if assign, ok := n.(*syntax.AssignStmt); ok {
+			r.bind(assign.LHS, assign.RHS, assign.Op == syntax.EQ)
+		}
+
+		if loop, ok := n.(*syntax.ForStmt); ok {
+			r.bind(loop.Vars, nil, false)
+		}
+
+		return true
+	})
+
+	return r
+}
+
+// bind records the value assigned to an identifier, an identifier assigned
+// more than once or from a non simple assignment can't be resolved.
+func (r *Resolver) bind(lhs, rhs syntax.Expr, simple bool) {
+	switch lhs := lhs.(type) {
+	case *syntax.Ident:
+		first := binding(lhs)
+		if first == nil {
+			return
+		}
+
+		r.bindings[first] = append(r.bindings[first], rhs)
+		if !simple {
+			r.bindings[first] = append(r.bindings[first], nil)
+		}
+	case *syntax.TupleExpr:
+		for _, x := range lhs.List {
+			r.bind(x, nil, false)
+		}
+	case *syntax.ListExpr:
+		for _, x := range lhs.List {
+			r.bind(x, nil, false)
+		}
+	case *syntax.ParenExpr:
+		r.bind(lhs.X, rhs, simple)
+	}
+}
+
+// Resolve returns the value of the given expression, or nil if it can't be
+// resolved statically. An error is returned if the value can't be
+// computed, eg.: the provider can't be instantiated.
+func (r *Resolver) Resolve(x syntax.Expr) (starlark.Value, error) {
+	if v, ok := r.values[x]; ok {
+		return v, nil
+	}
+
+	if r.visiting[x] {
+		return nil, nil
+	}
+
+	r.visiting[x] = true
+	defer delete(r.visiting, x)
+
+	v, err := r.resolve(x)
+	if err != nil {
+		return nil, err
+	}
+
+	r.values[x] = v
+	return v, nil
+}
+
+func (r *Resolver) resolve(x syntax.Expr) (starlark.Value, error) {
+	switch x := x.(type) {
+	case *syntax.ParenExpr:
+		return r.Resolve(x.X)
+	case *syntax.Ident:
+		exprs := r.bindings[binding(x)]
+		if len(exprs) != 1 || exprs[0] == nil {
+			return nil, nil
+		}
+
+		return r.Resolve(exprs[0])
+	case *syntax.DotExpr:
+		v, err := r.Resolve(x.X)
+		if err != nil || v == nil {
+			return nil, err
+		}
+
+		attrs, ok := v.(starlark.HasAttrs)
+		if !ok {
+			return nil, nil
+		}
+
+		v, err = attrs.Attr(x.Name.Name)
+		if err != nil || v == nil || v == starlark.None {
+			return nil, nil
+		}
+
+		return v, nil
+	case *syntax.CallExpr:
+		if IsProviderCall(x) {
+			return r.callProvider(x)
+		}
+
+		v, err := r.Resolve(x.Fn)
+		if err != nil || v == nil {
+			return nil, err
+		}
+
+		c, ok := v.(*types.ResourceCollection)
+		if !ok {
+			return nil, nil
+		}
+
+		return c.CallInternal(r.thread, nil, nil)
+	}
+
+	return nil, nil
+}
+
+func (r *Resolver) callProvider(call *syntax.CallExpr) (starlark.Value, error) {
+	var args []string
+	for _, arg := range call.Args {
+		lit, ok := arg.(*syntax.Literal)
+		if !ok {
+			break
+		}
+
+		s, ok := lit.Value.(string)
+		if !ok {
+			return nil, nil
+		}
+
+		args = append(args, s)
+	}
+
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	typ, version := args[0], ""
+	if len(args) > 1 {
+		version = args[1]
+	}
+
+	key := typ + "@" + version
+	if p, ok := r.providers[key]; ok {
+		return p, nil
+	}
+
+	p, err := r.provider(typ, version)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to load provider %q: %s", call.Lparen, typ, err)
+	}
+
+	r.providers[key] = p
+	return p, nil
+}
+
+// CallSchema returns the schema of the block defined by the given call, a
+// call to `tf.provider` or to a resource collection, or nil if the callee
+// can't be resolved.
+func (r *Resolver) CallSchema(call *syntax.CallExpr) (*configschema.Block, error) {
+	if IsProviderCall(call) {
+		v, err := r.Resolve(call)
+		if err != nil || v == nil {
+			return nil, err
+		}
+
+		return v.(*types.Provider).Schema(), nil
+	}
+
+	v, err := r.Resolve(call.Fn)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	if c, ok := v.(*types.ResourceCollection); ok {
+		return c.Schema(), nil
+	}
+
+	return nil, nil
+}
+
+// IsProviderCall returns true if the given call is a call to the predeclared
+// `tf.provider`.
+func IsProviderCall(call *syntax.CallExpr) bool {
+	dot, ok := call.Fn.(*syntax.DotExpr)
+	if !ok || dot.Name.Name != "provider" {
+		return false
+	}
+
+	id, ok := dot.X.(*syntax.Ident)
+	if !ok || id.Name != "tf" {
+		return false
+	}
+
+	b, ok := id.Binding.(*resolve.Binding)
+	return !ok || b.Scope == resolve.Predeclared
+}
+
+// binding returns the first identifier binding the variable of the given
+// identifier, or nil if is not a variable of the file.
+func binding(id *syntax.Ident) *syntax.Ident {
+	b, ok := id.Binding.(*resolve.Binding)
+	if !ok {
+		return nil
+	}
+
+	return b.First
+}
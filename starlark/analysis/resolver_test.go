@@ -0,0 +1,90 @@
+package analysis
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mcuadros/ascode/starlark/types"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/syntax"
+)
+
+func parse(t *testing.T, src string) *syntax.File {
+	f, err := syntax.Parse("test.star", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func failingProviderFunc(calls *[]string) ProviderFunc {
+	return func(typ, version string) (*types.Provider, error) {
+		*calls = append(*calls, typ+"@"+version)
+		return nil, fmt.Errorf("not available")
+	}
+}
+
+func TestResolverProvider(t *testing.T) {
+	f := parse(t, ""+
+		"aws = tf.provider(\"aws\", \"2.13.0\", region=\"us-west-2\")\n"+
+		"aws.resource.instance(\"web\")\n",
+	)
+
+	var calls []string
+	r := NewResolver(f, failingProviderFunc(&calls))
+
+	call := f.Stmts[1].(*syntax.ExprStmt).X.(*syntax.CallExpr)
+	_, err := r.CallSchema(call)
+	assert.EqualError(t, err, `test.star:1:18: unable to load provider "aws": not available`)
+	assert.Equal(t, []string{"aws@2.13.0"}, calls)
+}
+
+func TestResolverUnresolvable(t *testing.T) {
+	f := parse(t, ""+
+		"aws = tf.provider(\"aws\")\n"+
+		"aws = tf.provider(\"aws\", \"2.13.0\")\n"+
+		"def f(tf, google):\n"+
+		"    tf.provider(\"google\")\n"+
+		"    google.resource.instance()\n"+
+		"aws.resource.instance()\n"+
+		"tf.provider(name)\n",
+	)
+
+	var calls []string
+	r := NewResolver(f, failingProviderFunc(&calls))
+
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if call, ok := n.(*syntax.CallExpr); ok && !IsProviderCall(call) {
+			v, err := r.Resolve(call)
+			assert.NoError(t, err)
+			assert.Nil(t, v)
+		}
+
+		return true
+	})
+
+	assert.Len(t, calls, 0)
+}
+
+func TestIsProviderCall(t *testing.T) {
+	f := parse(t, ""+
+		"tf.provider(\"aws\")\n"+
+		"def f(tf):\n"+
+		"    tf.provider(\"aws\")\n"+
+		"foo.provider(\"aws\")\n",
+	)
+
+	NewResolver(f, nil)
+
+	var results []bool
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if call, ok := n.(*syntax.CallExpr); ok {
+			results = append(results, IsProviderCall(call))
+		}
+
+		return true
+	})
+
+	assert.Equal(t, []bool{true, false, false}, results)
+}
@@ -0,0 +1,235 @@
+// Package docs extracts the documentation of the Starlark files, from the
+// docstrings of the modules and its functions, rendering it as Markdown or
+// HTML.
+package docs
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mcuadros/ascode/starlark/format"
+	"github.com/mcuadros/ascode/starlark/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Module is the documentation of a Starlark file.
+type Module struct {
+	// Filename of the Starlark file.
+	Filename string
+	// Doc is the docstring of the module, the string literal being the first
+	// statement of the file.
+	Doc string
+	// Functions are the public functions of the module, the ones not starting
+	// by underscore, in order of definition.
+	Functions []*Function
+}
+
+// Function is the documentation of a function defined by a Module.
+type Function struct {
+	Name string
+	// Doc is the docstring of the function, the string literal being the
+	// first statement of its body.
+	Doc    string
+	Params []*Param
+	// Resources are the resource types, and the data source types prefixed
+	// by `data.`, created by the function, see Module.DiscoverResources.
+	Resources []string
+	Pos       syntax.Position
+}
+
+// Signature returns the signature of the function, eg.:
+// `bucket(name, acl="private", **kwargs)`.
+func (f *Function) Signature() string {
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = p.String()
+	}
+
+	return f.Name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// Param is a parameter of a Function.
+type Param struct {
+	Name string
+	// Default is the source of the default value, if any.
+	Default string
+	// Variadic is true for the `*args` parameter, and for the bare `*`
+	// preceding the keyword-only parameters, without name.
+	Variadic bool
+	// Kwargs is true for the `**kwargs` parameter.
+	Kwargs bool
+}
+
+// Required returns true if the parameter has no default value and it's
+// neither `*args` nor `**kwargs`.
+func (p *Param) Required() bool {
+	return p.Default == "" && !p.Variadic && !p.Kwargs
+}
+
+func (p *Param) String() string {
+	switch {
+	case p.Variadic:
+		return "*" + p.Name
+	case p.Kwargs:
+		return "**" + p.Name
+	case p.Default != "":
+		return p.Name + "=" + p.Default
+	}
+
+	return p.Name
+}
+
+// Parse returns the documentation of the given Starlark source. The src
+// argument is handled as in syntax.Parse.
+func Parse(filename string, src interface{}) (*Module, error) {
+	f, err := syntax.Parse(filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Module{Filename: filename, Doc: docstring(f.Stmts)}
+	for _, stmt := range f.Stmts {
+		def, ok := stmt.(*syntax.DefStmt)
+		if !ok || strings.HasPrefix(def.Name.Name, "_") {
+			continue
+		}
+
+		m.Functions = append(m.Functions, &Function{
+			Name:   def.Name.Name,
+			Doc:    docstring(def.Body),
+			Params: params(def.Params),
+			Pos:    def.Def,
+		})
+	}
+
+	return m, nil
+}
+
+func params(list []syntax.Expr) []*Param {
+	var params []*Param
+	for _, x := range list {
+		switch x := x.(type) {
+		case *syntax.Ident:
+			params = append(params, &Param{Name: x.Name})
+		case *syntax.BinaryExpr:
+			params = append(params, &Param{
+				Name:    x.X.(*syntax.Ident).Name,
+				Default: format.Expr(x.Y),
+			})
+		case *syntax.UnaryExpr:
+			p := &Param{Variadic: x.Op == syntax.STAR, Kwargs: x.Op == syntax.STARSTAR}
+			// a bare `*` separating the keyword-only parameters has no name.
+			if id, ok := x.X.(*syntax.Ident); ok {
+				p.Name = id.Name
+			}
+
+			params = append(params, p)
+		}
+	}
+
+	return params
+}
+
+// docstring returns the docstring of the given statements, cleaning up its
+// indentation.
+func docstring(stmts []syntax.Stmt) string {
+	if len(stmts) == 0 {
+		return ""
+	}
+
+	expr, ok := stmts[0].(*syntax.ExprStmt)
+	if !ok {
+		return ""
+	}
+
+	lit, ok := expr.X.(*syntax.Literal)
+	if !ok || lit.Token != syntax.STRING {
+		return ""
+	}
+
+	return cleanDoc(lit.Value.(string))
+}
+
+// cleanDoc removes the indentation common to all the lines of a docstring
+// but the first one, and the leading and trailing blank lines.
+func cleanDoc(doc string) string {
+	lines := strings.Split(strings.Replace(doc, "\t", "    ", -1), "\n")
+
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" {
+			continue
+		}
+
+		if n := len(line) - len(trimmed); indent == -1 || n < indent {
+			indent = n
+		}
+	}
+
+	lines[0] = strings.TrimSpace(lines[0])
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= indent && indent > 0 {
+			lines[i] = lines[i][indent:]
+		}
+
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// DiscoverResources calls every function of the module, found at the given
+// globals, recording the resource and data source types created by it into
+// the given Terraform. Every required parameter is given its own name as
+// value, eg.: `name="name"`, since most of them are strings, such as the
+// resource names. The errors are ignored, keeping the resources created before
+// them.
+func (m *Module) DiscoverResources(thread *starlark.Thread, tf *types.Terraform, globals starlark.StringDict) {
+	for _, f := range m.Functions {
+		fn, ok := globals[f.Name].(*starlark.Function)
+		if !ok {
+			continue
+		}
+
+		known := make(map[*types.Resource]bool)
+		for _, r := range tf.Resources() {
+			known[r] = true
+		}
+
+		var kwargs []starlark.Tuple
+		for _, p := range f.Params {
+			if p.Required() {
+				kwargs = append(kwargs, starlark.Tuple{starlark.String(p.Name), starlark.String(p.Name)})
+			}
+		}
+
+		starlark.Call(thread, fn, nil, kwargs)
+
+		created := make(map[string]bool)
+		for _, r := range tf.Resources() {
+			if !known[r] {
+				created[resourceType(r)] = true
+			}
+		}
+
+		f.Resources = nil
+		for typ := range created {
+			f.Resources = append(f.Resources, typ)
+		}
+
+		sort.Strings(f.Resources)
+	}
+}
+
+// resourceType returns the type of the given resource, prefixed by `data.`
+// for the data sources.
+func resourceType(r *types.Resource) string {
+	parts := strings.Split(r.Address(), ".")
+	if parts[0] == string(types.DataSourceKind) {
+		return parts[0] + "." + parts[1]
+	}
+
+	return parts[0]
+}
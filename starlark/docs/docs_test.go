@@ -0,0 +1,99 @@
+package docs
+
+import (
+	"testing"
+
+	"github.com/mcuadros/ascode/starlark/types"
+	"github.com/mcuadros/ascode/terraform"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestParse(t *testing.T) {
+	m, err := Parse("testdata/lib.star", nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Helpers to define null resources.\n\nThe resources are defined using the `null` provider.", m.Doc)
+	assert.Len(t, m.Functions, 3)
+
+	f := m.Functions[0]
+	assert.Equal(t, "resource", f.Name)
+	assert.Equal(t, "resource(name, triggers={}, *args, **kwargs)", f.Signature())
+	assert.Equal(t, "Returns a new null resource.\n\nThe triggers are copied to the resource.", f.Doc)
+	assert.Equal(t, 8, int(f.Pos.Line))
+	assert.True(t, f.Params[0].Required())
+	assert.False(t, f.Params[1].Required())
+	assert.False(t, f.Params[2].Required())
+
+	assert.Equal(t, "data_source(name, inputs=None)", m.Functions[1].Signature())
+	assert.Equal(t, "Returns a new null data source.", m.Functions[1].Doc)
+
+	assert.Equal(t, "undocumented(*, name)", m.Functions[2].Signature())
+	assert.Equal(t, "", m.Functions[2].Doc)
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Parse("test.star", "def f(:")
+	assert.Error(t, err)
+}
+
+func TestMarkdown(t *testing.T) {
+	m, err := Parse("lib.star", "\"\"\"Library.\"\"\"\ndef f(a, b=1):\n    \"\"\"Does f.\"\"\"\n")
+	assert.NoError(t, err)
+
+	m.Functions[0].Resources = []string{"data.null_data_source", "null_resource"}
+
+	out, err := Markdown([]*Module{m})
+	assert.NoError(t, err)
+	assert.Equal(t, ""+
+		"# lib.star\n"+
+		"\n"+
+		"Library.\n"+
+		"\n"+
+		"## f\n"+
+		"\n"+
+		"`f(a, b=1)`\n"+
+		"\n"+
+		"Does f.\n"+
+		"\n"+
+		"| Parameter | Default |\n"+
+		"|-----------|---------|\n"+
+		"| `a` | required |\n"+
+		"| `b` | `1` |\n"+
+		"\n"+
+		"Resources:\n"+
+		"\n"+
+		"- `data.null_data_source`\n"+
+		"- `null_resource`\n", string(out))
+}
+
+func TestHTML(t *testing.T) {
+	m, err := Parse("lib.star", "def f(a, b=\"<b>\"):\n    pass\n")
+	assert.NoError(t, err)
+
+	out, err := HTML([]*Module{m})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "<h2 id=\"f\">f</h2>")
+	assert.Contains(t, string(out), "<code>&#34;&lt;b&gt;&#34;</code>")
+}
+
+func TestModuleDiscoverResources(t *testing.T) {
+	pm := &terraform.PluginManager{Path: ".providers"}
+	tf := types.NewTerraform(pm)
+
+	thread := &starlark.Thread{}
+	thread.SetLocal(types.PluginManagerLocal, pm)
+
+	globals, err := starlark.ExecFile(thread, "testdata/lib.star", nil, starlark.StringDict{"tf": tf})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Parse("testdata/lib.star", nil)
+	assert.NoError(t, err)
+
+	m.DiscoverResources(thread, tf, globals)
+	assert.Equal(t, []string{"null_resource"}, m.Functions[0].Resources)
+	assert.Equal(t, []string{"data.null_data_source"}, m.Functions[1].Resources)
+	assert.Len(t, m.Functions[2].Resources, 0)
+}
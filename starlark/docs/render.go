@@ -0,0 +1,101 @@
+package docs
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"text/template"
+)
+
+var funcs = map[string]interface{}{
+	"backquote": func() string { return "`" },
+}
+
+const param = `{{ define "param" }}{{ if .Variadic }}*{{ else if .Kwargs }}**{{ end }}{{ .Name }}{{ end }}`
+
+var markdown = template.Must(template.New("markdown").Funcs(funcs).Parse(param + `
+{{- range $i, $m := . -}}
+{{- if $i }}
+{{ end -}}
+# {{ $m.Filename }}
+{{ if $m.Doc }}
+{{ $m.Doc }}
+{{ end -}}
+{{ range $m.Functions }}
+## {{ .Name }}
+
+{{ backquote }}{{ .Signature }}{{ backquote }}
+{{ if .Doc }}
+{{ .Doc }}
+{{ end -}}
+{{ if .Params }}
+| Parameter | Default |
+|-----------|---------|
+{{ range .Params }}{{ if .Name -}}
+| {{ backquote }}{{ template "param" . }}{{ backquote }} | {{ if .Default }}{{ backquote }}{{ .Default }}{{ backquote }}{{ else if .Required }}required{{ end }} |
+{{ end }}{{ end -}}
+{{ end -}}
+{{ if .Resources }}
+Resources:
+{{ range .Resources }}
+- {{ backquote }}{{ . }}{{ backquote }}
+{{- end }}
+{{ end -}}
+{{ end -}}
+{{ end -}}
+`))
+
+var html = htmltemplate.Must(htmltemplate.New("html").Parse(param + `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ range $i, $m := . }}{{ if $i }}, {{ end }}{{ $m.Filename }}{{ end }}</title>
+</head>
+<body>
+{{- range . }}
+<section>
+<h1>{{ .Filename }}</h1>
+{{- if .Doc }}
+<pre>{{ .Doc }}</pre>
+{{- end }}
+{{- range .Functions }}
+<h2 id="{{ .Name }}">{{ .Name }}</h2>
+<code>{{ .Signature }}</code>
+{{- if .Doc }}
+<pre>{{ .Doc }}</pre>
+{{- end }}
+{{- if .Params }}
+<table>
+<tr><th>Parameter</th><th>Default</th></tr>
+{{- range .Params }}{{ if .Name }}
+<tr><td><code>{{ template "param" . }}</code></td><td>{{ if .Default }}<code>{{ .Default }}</code>{{ else if .Required }}required{{ end }}</td></tr>
+{{- end }}{{ end }}
+</table>
+{{- end }}
+{{- if .Resources }}
+<p>Resources:</p>
+<ul>
+{{- range .Resources }}
+<li><code>{{ . }}</code></li>
+{{- end }}
+</ul>
+{{- end }}
+{{- end }}
+</section>
+{{- end }}
+</body>
+</html>
+`))
+
+// Markdown renders the documentation of the given modules as Markdown.
+func Markdown(modules []*Module) ([]byte, error) {
+	var buf bytes.Buffer
+	err := markdown.Execute(&buf, modules)
+	return buf.Bytes(), err
+}
+
+// HTML renders the documentation of the given modules as a HTML page.
+func HTML(modules []*Module) ([]byte, error) {
+	var buf bytes.Buffer
+	err := html.Execute(&buf, modules)
+	return buf.Bytes(), err
+}
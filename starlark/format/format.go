@@ -0,0 +1,779 @@
+// Package format implements the canonical formatting of the Starlark source
+// files, preserving the comments and the line breaks chosen by the author.
+package format
+
+import (
+	"bytes"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+const indentation = "    "
+
+// KeywordOrderFunc returns the order of the keyword arguments of the given
+// call, or nil if the arguments should keep its order.
+type KeywordOrderFunc func(call *syntax.CallExpr) []string
+
+// Options of the formatting.
+type Options struct {
+	// KeywordOrder if not nil is used to sort the keyword arguments of the
+	// calls, the keyword arguments not contained in the order are moved
+	// to the end, keeping its original order.
+	KeywordOrder KeywordOrderFunc
+}
+
+// Source parses and formats the given Starlark source.
+func Source(filename string, src []byte, opts *Options) ([]byte, error) {
+	f, err := syntax.Parse(filename, src, syntax.RetainComments)
+	if err != nil {
+		return nil, err
+	}
+
+	return File(f, opts), nil
+}
+
+// File formats the given parsed Starlark file, the file should be parsed
+// with the mode syntax.RetainComments, otherwise the comments are lost.
+//
+// The statements are indented using four spaces, the operators are
+// surrounded by spaces, and at most one blank line is kept between
+// statements. Calls, lists, dicts, tuples and parameters are written in a
+// single line, unless any of its elements was in a different line, in that
+// case every element is written in its own line with a trailing comma.
+func File(f *syntax.File, opts *Options) []byte {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	p := &printer{opts: opts, bol: true}
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if n != nil && n.Comments() != nil {
+			p.all = append(p.all, n.Comments().Before...)
+		}
+
+		return true
+	})
+
+	if c := f.Comments(); c != nil {
+		p.all = append(p.all, c.After...)
+	}
+
+	p.stmts(f.Stmts)
+	if c := f.Comments(); c != nil {
+		after := p.unwritten(c.After)
+		if len(f.Stmts) != 0 {
+			after = p.trailing(lastBlock(f.Stmts[len(f.Stmts)-1]), after, 1)
+		}
+
+		p.comments(after)
+	}
+
+	p.flush()
+	return p.buf.Bytes()
+}
+
+// Expr formats the given expression, as it is formatted by File, in a single
+// line unless any of its elements was written in a different line.
+func Expr(x syntax.Expr) string {
+	p := &printer{opts: &Options{}, bol: true, depth: 1}
+	p.expr(x)
+	p.flush()
+	return strings.TrimSpace(p.buf.String())
+}
+
+type printer struct {
+	opts   *Options
+	buf    bytes.Buffer
+	indent int
+	// line is the last line of the source printed, used to preserve the
+	// blank lines, zero at the beginning of a block.
+	line int
+	// suffix are the end of line comments pending to be written.
+	suffix []syntax.Comment
+	// bol is true if nothing is written yet in the current line.
+	bol bool
+	// depth is the number of brackets open.
+	depth int
+	// all are the whole-line comments of the file, and written the ones
+	// already written, by position. The parser attaches the comments to the
+	// following node, so the ones at the end of a block or before a closing
+	// bracket are written before reaching its node.
+	all     []syntax.Comment
+	written map[syntax.Position]bool
+}
+
+func (p *printer) print(strs ...string) {
+	for _, s := range strs {
+		if p.bol {
+			p.buf.WriteString(strings.Repeat(indentation, p.indent))
+			p.bol = false
+		}
+
+		p.buf.WriteString(s)
+	}
+}
+
+// newline ends the current line, writing the pending suffix comments.
+func (p *printer) newline() {
+	for _, c := range p.suffix {
+		p.buf.WriteString("  ")
+		p.buf.WriteString(strings.TrimRight(c.Text, " \t"))
+	}
+
+	p.suffix = nil
+	p.buf.WriteByte('\n')
+	p.bol = true
+}
+
+func (p *printer) flush() {
+	if len(p.suffix) != 0 {
+		p.newline()
+	}
+}
+
+// blank writes a blank line if the given line of the source was preceded
+// by a blank line.
+func (p *printer) blank(line int) {
+	if p.line != 0 && line > p.line+1 {
+		p.buf.WriteByte('\n')
+		p.line = line - 1
+	}
+}
+
+// comments writes the given whole-line comments, not written yet.
+func (p *printer) comments(comments []syntax.Comment) {
+	if p.written == nil {
+		p.written = make(map[syntax.Position]bool)
+	}
+
+	for _, c := range p.unwritten(comments) {
+		if !p.bol {
+			p.newline()
+		}
+
+		p.blank(int(c.Start.Line))
+		p.print(strings.TrimRight(c.Text, " \t"))
+		p.newline()
+		p.line = int(c.Start.Line)
+		p.written[c.Start] = true
+	}
+}
+
+// unwritten returns the given comments not written yet.
+func (p *printer) unwritten(comments []syntax.Comment) []syntax.Comment {
+	var unwritten []syntax.Comment
+	for _, c := range comments {
+		if !p.written[c.Start] {
+			unwritten = append(unwritten, c)
+		}
+	}
+
+	return unwritten
+}
+
+// before writes the comments preceding the given node.
+func (p *printer) before(n syntax.Node) {
+	if c := n.Comments(); c != nil && len(p.unwritten(c.Before)) != 0 {
+		p.comments(c.Before)
+		p.blank(int(syntax.Start(n).Line))
+	}
+}
+
+// trailing writes the comments, at the beginning of the given ones, indented
+// deeper than the given column, at the end of the given block, or of its
+// nested blocks, following its indentation. The remaining comments are
+// returned.
+func (p *printer) trailing(block []syntax.Stmt, comments []syntax.Comment, col int32) []syntax.Comment {
+	n := 0
+	for n < len(comments) && comments[n].Start.Col > col {
+		n++
+	}
+
+	for i := range comments[:n] {
+		depth := 0
+		for b := block; len(b) != 0 && comments[i].Start.Col >= syntax.Start(b[0]).Col; b = lastBlock(b[len(b)-1]) {
+			depth++
+		}
+
+		p.indent += depth
+		p.comments(comments[i : i+1])
+		p.indent -= depth
+	}
+
+	return comments[n:]
+}
+
+// between writes the comments not written yet between the given positions,
+// not being attached to any node in between, eg.: in an empty list.
+func (p *printer) between(start, end syntax.Position) {
+	var comments []syntax.Comment
+	for _, c := range p.all {
+		if isBefore(start, c.Start) && isBefore(c.Start, end) {
+			comments = append(comments, c)
+		}
+	}
+
+	p.comments(comments)
+}
+
+// lastBlock returns the last block of statements of the given statement, if
+// any, eg.: the else block of an if statement.
+func lastBlock(stmt syntax.Stmt) []syntax.Stmt {
+	switch stmt := stmt.(type) {
+	case *syntax.DefStmt:
+		return stmt.Body
+	case *syntax.ForStmt:
+		return stmt.Body
+	case *syntax.WhileStmt:
+		return stmt.Body
+	case *syntax.IfStmt:
+		if len(stmt.False) == 0 {
+			return stmt.True
+		}
+
+		if elif, ok := stmt.False[0].(*syntax.IfStmt); ok && len(stmt.False) == 1 && elif.If == stmt.ElsePos {
+			return lastBlock(elif)
+		}
+
+		return stmt.False
+	}
+
+	return nil
+}
+
+func isBefore(a, b syntax.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+}
+
+// after queues the end of line comments of the given node.
+func (p *printer) after(n syntax.Node) {
+	if c := n.Comments(); c != nil {
+		p.suffix = append(p.suffix, c.Suffix...)
+	}
+}
+
+func (p *printer) stmts(stmts []syntax.Stmt) {
+	p.line = 0
+	for i, stmt := range stmts {
+		p.blank(startLine(stmt))
+
+		var before []syntax.Comment
+		if c := stmt.Comments(); c != nil {
+			before = p.unwritten(c.Before)
+		}
+
+		// the comments indented deeper than the statement, belong to the
+		// end of the previous one.
+		if i != 0 {
+			before = p.trailing(lastBlock(stmts[i-1]), before, syntax.Start(stmt).Col)
+		}
+
+		p.comments(before)
+		p.blank(int(syntax.Start(stmt).Line))
+		p.stmt(stmt)
+		p.after(stmt)
+		if !p.bol {
+			p.newline()
+		}
+
+		p.line = int(syntax.End(stmt).Line)
+	}
+}
+
+func (p *printer) block(stmts []syntax.Stmt) {
+	p.newline()
+	p.indent++
+	p.stmts(stmts)
+	p.indent--
+}
+
+func (p *printer) stmt(stmt syntax.Stmt) {
+	switch stmt := stmt.(type) {
+	case *syntax.ExprStmt:
+		p.expr(stmt.X)
+	case *syntax.AssignStmt:
+		p.expr(stmt.LHS)
+		p.print(" ", stmt.Op.String(), " ")
+		p.expr(stmt.RHS)
+	case *syntax.BranchStmt:
+		p.print(stmt.Token.String())
+	case *syntax.ReturnStmt:
+		p.print("return")
+		if stmt.Result != nil {
+			p.print(" ")
+			p.expr(stmt.Result)
+		}
+	case *syntax.LoadStmt:
+		p.load(stmt)
+	case *syntax.DefStmt:
+		p.print("def ")
+		p.expr(stmt.Name)
+		p.list("(", ")", stmt.Name.NamePos, syntax.End(stmt.Name), stmt.Params, true)
+		p.print(":")
+		p.block(stmt.Body)
+	case *syntax.IfStmt:
+		p.print("if ")
+		p.ifStmt(stmt)
+	case *syntax.ForStmt:
+		p.print("for ")
+		p.expr(stmt.Vars)
+		p.print(" in ")
+		p.expr(stmt.X)
+		p.print(":")
+		p.block(stmt.Body)
+	case *syntax.WhileStmt:
+		p.print("while ")
+		p.expr(stmt.Cond)
+		p.print(":")
+		p.block(stmt.Body)
+	}
+}
+
+func (p *printer) ifStmt(stmt *syntax.IfStmt) {
+	p.expr(stmt.Cond)
+	p.print(":")
+	p.block(stmt.True)
+	if len(stmt.False) == 0 {
+		return
+	}
+
+	// the comments preceding the else are attached to the first statement
+	// of its block, or to the elif.
+	var before []syntax.Comment
+	if c := stmt.False[0].Comments(); c != nil {
+		for _, comment := range p.unwritten(c.Before) {
+			if comment.Start.Line < stmt.ElsePos.Line {
+				before = append(before, comment)
+			}
+		}
+	}
+
+	if before = p.trailing(stmt.True, before, stmt.ElsePos.Col); len(before) != 0 {
+		p.comments(before)
+		p.blank(int(stmt.ElsePos.Line))
+	}
+
+	if elif, ok := stmt.False[0].(*syntax.IfStmt); ok && len(stmt.False) == 1 && elif.If == stmt.ElsePos {
+		p.print("elif ")
+		p.ifStmt(elif)
+		return
+	}
+
+	p.print("else:")
+	p.block(stmt.False)
+}
+
+func (p *printer) load(stmt *syntax.LoadStmt) {
+	args := []syntax.Node{stmt.Module}
+	for i := range stmt.To {
+		args = append(args, stmt.To[i])
+	}
+
+	p.print("load")
+	p.items("(", ")", stmt.Load, stmt.Rparen, args, true, func(i int) {
+		if i == 0 {
+			p.expr(stmt.Module)
+			return
+		}
+
+		// To is the local name and From the name in the loaded module.
+		from, to := stmt.From[i-1], stmt.To[i-1]
+		p.before(to)
+		if from.Name != to.Name {
+			p.print(to.Name, "=")
+		}
+
+		p.print(quote(from.Name))
+		p.after(from)
+	})
+}
+
+// list writes the given expressions separated by commas, between the given
+// delimiters, as items does.
+func (p *printer) list(open, close string, start, end syntax.Position, list []syntax.Expr, trailing bool) {
+	nodes := make([]syntax.Node, len(list))
+	for i, x := range list {
+		nodes[i] = x
+	}
+
+	p.items(open, close, start, end, nodes, trailing, func(i int) {
+		p.expr(list[i])
+	})
+}
+
+// items writes the given nodes, using the given function, between the given
+// delimiters. If any of the nodes was in a different line than the previous
+// one or the delimiters, each node is written in its own line, with a
+// trailing comma if allowed.
+func (p *printer) items(open, close string, start, end syntax.Position, nodes []syntax.Node, trailing bool, fn func(int)) {
+	p.print(open)
+	p.depth++
+	defer func() { p.depth-- }()
+
+	if !isMultiline(start, end, nodes) {
+		for i := range nodes {
+			if i != 0 {
+				p.print(", ")
+			}
+
+			fn(i)
+		}
+
+		p.print(close)
+		return
+	}
+
+	p.newline()
+	p.indent++
+	p.line = 0
+	for i, n := range nodes {
+		p.blank(startLine(n))
+		fn(i)
+		if trailing || i != len(nodes)-1 {
+			p.print(",")
+		}
+
+		p.newline()
+		p.line = int(syntax.End(n).Line)
+	}
+
+	p.between(start, end)
+	p.indent--
+	p.print(close)
+}
+
+// startLine returns the first line of the given node, including the
+// comments preceding it, attached to the node or to its first child.
+func startLine(n syntax.Node) int {
+	start := syntax.Start(n)
+	line := int(start.Line)
+	syntax.Walk(n, func(n syntax.Node) bool {
+		if n == nil || syntax.Start(n) != start {
+			return false
+		}
+
+		if c := n.Comments(); c != nil && len(c.Before) != 0 && int(c.Before[0].Start.Line) < line {
+			line = int(c.Before[0].Start.Line)
+		}
+
+		return true
+	})
+
+	return line
+}
+
+func isMultiline(start, end syntax.Position, nodes []syntax.Node) bool {
+	line := start.Line
+	for _, n := range nodes {
+		from, to := n.Span()
+		if from.Line != line {
+			return true
+		}
+
+		line = to.Line
+	}
+
+	return line != end.Line
+}
+
+func (p *printer) expr(x syntax.Expr) {
+	// the comments in the middle of an expression are only possible between
+	// brackets, where the line breaks are allowed.
+	p.before(x)
+
+	switch x := x.(type) {
+	case *syntax.Ident:
+		p.print(x.Name)
+	case *syntax.Literal:
+		p.print(literal(x))
+	case *syntax.ParenExpr:
+		if tuple, ok := x.X.(*syntax.TupleExpr); ok && tuple.Comments() == nil {
+			p.parenTuple(x, tuple)
+			break
+		}
+
+		p.items("(", ")", x.Lparen, x.Rparen, []syntax.Node{x.X}, false, func(int) {
+			p.expr(x.X)
+		})
+	case *syntax.CallExpr:
+		p.expr(x.Fn)
+		p.list("(", ")", x.Lparen, x.Rparen, p.arguments(x), true)
+	case *syntax.DotExpr:
+		p.expr(x.X)
+		p.print(".", x.Name.Name)
+	case *syntax.IndexExpr:
+		p.expr(x.X)
+		p.print("[")
+		p.depth++
+		p.expr(x.Y)
+		p.depth--
+		p.print("]")
+	case *syntax.SliceExpr:
+		p.expr(x.X)
+		p.print("[")
+		p.depth++
+		p.optional(x.Lo)
+		p.print(":")
+		p.optional(x.Hi)
+		if x.Step != nil {
+			p.print(":")
+			p.expr(x.Step)
+		}
+
+		p.depth--
+		p.print("]")
+	case *syntax.ListExpr:
+		p.list("[", "]", x.Lbrack, x.Rbrack, x.List, true)
+	case *syntax.DictExpr:
+		p.list("{", "}", x.Lbrace, x.Rbrace, x.List, true)
+	case *syntax.DictEntry:
+		p.expr(x.Key)
+		p.print(": ")
+		p.expr(x.Value)
+	case *syntax.TupleExpr:
+		p.tuple(x)
+	case *syntax.Comprehension:
+		p.comprehension(x)
+	case *syntax.CondExpr:
+		p.expr(x.True)
+		p.print(" if ")
+		p.expr(x.Cond)
+		p.print(" else ")
+		p.expr(x.False)
+	case *syntax.LambdaExpr:
+		p.print("lambda")
+		for i, param := range x.Params {
+			if i == 0 {
+				p.print(" ")
+			} else {
+				p.print(", ")
+			}
+
+			p.expr(param)
+		}
+
+		p.print(": ")
+		p.expr(x.Body)
+	case *syntax.UnaryExpr:
+		p.print(x.Op.String())
+		if x.Op == syntax.NOT {
+			p.print(" ")
+		}
+
+		p.optional(x.X)
+	case *syntax.BinaryExpr:
+		p.binary(x)
+	}
+
+	p.after(x)
+}
+
+func (p *printer) binary(x *syntax.BinaryExpr) {
+	p.expr(x.X)
+	if x.Op == syntax.EQ {
+		// keyword argument or parameter with default value
+		p.print("=")
+		p.expr(x.Y)
+		return
+	}
+
+	p.print(" ", x.Op.String())
+	if int(syntax.End(x.X).Line) == startLine(x.Y) {
+		p.print(" ")
+		p.expr(x.Y)
+		return
+	}
+
+	// the line break is kept, using a backslash outside of brackets.
+	if p.depth == 0 {
+		p.print(" \\")
+	}
+
+	p.newline()
+	p.indent++
+	p.expr(x.Y)
+	p.indent--
+}
+
+func (p *printer) optional(x syntax.Expr) {
+	if x != nil {
+		p.expr(x)
+	}
+}
+
+func (p *printer) tuple(x *syntax.TupleExpr) {
+	if !x.Lparen.IsValid() {
+		for i, elem := range x.List {
+			if i != 0 {
+				p.print(", ")
+			}
+
+			p.expr(elem)
+		}
+
+		// only valid between parenthesis, eg.: `(1,)`
+		if len(x.List) == 1 {
+			p.print(",")
+		}
+
+		return
+	}
+
+	if len(x.List) == 1 {
+		p.print("(")
+		p.expr(x.List[0])
+		p.print(",)")
+		return
+	}
+
+	p.list("(", ")", x.Lparen, x.Rparen, x.List, true)
+}
+
+// parenTuple writes a tuple between parenthesis, as a list.
+func (p *printer) parenTuple(x *syntax.ParenExpr, tuple *syntax.TupleExpr) {
+	if len(tuple.List) == 1 && !isMultiline(x.Lparen, x.Rparen, []syntax.Node{tuple.List[0]}) {
+		p.print("(")
+		p.expr(tuple.List[0])
+		p.print(",)")
+		return
+	}
+
+	p.list("(", ")", x.Lparen, x.Rparen, tuple.List, true)
+}
+
+func (p *printer) comprehension(x *syntax.Comprehension) {
+	open, close := "[", "]"
+	if x.Curly {
+		open, close = "{", "}"
+	}
+
+	nodes := append([]syntax.Node{x.Body}, x.Clauses...)
+	multiline := isMultiline(x.Lbrack, x.Rbrack, nodes)
+
+	p.print(open)
+	p.depth++
+	defer func() { p.depth-- }()
+
+	if multiline {
+		p.newline()
+		p.indent++
+	}
+
+	for i, n := range nodes {
+		if i != 0 && !multiline {
+			p.print(" ")
+		}
+
+		switch n := n.(type) {
+		case *syntax.ForClause:
+			p.before(n)
+			p.print("for ")
+			p.expr(n.Vars)
+			p.print(" in ")
+			p.expr(n.X)
+			p.after(n)
+		case *syntax.IfClause:
+			p.before(n)
+			p.print("if ")
+			p.expr(n.Cond)
+			p.after(n)
+		case syntax.Expr:
+			p.expr(n)
+		}
+
+		if multiline {
+			p.newline()
+		}
+	}
+
+	if multiline {
+		p.indent--
+	}
+
+	p.print(close)
+}
+
+// arguments returns the arguments of the given call, sorting the keyword
+// arguments if Options.KeywordOrder is defined.
+func (p *printer) arguments(call *syntax.CallExpr) []syntax.Expr {
+	if p.opts.KeywordOrder == nil {
+		return call.Args
+	}
+
+	order := p.opts.KeywordOrder(call)
+	if order == nil {
+		return call.Args
+	}
+
+	index := make(map[string]int, len(order))
+	for i, name := range order {
+		index[name] = i
+	}
+
+	args := make([]syntax.Expr, len(call.Args))
+	copy(args, call.Args)
+
+	// only the consecutive keyword arguments are sorted, the positional
+	// and the variadic arguments keep its position.
+	for i := 0; i < len(args); {
+		j := i
+		for j < len(args) && keyword(args[j]) != "" {
+			j++
+		}
+
+		sortKeywords(args[i:j], index)
+		i = j + 1
+	}
+
+	return args
+}
+
+func sortKeywords(args []syntax.Expr, index map[string]int) {
+	position := func(x syntax.Expr) int {
+		if i, ok := index[keyword(x)]; ok {
+			return i
+		}
+
+		return len(index)
+	}
+
+	// insertion sort, being stable and the arguments being few.
+	for i := 1; i < len(args); i++ {
+		for j := i; j > 0 && position(args[j]) < position(args[j-1]); j-- {
+			args[j], args[j-1] = args[j-1], args[j]
+		}
+	}
+}
+
+// keyword returns the name of a keyword argument, or an empty string if the
+// given argument is not a keyword argument.
+func keyword(arg syntax.Expr) string {
+	if b, ok := arg.(*syntax.BinaryExpr); ok && b.Op == syntax.EQ {
+		if id, ok := b.X.(*syntax.Ident); ok {
+			return id.Name
+		}
+	}
+
+	return ""
+}
+
+// literal returns the raw literal, replacing the single quotes of the
+// strings by double quotes when no escaping is required.
+func literal(x *syntax.Literal) string {
+	raw := x.Raw
+	if x.Token != syntax.STRING || len(raw) < 2 || raw[0] != '\'' || strings.HasPrefix(raw, "'''") {
+		return raw
+	}
+
+	content := raw[1 : len(raw)-1]
+	if strings.ContainsAny(content, "\"\\") {
+		return raw
+	}
+
+	return `"` + content + `"`
+}
+
+func quote(s string) string {
+	return syntax.Quote(s, false)
+}
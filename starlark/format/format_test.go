@@ -0,0 +1,123 @@
+package format
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/mcuadros/ascode/starlark/analysis"
+	"github.com/mcuadros/ascode/terraform"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/syntax"
+)
+
+func TestSource(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/format.star")
+	assert.NoError(t, err)
+
+	expected, err := ioutil.ReadFile("testdata/format.golden.star")
+	assert.NoError(t, err)
+
+	out, err := Source("format.star", src, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(out))
+
+	out, err = Source("format.golden.star", expected, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(out))
+}
+
+func TestSourceLayout(t *testing.T) {
+	testCases := []struct {
+		src, expected string
+	}{
+		{"x=f( 1,b = 2 )", "x = f(1, b=2)\n"},
+		{"x = f(\n1, 2)", "x = f(\n    1,\n    2,\n)\n"},
+		{"x = f(1,  # one\n2)", "x = f(\n    1,  # one\n    2,\n)\n"},
+		{"x = {'a': [1,\n2]}", "x = {\"a\": [\n    1,\n    2,\n]}\n"},
+		{"x = 'foo\"'", "x = 'foo\"'\n"},
+		{"def f(): return 1", "def f():\n    return 1\n"},
+		{"x = (\n1 + 2)", "x = (\n    1 + 2\n)\n"},
+		{"x, y = 1, 2\nz = (1,)", "x, y = 1, 2\nz = (1,)\n"},
+		{"x = [y for y in z\n if y]", "x = [\n    y\n    for y in z\n    if y\n]\n"},
+		{"x = not a or -b", "x = not a or -b\n"},
+		{"a\n\n\n\nb", "a\n\nb\n"},
+		{"if a:\n  b\nelse:\n  if c:\n    d", "if a:\n    b\nelse:\n    if c:\n        d\n"},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		out, err := Source("test.star", []byte(tc.src), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, string(out), tc.src)
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	_, err := Source("test.star", []byte("x = ("), nil)
+	assert.EqualError(t, err, "test.star:1:6: got end of file, want primary expression")
+}
+
+func TestExpr(t *testing.T) {
+	f, err := syntax.Parse("test.star", "def f(a, b={'foo':[1,2]}, *args, **kw): pass", 0)
+	assert.NoError(t, err)
+
+	params := f.Stmts[0].(*syntax.DefStmt).Params
+	assert.Equal(t, "b={\"foo\": [1, 2]}", Expr(params[1]))
+	assert.Equal(t, "**kw", Expr(params[3]))
+}
+
+func TestSourceKeywordOrder(t *testing.T) {
+	opts := &Options{KeywordOrder: func(call *syntax.CallExpr) []string {
+		if id, ok := call.Fn.(*syntax.Ident); ok && id.Name == "f" {
+			return []string{"a", "b", "c"}
+		}
+
+		return nil
+	}}
+
+	out, err := Source("test.star", []byte("f(1, c=3, foo=4, b=2, a=1, **kw)\ng(c=3, a=1)"), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "f(1, a=1, b=2, c=3, foo=4, **kw)\ng(c=3, a=1)\n", string(out))
+}
+
+func TestSchemaOrder(t *testing.T) {
+	b := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"id":   {Computed: true},
+			"tags": {Optional: true},
+			"name": {Optional: true, Computed: true},
+			"type": {Required: true},
+			"ami":  {Required: true},
+		},
+		BlockTypes: map[string]*configschema.NestedBlock{
+			"timeouts":         {},
+			"ebs_block_device": {},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"ami", "type", "name", "tags", "id", "ebs_block_device", "timeouts",
+	}, SchemaOrder(b))
+}
+
+func TestSchemaKeywordOrder(t *testing.T) {
+	src := "" +
+		"aws = tf.provider(\"aws\", \"2.13.0\", region=\"us-west-2\", profile=\"foo\")\n" +
+		"aws.resource.instance(\"web\", tags={}, instance_type=\"t2.micro\", ami=\"foo\")\n"
+
+	f, err := syntax.Parse("test.star", src, syntax.RetainComments)
+	assert.NoError(t, err)
+
+	pm := &terraform.PluginManager{Path: ".providers"}
+	order, err := SchemaKeywordOrder(f, analysis.NewProviderFunc(pm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ""+
+		"aws = tf.provider(\"aws\", \"2.13.0\", region=\"us-west-2\", profile=\"foo\")\n"+
+		"aws.resource.instance(\"web\", ami=\"foo\", instance_type=\"t2.micro\", tags={})\n",
+		string(File(f, &Options{KeywordOrder: order})),
+	)
+}
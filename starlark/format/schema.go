@@ -0,0 +1,70 @@
+package format
+
+import (
+	"sort"
+
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/mcuadros/ascode/starlark/analysis"
+	"go.starlark.net/syntax"
+)
+
+// SchemaKeywordOrder returns a KeywordOrderFunc sorting the keyword arguments
+// of the calls to `tf.provider` and to the resource collections, resolvable
+// statically, following the order of its schema, as returned by SchemaOrder.
+func SchemaKeywordOrder(f *syntax.File, fn analysis.ProviderFunc) (KeywordOrderFunc, error) {
+	r := analysis.NewResolver(f, fn)
+	orders := make(map[*syntax.CallExpr][]string)
+
+	var err error
+	syntax.Walk(f, func(n syntax.Node) bool {
+		call, ok := n.(*syntax.CallExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+
+		var schema *configschema.Block
+		schema, err = r.CallSchema(call)
+		if schema != nil {
+			orders[call] = SchemaOrder(schema)
+		}
+
+		return err == nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(call *syntax.CallExpr) []string {
+		return orders[call]
+	}, nil
+}
+
+// SchemaOrder returns the names of the arguments of the given block: the
+// required arguments, the optional arguments, the computed arguments and the
+// nested blocks, each group sorted alphabetically.
+func SchemaOrder(b *configschema.Block) []string {
+	var required, optional, computed, blocks []string
+	for name, attr := range b.Attributes {
+		switch {
+		case attr.Required:
+			required = append(required, name)
+		case attr.Optional:
+			optional = append(optional, name)
+		default:
+			computed = append(computed, name)
+		}
+	}
+
+	for name := range b.BlockTypes {
+		blocks = append(blocks, name)
+	}
+
+	var order []string
+	for _, names := range [][]string{required, optional, computed, blocks} {
+		sort.Strings(names)
+		order = append(order, names...)
+	}
+
+	return order
+}
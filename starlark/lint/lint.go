@@ -0,0 +1,367 @@
+// Package lint implements a static linter for the AsCode Starlark files,
+// reporting the mistakes detectable without executing the files, such as
+// unknown resource types or arguments, based on the provider schemas.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/mcuadros/ascode/starlark/analysis"
+	"github.com/mcuadros/ascode/starlark/types"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Rule IDs of the issues reported by the Linter.
+const (
+	UnknownResourceType = "unknown-resource-type"
+	UnknownArgument     = "unknown-argument"
+	ComputedArgument    = "computed-argument"
+	UnusedLoad          = "unused-load"
+)
+
+// Rules are the descriptions of the rules by ID.
+var Rules = map[string]string{
+	UnknownResourceType: "resource or data source type not defined by the provider",
+	UnknownArgument:     "argument or nested block not defined by the schema",
+	ComputedArgument:    "computed only argument being set",
+	UnusedLoad:          "symbol loaded but never used",
+}
+
+// IgnoreDirective is the comment used to suppress the issues of a line, if
+// written at the end of the line, or of the following line, if written in
+// its own line. The rules can be given separated by commas, otherwise all
+// the rules are suppressed. Eg.: `# lint:ignore unused-load`.
+const IgnoreDirective = "lint:ignore"
+
+// Issue is a problem found by the Linter.
+type Issue struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// String returns the issue in the format `<file>:<line>:<col>: <msg> (<rule>)`.
+func (i *Issue) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", i.Filename, i.Line, i.Column, i.Message, i.Rule)
+}
+
+// Linter reports the issues of Starlark files, the providers, resources and
+// its arguments are checked when they can be resolved statically, see
+// analysis.Resolver.
+type Linter struct {
+	provider analysis.ProviderFunc
+}
+
+// NewLinter returns a new Linter, instantiating the providers using the
+// given ProviderFunc.
+func NewLinter(fn analysis.ProviderFunc) *Linter {
+	return &Linter{provider: fn}
+}
+
+// File returns the issues of the given file, sorted by position. The
+// src argument is handled as in syntax.Parse. An error is returned if the
+// file can't be parsed, or a provider can't be instantiated.
+func (l *Linter) File(filename string, src interface{}) ([]*Issue, error) {
+	f, err := syntax.Parse(filename, src, syntax.RetainComments)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checker{
+		resolver: analysis.NewResolver(f, l.provider),
+		ignored:  ignoredRules(f),
+	}
+
+	if err := c.check(f); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(c.issues, func(i, j int) bool {
+		a, b := c.issues[i], c.issues[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+
+		return a.Column < b.Column
+	})
+
+	return c.issues, nil
+}
+
+type checker struct {
+	resolver *analysis.Resolver
+	ignored  map[int][]string
+	issues   []*Issue
+}
+
+func (c *checker) check(f *syntax.File) error {
+	var err error
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if n == nil || err != nil {
+			return false
+		}
+
+		switch n := n.(type) {
+		case *syntax.CallExpr:
+			err = c.checkCall(n)
+		case *syntax.DotExpr:
+			err = c.checkDot(n)
+		case *syntax.AssignStmt:
+			err = c.checkAssign(n)
+		}
+
+		return err == nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	c.checkLoads(f)
+	return nil
+}
+
+// checkCall checks the keyword arguments of the calls to providers, resource
+// collections and nested blocks.
+func (c *checker) checkCall(call *syntax.CallExpr) error {
+	schema, err := c.resolver.CallSchema(call)
+	if err != nil || schema == nil {
+		return err
+	}
+
+	var names []string
+	for name := range schema.Attributes {
+		names = append(names, name)
+	}
+
+	for name := range schema.BlockTypes {
+		names = append(names, name)
+	}
+
+	for _, arg := range call.Args {
+		b, ok := arg.(*syntax.BinaryExpr)
+		if !ok || b.Op != syntax.EQ {
+			continue
+		}
+
+		id := b.X.(*syntax.Ident)
+		if _, ok := schema.BlockTypes[id.Name]; ok {
+			continue
+		}
+
+		attr, ok := schema.Attributes[id.Name]
+		if !ok {
+			c.report(id.NamePos, UnknownArgument, "unexpected argument %q%s", id.Name, suggest(id.Name, names))
+			continue
+		}
+
+		if attr.Computed && !attr.Optional {
+			c.report(id.NamePos, ComputedArgument, "can't set computed %s argument", id.Name)
+		}
+	}
+
+	return nil
+}
+
+// checkDot checks the selection of resource types and the attributes of the
+// resources.
+func (c *checker) checkDot(dot *syntax.DotExpr) error {
+	v, err := c.resolver.Resolve(dot.X)
+	if err != nil || v == nil {
+		return err
+	}
+
+	var kind string
+	switch v.(type) {
+	case *types.ResourceCollectionGroup:
+		kind = UnknownResourceType
+	case *types.Resource, *types.Provider:
+		kind = UnknownArgument
+	default:
+		return nil
+	}
+
+	names := v.(starlark.HasAttrs).AttrNames()
+	for _, name := range names {
+		if name == dot.Name.Name {
+			return nil
+		}
+	}
+
+	if kind == UnknownResourceType {
+		c.report(dot.NamePos, kind, "%s has no type %q%s", v, dot.Name.Name, suggest(dot.Name.Name, names))
+		return nil
+	}
+
+	c.report(dot.NamePos, kind, "%s has no argument %q%s", v, dot.Name.Name, suggest(dot.Name.Name, names))
+	return nil
+}
+
+// checkAssign checks the assignments of computed attributes.
+func (c *checker) checkAssign(assign *syntax.AssignStmt) error {
+	dot, ok := assign.LHS.(*syntax.DotExpr)
+	if !ok {
+		return nil
+	}
+
+	v, err := c.resolver.Resolve(dot.X)
+	if err != nil || v == nil {
+		return err
+	}
+
+	r, ok := v.(interface{ Schema() *configschema.Block })
+	if !ok {
+		return nil
+	}
+
+	attr, ok := r.Schema().Attributes[dot.Name.Name]
+	if ok && attr.Computed && !attr.Optional {
+		c.report(dot.NamePos, ComputedArgument, "can't set computed %s argument", dot.Name.Name)
+	}
+
+	return nil
+}
+
+// checkLoads reports the loaded symbols not used by the file.
+func (c *checker) checkLoads(f *syntax.File) {
+	used := make(map[*syntax.Ident]bool)
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if id, ok := n.(*syntax.Ident); ok {
+			if b, ok := id.Binding.(*resolve.Binding); ok && b.First != nil && b.First != id {
+				used[b.First] = true
+			}
+		}
+
+		return n != nil
+	})
+
+	for _, stmt := range f.Stmts {
+		load, ok := stmt.(*syntax.LoadStmt)
+		if !ok {
+			continue
+		}
+
+		for _, id := range load.To {
+			if !used[id] {
+				c.report(id.NamePos, UnusedLoad, "%q loaded from %q is never used", id.Name, load.ModuleName())
+			}
+		}
+	}
+}
+
+func (c *checker) report(pos syntax.Position, rule, format string, args ...interface{}) {
+	for _, ignored := range c.ignored[int(pos.Line)] {
+		if ignored == "" || ignored == rule {
+			return
+		}
+	}
+
+	c.issues = append(c.issues, &Issue{
+		Filename: pos.Filename(),
+		Line:     int(pos.Line),
+		Column:   int(pos.Col),
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// ignoredRules returns the rules ignored by line, an empty rule means all
+// the rules are ignored.
+func ignoredRules(f *syntax.File) map[int][]string {
+	ignored := make(map[int][]string)
+	add := func(comments []syntax.Comment, next bool) {
+		for _, c := range comments {
+			text := strings.TrimSpace(strings.TrimPrefix(c.Text, "#"))
+			if !strings.HasPrefix(text, IgnoreDirective) {
+				continue
+			}
+
+			line := int(c.Start.Line)
+			if next {
+				line++
+			}
+
+			rules := strings.TrimSpace(strings.TrimPrefix(text, IgnoreDirective))
+			if rules == "" {
+				ignored[line] = append(ignored[line], "")
+				continue
+			}
+
+			for _, rule := range strings.Split(rules, ",") {
+				ignored[line] = append(ignored[line], strings.TrimSpace(rule))
+			}
+		}
+	}
+
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if n == nil {
+			return false
+		}
+
+		if c := n.Comments(); c != nil {
+			add(c.Before, true)
+			add(c.Suffix, false)
+			add(c.After, true)
+		}
+
+		return true
+	})
+
+	return ignored
+}
+
+// suggest returns a suggestion, for an unknown name, of the closest name
+// from the given candidates.
+func suggest(name string, candidates []string) string {
+	best, distance := "", len(name)/3+1
+	for _, candidate := range candidates {
+		if d := levenshtein(name, candidate); d < distance || (d == distance && candidate < best) {
+			best, distance = candidate, d
+		}
+	}
+
+	if best == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+func levenshtein(a, b string) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current := row[j]
+			row[j] = min(min(row[j]+1, row[j-1]+1), prev+cost)
+			prev = current
+		}
+	}
+
+	return row[len(b)]
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
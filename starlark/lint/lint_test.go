@@ -0,0 +1,77 @@
+package lint
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mcuadros/ascode/starlark/analysis"
+	"github.com/mcuadros/ascode/starlark/types"
+	"github.com/mcuadros/ascode/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func noProviders(typ, version string) (*types.Provider, error) {
+	return nil, fmt.Errorf("not available")
+}
+
+func TestLinterFile(t *testing.T) {
+	pm := &terraform.PluginManager{Path: ".providers"}
+	issues, err := NewLinter(analysis.NewProviderFunc(pm)).File("testdata/lint.star", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []string
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+
+	assert.Equal(t, []string{
+		`testdata/lint.star:1:34: "subnet" loaded from "network.star" is never used (unused-load)`,
+		`testdata/lint.star:3:36: unexpected argument "regoin", did you mean "region"? (unknown-argument)`,
+		`testdata/lint.star:9:36: unexpected argument "instace_type", did you mean "instance_type"? (unknown-argument)`,
+		`testdata/lint.star:9:61: can't set computed arn argument (computed-argument)`,
+		`testdata/lint.star:11:5: can't set computed public_ip argument (computed-argument)`,
+		`testdata/lint.star:12:46: unexpected argument "volume_sise", did you mean "volume_size"? (unknown-argument)`,
+		`testdata/lint.star:14:19: ResourceCollectionGroup<aws.resource> has no type "db_instanse", did you mean "db_instance"? (unknown-resource-type)`,
+	}, messages)
+}
+
+func TestLinterFileUnusedLoad(t *testing.T) {
+	src := "" +
+		"load(\"a.star\", \"a\", \"b\", c=\"d\")\n" +
+		"load(\"e.star\", \"e\")  # lint:ignore unused-load\n" +
+		"# lint:ignore\n" +
+		"load(\"f.star\", \"f\")\n" +
+		"load(\"g.star\", \"g\")  # lint:ignore unknown-argument\n" +
+		"def foo():\n" +
+		"    return a\n" +
+		"print(c)\n"
+
+	issues, err := NewLinter(noProviders).File("test.star", src)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Issue{{
+		Filename: "test.star", Line: 1, Column: 22,
+		Rule: UnusedLoad, Message: `"b" loaded from "a.star" is never used`,
+	}, {
+		Filename: "test.star", Line: 5, Column: 17,
+		Rule: UnusedLoad, Message: `"g" loaded from "g.star" is never used`,
+	}}, issues)
+}
+
+func TestLinterFileProviderError(t *testing.T) {
+	_, err := NewLinter(noProviders).File("test.star", "tf.provider(\"aws\").resource.instance()")
+	assert.EqualError(t, err, `test.star:1:12: unable to load provider "aws": not available`)
+}
+
+func TestLinterFileSyntaxError(t *testing.T) {
+	_, err := NewLinter(noProviders).File("test.star", "x = (")
+	assert.EqualError(t, err, "test.star:1:6: got end of file, want primary expression")
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"instance_type", "instance_state", "ami"}
+	assert.Equal(t, `, did you mean "instance_type"?`, suggest("instace_type", candidates))
+	assert.Equal(t, `, did you mean "ami"?`, suggest("amj", candidates))
+	assert.Equal(t, "", suggest("foo", candidates))
+}
@@ -0,0 +1,659 @@
+package lsp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/mcuadros/ascode/starlark/sandbox"
+	"github.com/mcuadros/ascode/starlark/types"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// document is a Starlark file opened by the client.
+type document struct {
+	URI         string
+	Filename    string
+	Text        string
+	Diagnostics []Diagnostic
+
+	file        *syntax.File
+	globals     starlark.StringDict
+	loads       starlark.StringDict
+	predeclared starlark.StringDict
+}
+
+// Analyze executes the given text of the document, using a new Runtime,
+// computing the diagnostics and the values of its globals. If the text can't
+// be parsed, the values from the previous execution are kept, since most of
+// the time the document is being edited.
+//
+// Since the document is executed on every change, the Runtime is sandboxed
+// without any capability, and the remote modules are only read from the cache.
+func (d *document) Analyze(text string, fn RuntimeFunc) error {
+	d.Text = text
+
+	r, err := fn()
+	if err != nil {
+		return err
+	}
+
+	r.Permissions = sandbox.Permissions{}
+	r.Cache.Offline = true
+
+	globals, err := r.ExecSource(d.Filename, text)
+	d.Diagnostics = nil
+	if err != nil {
+		d.Diagnostics = d.errorDiagnostics(err)
+	} else {
+		for _, e := range r.Terraform.Validate() {
+			pos, ok := d.callStackPos(e.CallStack)
+			if !ok {
+				continue
+			}
+
+			d.Diagnostics = append(d.Diagnostics, d.diagnostic(pos, SeverityError, e.Msg))
+		}
+	}
+
+	d.predeclared = r.Predeclared()
+	if globals != nil {
+		d.globals = globals
+	}
+
+	f, err := syntax.Parse(d.Filename, text, 0)
+	if err != nil {
+		return nil
+	}
+
+	d.file = f
+	d.loads = make(starlark.StringDict)
+	for _, stmt := range f.Stmts {
+		load, ok := stmt.(*syntax.LoadStmt)
+		if !ok {
+			continue
+		}
+
+		module, err := r.Load(load.ModuleName())
+		if err != nil {
+			continue
+		}
+
+		for i, to := range load.To {
+			if v, ok := module[load.From[i].Name]; ok {
+				d.loads[to.Name] = v
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *document) errorDiagnostics(err error) []Diagnostic {
+	switch e := err.(type) {
+	case syntax.Error:
+		return []Diagnostic{d.diagnostic(e.Pos, SeverityError, e.Msg)}
+	case resolve.ErrorList:
+		diags := make([]Diagnostic, len(e))
+		for i, err := range e {
+			diags[i] = d.diagnostic(err.Pos, SeverityError, err.Msg)
+		}
+
+		return diags
+	case *starlark.EvalError:
+		if pos, ok := d.callStackPos(e.CallStack); ok {
+			return []Diagnostic{d.diagnostic(pos, SeverityError, e.Msg)}
+		}
+	}
+
+	return []Diagnostic{d.diagnostic(syntax.MakePosition(nil, 1, 1), SeverityError, err.Error())}
+}
+
+// callStackPos returns the position of the innermost frame of the given call
+// stack belonging to the document, if any.
+func (d *document) callStackPos(cs starlark.CallStack) (syntax.Position, bool) {
+	for i := 0; i < len(cs); i++ {
+		if pos := cs.At(i).Pos; pos.Filename() == d.Filename {
+			return pos, true
+		}
+	}
+
+	return syntax.Position{}, false
+}
+
+func (d *document) diagnostic(pos syntax.Position, severity int, msg string) Diagnostic {
+	start := Position{Line: int(pos.Line) - 1, Character: int(pos.Col) - 1}
+	if start.Line < 0 {
+		start = Position{}
+	}
+
+	end := start
+	line := d.line(start.Line)
+	for end.Character < len(line) && isIdentifier(line[end.Character]) {
+		end.Character++
+	}
+
+	if end == start {
+		end.Character++
+	}
+
+	return Diagnostic{
+		Range:    Range{Start: utf16Position(line, start), End: utf16Position(line, end)},
+		Severity: severity,
+		Source:   "ascode",
+		Message:  msg,
+	}
+}
+
+// Complete returns the completion items for the given position. The
+// attributes of values, such as providers or resources, are completed after
+// a dot, and the arguments of a resource inside of its constructor call.
+func (d *document) Complete(p Position) []CompletionItem {
+	p = d.runePosition(p)
+	expr := dottedSuffix(d.prefix(p))
+	parts := strings.Split(expr, ".")
+	partial := parts[len(parts)-1]
+
+	if len(parts) > 1 {
+		v, ok := d.resolve(parts[:len(parts)-1])
+		if !ok {
+			return nil
+		}
+
+		return attrItems(v, partial)
+	}
+
+	var items []CompletionItem
+	if callee := openCall(d.textBefore(p)); callee != "" {
+		v, ok := d.resolve(strings.Split(callee, "."))
+		if c, isCollection := v.(*types.ResourceCollection); ok && isCollection {
+			items = append(items, argumentItems(c.Schema(), partial)...)
+		}
+	}
+
+	return append(items, d.nameItems(partial)...)
+}
+
+// Hover returns the description of the expression at the given position,
+// for the arguments of a resource the schema documentation is returned.
+func (d *document) Hover(p Position) *Hover {
+	p = d.runePosition(p)
+	expr := d.expressionAt(p)
+	if expr == "" {
+		return nil
+	}
+
+	parts := strings.Split(expr, ".")
+	if len(parts) > 1 {
+		parent, ok := d.resolve(parts[:len(parts)-1])
+		if schema := schemaOf(parent); ok && schema != nil {
+			if doc := schemaDoc(schema, parts[len(parts)-1]); doc != "" {
+				return &Hover{Contents: MarkupContent{Kind: "markdown", Value: doc}}
+			}
+		}
+	}
+
+	v, ok := d.resolve(parts)
+	if !ok {
+		return nil
+	}
+
+	lines := []string{fmt.Sprintf("%s: %s", expr, v.Type())}
+	switch v.(type) {
+	case *types.ResourceCollection, *types.ResourceCollectionGroup, *types.Provider, *types.Resource:
+		lines = append(lines, v.String())
+	}
+
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: markdownCode(lines...)}}
+}
+
+// Definition returns the location where the name at the given position is
+// defined, following the `load` statements to local files.
+func (d *document) Definition(p Position) *Location {
+	if d.file == nil {
+		return nil
+	}
+
+	p = d.runePosition(p)
+	name := d.identifierAt(p)
+	for _, stmt := range d.file.Stmts {
+		load, ok := stmt.(*syntax.LoadStmt)
+		if !ok {
+			continue
+		}
+
+		filename := filepath.Join(filepath.Dir(d.Filename), load.ModuleName())
+		if _, err := os.Stat(filename); err != nil {
+			continue
+		}
+
+		start, end := load.Module.Span()
+		if contains(start, end, p) {
+			return &Location{URI: uriFromFilename(filename)}
+		}
+
+		for i, to := range load.To {
+			if to.Name == name {
+				return definitionAt(filename, load.From[i].Name)
+			}
+		}
+	}
+
+	if pos, ok := findDefinition(d.file, name); ok {
+		return &Location{URI: d.URI, Range: rangeAt(d.Text, pos, name)}
+	}
+
+	return nil
+}
+
+// resolve returns the value of the given dotted expression, eg.:
+// `aws.resource.instance`, looking for the first name at the globals, the
+// loaded names, the predeclared and the universe.
+func (d *document) resolve(parts []string) (starlark.Value, bool) {
+	v, ok := d.lookup(parts[0])
+	if !ok {
+		return nil, false
+	}
+
+	for _, name := range parts[1:] {
+		attrs, ok := v.(starlark.HasAttrs)
+		if !ok {
+			return nil, false
+		}
+
+		var err error
+		v, err = attrs.Attr(name)
+		if err != nil || v == nil || v == starlark.None {
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+func (d *document) lookup(name string) (starlark.Value, bool) {
+	for _, dict := range []starlark.StringDict{d.globals, d.loads, d.predeclared, starlark.Universe} {
+		if v, ok := dict[name]; ok {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+func (d *document) nameItems(partial string) []CompletionItem {
+	seen := make(map[string]bool)
+
+	var items []CompletionItem
+	for _, dict := range []starlark.StringDict{d.globals, d.loads, d.predeclared, starlark.Universe} {
+		for _, name := range dict.Keys() {
+			if seen[name] || !strings.HasPrefix(name, partial) {
+				continue
+			}
+
+			seen[name] = true
+			items = append(items, valueItem(name, dict[name]))
+		}
+	}
+
+	return items
+}
+
+func (d *document) line(n int) []rune {
+	return lineAt(d.Text, n)
+}
+
+func lineAt(text string, n int) []rune {
+	lines := strings.Split(text, "\n")
+	if n < 0 || n >= len(lines) {
+		return nil
+	}
+
+	return []rune(lines[n])
+}
+
+// runePosition returns the given position, received from the client with
+// the character in UTF-16 code units, with the character in runes, as the
+// columns of the syntax.Position.
+func (d *document) runePosition(p Position) Position {
+	line := d.line(p.Line)
+
+	var i, units int
+	for i < len(line) && units < p.Character {
+		units += utf16Len(line[i])
+		i++
+	}
+
+	return Position{Line: p.Line, Character: i + p.Character - units}
+}
+
+// utf16Position returns the given position, of the given line, with the
+// character in runes, as a position sent to the client, with the character in
+// UTF-16 code units.
+func utf16Position(line []rune, p Position) Position {
+	var units int
+	for i := 0; i < p.Character; i++ {
+		if i >= len(line) {
+			units += p.Character - i
+			break
+		}
+
+		units += utf16Len(line[i])
+	}
+
+	return Position{Line: p.Line, Character: units}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
+
+// prefix returns the text of the line before the given position.
+func (d *document) prefix(p Position) string {
+	line := d.line(p.Line)
+	if p.Character > len(line) {
+		return string(line)
+	}
+
+	return string(line[:p.Character])
+}
+
+// textBefore returns the text of the document before the given position.
+func (d *document) textBefore(p Position) string {
+	lines := strings.Split(d.Text, "\n")
+	if p.Line >= len(lines) {
+		return d.Text
+	}
+
+	return strings.Join(lines[:p.Line], "\n") + "\n" + d.prefix(p)
+}
+
+// expressionAt returns the dotted expression ending at the identifier at the
+// given position, eg.: `aws.resource` for `resource` in `aws.resource.foo`.
+func (d *document) expressionAt(p Position) string {
+	line := d.line(p.Line)
+	if p.Character > len(line) {
+		return ""
+	}
+
+	end := p.Character
+	for end < len(line) && isIdentifier(line[end]) {
+		end++
+	}
+
+	return dottedSuffix(string(line[:end]))
+}
+
+func (d *document) identifierAt(p Position) string {
+	parts := strings.Split(d.expressionAt(p), ".")
+	return parts[len(parts)-1]
+}
+
+func attrItems(v starlark.Value, partial string) []CompletionItem {
+	attrs, ok := v.(starlark.HasAttrs)
+	if !ok {
+		return nil
+	}
+
+	schema := schemaOf(v)
+	names := attrs.AttrNames()
+	sort.Strings(names)
+
+	var items []CompletionItem
+	for _, name := range names {
+		if !strings.HasPrefix(name, partial) {
+			continue
+		}
+
+		item := CompletionItem{Label: name, Kind: CompletionField}
+		if schema != nil {
+			if attr, ok := schema.Attributes[name]; ok {
+				item.Kind = CompletionProperty
+				item.Detail = attributeDetail(attr)
+				item.Documentation = attr.Description
+			} else if _, ok := schema.BlockTypes[name]; ok {
+				item.Detail = "block"
+			} else {
+				item.Kind = CompletionMethod
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func argumentItems(schema *configschema.Block, partial string) []CompletionItem {
+	var items []CompletionItem
+	for _, name := range sortedKeys(schema) {
+		if !strings.HasPrefix(name, partial) {
+			continue
+		}
+
+		item := CompletionItem{Label: name, Kind: CompletionField, Detail: "block", InsertText: name + "="}
+		if attr, ok := schema.Attributes[name]; ok {
+			if attr.Computed && !attr.Optional {
+				continue
+			}
+
+			item.Kind = CompletionProperty
+			item.Detail = attributeDetail(attr)
+			item.Documentation = attr.Description
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func valueItem(name string, v starlark.Value) CompletionItem {
+	item := CompletionItem{Label: name, Kind: CompletionVariable, Detail: v.Type()}
+	switch v.(type) {
+	case starlark.Callable:
+		item.Kind = CompletionMethod
+	case starlark.HasAttrs:
+		item.Kind = CompletionModule
+	}
+
+	return item
+}
+
+// schemaOf returns the schema of the given value if is a resource, or any
+// other value embedding a resource, such as a provider or a backend.
+func schemaOf(v starlark.Value) *configschema.Block {
+	if _, ok := v.(*types.ResourceCollection); ok {
+		return nil
+	}
+
+	if r, ok := v.(interface{ Schema() *configschema.Block }); ok {
+		return r.Schema()
+	}
+
+	return nil
+}
+
+func schemaDoc(schema *configschema.Block, name string) string {
+	if attr, ok := schema.Attributes[name]; ok {
+		doc := markdownCode(fmt.Sprintf("%s %s", name, attributeDetail(attr)))
+		if attr.Description != "" {
+			doc += "\n\n" + attr.Description
+		}
+
+		return doc
+	}
+
+	if block, ok := schema.BlockTypes[name]; ok {
+		return markdownCode(fmt.Sprintf("%s block (%s)", name, block.Nesting))
+	}
+
+	return ""
+}
+
+func attributeDetail(attr *configschema.Attribute) string {
+	var flags []string
+	switch {
+	case attr.Required:
+		flags = append(flags, "required")
+	case attr.Optional:
+		flags = append(flags, "optional")
+	}
+
+	if attr.Computed {
+		flags = append(flags, "computed")
+	}
+
+	return fmt.Sprintf("%s (%s)", attr.Type.FriendlyName(), strings.Join(flags, ", "))
+}
+
+func sortedKeys(schema *configschema.Block) []string {
+	var names []string
+	for name := range schema.Attributes {
+		names = append(names, name)
+	}
+
+	for name := range schema.BlockTypes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// definitionAt returns the location of the given name at the given file.
+func definitionAt(filename, name string) *Location {
+	l := &Location{URI: uriFromFilename(filename)}
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return l
+	}
+
+	f, err := syntax.Parse(filename, src, 0)
+	if err != nil {
+		return l
+	}
+
+	if pos, ok := findDefinition(f, name); ok {
+		l.Range = rangeAt(string(src), pos, name)
+	}
+
+	return l
+}
+
+// findDefinition returns the position of the top-level function or
+// assignment defining the given name.
+func findDefinition(f *syntax.File, name string) (syntax.Position, bool) {
+	for _, stmt := range f.Stmts {
+		switch s := stmt.(type) {
+		case *syntax.DefStmt:
+			if s.Name.Name == name {
+				return s.Name.NamePos, true
+			}
+		case *syntax.AssignStmt:
+			if id, ok := s.LHS.(*syntax.Ident); ok && id.Name == name {
+				return id.NamePos, true
+			}
+		}
+	}
+
+	return syntax.Position{}, false
+}
+
+// rangeAt returns the range of the given name, at the given position of the
+// given text, with the characters in UTF-16 code units.
+func rangeAt(text string, pos syntax.Position, name string) Range {
+	start := Position{Line: int(pos.Line) - 1, Character: int(pos.Col) - 1}
+	end := Position{Line: start.Line, Character: start.Character + len([]rune(name))}
+
+	line := lineAt(text, start.Line)
+	return Range{Start: utf16Position(line, start), End: utf16Position(line, end)}
+}
+
+func contains(start, end syntax.Position, p Position) bool {
+	line, col := int32(p.Line+1), int32(p.Character+1)
+	if line < start.Line || line > end.Line {
+		return false
+	}
+
+	if line == start.Line && col < start.Col {
+		return false
+	}
+
+	return line != end.Line || col <= end.Col
+}
+
+// dottedSuffix returns the dotted expression at the end of the given text.
+func dottedSuffix(text string) string {
+	runes := []rune(text)
+
+	i := len(runes)
+	for i > 0 && (isIdentifier(runes[i-1]) || runes[i-1] == '.') {
+		i--
+	}
+
+	return strings.TrimLeft(string(runes[i:]), ".")
+}
+
+// openCall returns the callee of the innermost call not closed at the end
+// of the given source, ignoring the strings and comments.
+func openCall(src string) string {
+	type open struct {
+		char byte
+		pos  int
+	}
+
+	var stack []open
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case '"', '\'':
+			quote := string(c)
+			if strings.HasPrefix(src[i:], strings.Repeat(quote, 3)) {
+				end := strings.Index(src[i+3:], strings.Repeat(quote, 3))
+				if end == -1 {
+					return ""
+				}
+
+				i += end + 5
+				continue
+			}
+
+			for i++; i < len(src) && src[i] != c && src[i] != '\n'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[', '{':
+			stack = append(stack, open{c, i})
+		case ')', ']', '}':
+			if len(stack) != 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	if len(stack) == 0 || stack[len(stack)-1].char != '(' {
+		return ""
+	}
+
+	return dottedSuffix(src[:stack[len(stack)-1].pos])
+}
+
+func isIdentifier(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,93 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/mcuadros/ascode/starlark/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+	"go.starlark.net/syntax"
+)
+
+func TestDottedSuffix(t *testing.T) {
+	assert.Equal(t, "aws.resource.inst", dottedSuffix("i = aws.resource.inst"))
+	assert.Equal(t, "aws.", dottedSuffix("foo(aws."))
+	assert.Equal(t, "", dottedSuffix("foo("))
+}
+
+func TestOpenCall(t *testing.T) {
+	for src, expected := range map[string]string{
+		`aws.resource.instance("web", ami="foo", `:      "aws.resource.instance",
+		`aws.resource.instance("web(", tags={"a": 1}, `: "aws.resource.instance",
+		`aws.resource.instance("web", tags={"a": `:      "",
+		`foo(bar(1), `:   "foo",
+		`foo(bar(1))`:    "",
+		"foo(# )\n":      "foo",
+		`foo("""a)""", `: "foo",
+	} {
+		assert.Equal(t, expected, openCall(src), src)
+	}
+}
+
+func TestDocumentUTF16(t *testing.T) {
+	d := &document{Text: "x = \"\U0001F600\" + foo.bar\n"}
+
+	diag := d.diagnostic(syntax.MakePosition(nil, 1, 11), SeverityError, "undefined: foo")
+	assert.Equal(t, Range{Start: Position{0, 11}, End: Position{0, 14}}, diag.Range)
+
+	assert.Equal(t, Position{0, 10}, d.runePosition(Position{0, 11}))
+	assert.Equal(t, "foo.bar", d.expressionAt(d.runePosition(Position{0, 15})))
+	assert.Equal(t, Position{0, 20}, d.runePosition(Position{0, 21}))
+	assert.Equal(t, Position{0, 21}, utf16Position(d.line(0), Position{0, 20}))
+}
+
+func TestArgumentItems(t *testing.T) {
+	schema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"ami":           {Type: cty.String, Required: true, Description: "AMI to use"},
+			"arn":           {Type: cty.String, Computed: true},
+			"associate_ip":  {Type: cty.Bool, Optional: true, Computed: true},
+			"instance_type": {Type: cty.String, Required: true},
+		},
+		BlockTypes: map[string]*configschema.NestedBlock{
+			"ebs_block_device": {Nesting: configschema.NestingSet},
+		},
+	}
+
+	assert.Equal(t, []CompletionItem{{
+		Label: "ami", Kind: CompletionProperty, Detail: "string (required)",
+		Documentation: "AMI to use", InsertText: "ami=",
+	}, {
+		Label: "associate_ip", Kind: CompletionProperty, Detail: "bool (optional, computed)",
+		InsertText: "associate_ip=",
+	}}, argumentItems(schema, "a"))
+
+	assert.Equal(t, []CompletionItem{{
+		Label: "ebs_block_device", Kind: CompletionField, Detail: "block", InsertText: "ebs_block_device=",
+	}}, argumentItems(schema, "e"))
+
+	assert.Contains(t, schemaDoc(schema, "ami"), "ami string (required)")
+	assert.Contains(t, schemaDoc(schema, "ebs_block_device"), "ebs_block_device block (NestingSet)")
+}
+
+func TestAnalyzeSandboxed(t *testing.T) {
+	d := &document{Filename: "main.star"}
+	err := d.Analyze(`load("https://example.com/lib.star", "foo")`, func() (*runtime.Runtime, error) {
+		return runtime.NewRuntime(nil), nil
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, d.Diagnostics, 1)
+	assert.Contains(t, d.Diagnostics[0].Message, "unable to fetch in offline mode")
+
+	err = d.Analyze("load(\"os\", \"os\")\nos.command(\"true\")", func() (*runtime.Runtime, error) {
+		r := runtime.NewRuntime(nil)
+		r.Permissions.Exec = true
+		return r, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, d.Diagnostics, 1)
+	assert.Contains(t, d.Diagnostics[0].Message, "missing capability")
+}
@@ -0,0 +1,223 @@
+// Package lsp implements a Language Server Protocol server for the AsCode
+// Starlark files, providing completion, hover, diagnostics and go to
+// definition, based on the schemas of the providers used by the files.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/mcuadros/ascode/starlark/runtime"
+)
+
+// RuntimeFunc returns a new Runtime used to execute a document.
+type RuntimeFunc func() (*runtime.Runtime, error)
+
+// Server is a Language Server Protocol server, communicating using JSON-RPC.
+// Every document is executed when opened or changed, the resulting values
+// are used to complete and describe the expressions of the document.
+type Server struct {
+	newRuntime RuntimeFunc
+	documents  map[string]*document
+	out        io.Writer
+}
+
+// NewServer returns a new Server executing the documents with the runtimes
+// returned by the given RuntimeFunc.
+func NewServer(fn RuntimeFunc) *Server {
+	return &Server{
+		newRuntime: fn,
+		documents:  make(map[string]*document),
+	}
+}
+
+// Serve reads the requests from the given reader, and writes the responses
+// to the given writer, until the `exit` notification is received or the
+// reader is closed.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.out = w
+
+	in := bufio.NewReader(r)
+	for {
+		m, err := readMessage(in)
+		if err == io.EOF {
+			return nil
+		}
+
+		if rerr, ok := err.(*responseError); ok {
+			if err := s.reply(nil, nil, rerr); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if m.Method == "exit" {
+			return nil
+		}
+
+		result, err := s.handle(m)
+		if m.ID == nil {
+			continue
+		}
+
+		if err := s.reply(m.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(m *message) (interface{}, error) {
+	switch m.Method {
+	case "initialize":
+		return s.initialize()
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var p didOpenParams
+		return nil, s.unmarshal(m, &p, func() error {
+			return s.open(p.TextDocument.URI, p.TextDocument.Text)
+		})
+	case "textDocument/didChange":
+		var p didChangeParams
+		return nil, s.unmarshal(m, &p, func() error {
+			if len(p.ContentChanges) == 0 {
+				return nil
+			}
+
+			return s.open(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+		})
+	case "textDocument/didClose":
+		var p didCloseParams
+		return nil, s.unmarshal(m, &p, func() error {
+			delete(s.documents, p.TextDocument.URI)
+			return s.publishDiagnostics(p.TextDocument.URI, nil)
+		})
+	case "textDocument/completion":
+		var p positionParams
+		var items []CompletionItem
+		return &items, s.unmarshal(m, &p, func() error {
+			if d, ok := s.documents[p.TextDocument.URI]; ok {
+				items = d.Complete(p.Position)
+			}
+
+			return nil
+		})
+	case "textDocument/hover":
+		var p positionParams
+		var hover *Hover
+		return &hover, s.unmarshal(m, &p, func() error {
+			if d, ok := s.documents[p.TextDocument.URI]; ok {
+				hover = d.Hover(p.Position)
+			}
+
+			return nil
+		})
+	case "textDocument/definition":
+		var p positionParams
+		var location *Location
+		return &location, s.unmarshal(m, &p, func() error {
+			if d, ok := s.documents[p.TextDocument.URI]; ok {
+				location = d.Definition(p.Position)
+			}
+
+			return nil
+		})
+	}
+
+	if m.ID == nil {
+		// unknown notifications, such as `initialized`, are ignored.
+		return nil, nil
+	}
+
+	return nil, &responseError{Code: methodNotFound, Message: "method not found: " + m.Method}
+}
+
+func (s *Server) initialize() (interface{}, error) {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync": 1, // full document sync
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{"."},
+			},
+			"hoverProvider":      true,
+			"definitionProvider": true,
+		},
+		"serverInfo": map[string]string{"name": "ascode"},
+	}, nil
+}
+
+func (s *Server) unmarshal(m *message, v interface{}, fn func() error) error {
+	if err := json.Unmarshal(m.Params, v); err != nil {
+		return &responseError{Code: invalidParams, Message: err.Error()}
+	}
+
+	return fn()
+}
+
+// open executes the given document and publishes its diagnostics.
+func (s *Server) open(uri, text string) error {
+	filename, err := filenameFromURI(uri)
+	if err != nil {
+		return err
+	}
+
+	d, ok := s.documents[uri]
+	if !ok {
+		d = &document{URI: uri, Filename: filename}
+		s.documents[uri] = d
+	}
+
+	if err := d.Analyze(text, s.newRuntime); err != nil {
+		return err
+	}
+
+	return s.publishDiagnostics(uri, d.Diagnostics)
+}
+
+func (s *Server) publishDiagnostics(uri string, diags []Diagnostic) error {
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+
+	params, err := json.Marshal(&publishDiagnosticsParams{URI: uri, Diagnostics: diags})
+	if err != nil {
+		return err
+	}
+
+	return writeMessage(s.out, &message{
+		Method: "textDocument/publishDiagnostics",
+		Params: params,
+	})
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, err error) error {
+	m := &message{ID: id}
+	if id == nil {
+		null := json.RawMessage("null")
+		m.ID = &null
+	}
+
+	if err != nil {
+		rerr, ok := err.(*responseError)
+		if !ok {
+			rerr = &responseError{Code: internalError, Message: err.Error()}
+		}
+
+		m.Error = rerr
+		return writeMessage(s.out, m)
+	}
+
+	content, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	m.Result = content
+	return writeMessage(s.out, m)
+}
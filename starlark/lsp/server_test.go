@@ -0,0 +1,208 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mcuadros/ascode/starlark/runtime"
+	"github.com/mcuadros/ascode/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+const testLib = `def helper(name):
+    return "helper-" + name
+`
+
+const testMain = `load("lib.star", "helper")
+
+b = backend("gcs")
+b.bucket = helper("state")
+b.bu
+`
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ascode-lsp")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lib.star"), []byte(testLib), 0644))
+	uri := uriFromFilename(filepath.Join(dir, "main.star"))
+
+	c := newTestClient(t, dir)
+	defer c.Close()
+
+	var init struct {
+		Capabilities struct {
+			HoverProvider bool `json:"hoverProvider"`
+		} `json:"capabilities"`
+	}
+
+	c.Call("initialize", map[string]interface{}{}, &init)
+	assert.True(t, init.Capabilities.HoverProvider)
+
+	c.Notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "text": testMain},
+	})
+
+	diags := c.Diagnostics()
+	assert.Len(t, diags, 1)
+	assert.Equal(t, Range{Start: Position{4, 1}, End: Position{4, 2}}, diags[0].Range)
+	assert.Contains(t, diags[0].Message, "bu")
+
+	var items []CompletionItem
+	c.Call("textDocument/completion", positionAt(uri, 4, 4), &items)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "bucket", items[0].Label)
+	assert.Equal(t, "string (required)", items[0].Detail)
+
+	c.Call("textDocument/completion", positionAt(uri, 3, 14), &items)
+	assert.Equal(t, "helper", items[0].Label)
+
+	var hover *Hover
+	c.Call("textDocument/hover", positionAt(uri, 3, 4), &hover)
+	assert.Contains(t, hover.Contents.Value, "bucket string (required)")
+
+	var location *Location
+	c.Call("textDocument/definition", positionAt(uri, 3, 14), &location)
+	assert.Equal(t, uriFromFilename(filepath.Join(dir, "lib.star")), location.URI)
+	assert.Equal(t, Range{Start: Position{0, 4}, End: Position{0, 10}}, location.Range)
+
+	c.Call("textDocument/definition", positionAt(uri, 3, 1), &location)
+	assert.Equal(t, uri, location.URI)
+	assert.Equal(t, Range{Start: Position{2, 0}, End: Position{2, 1}}, location.Range)
+
+	c.Notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri},
+		"contentChanges": []map[string]interface{}{{"text": testMain + "b.\n"}},
+	})
+
+	diags = c.Diagnostics()
+	assert.Len(t, diags, 1)
+	assert.Equal(t, "not an identifier", diags[0].Message)
+
+	// the values of the last execution are used while the document is invalid
+	c.Call("textDocument/completion", positionAt(uri, 5, 2), &items)
+	assert.NotEmpty(t, items)
+
+	var result interface{}
+	err = c.call("foo", nil, &result)
+	assert.EqualError(t, err, "method not found: foo")
+}
+
+func positionAt(uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     Position{Line: line, Character: character},
+	}
+}
+
+type testClient struct {
+	t      *testing.T
+	in     chan *message
+	out    *bufio.Reader
+	done   chan error
+	nextID int
+	diags  [][]Diagnostic
+}
+
+func newTestClient(t *testing.T, dir string) *testClient {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	c := &testClient{t: t, in: make(chan *message, 16), out: bufio.NewReader(outR), done: make(chan error)}
+
+	// messages are written in order, without blocking the reads.
+	go func() {
+		for m := range c.in {
+			assert.NoError(t, writeMessage(inW, m))
+		}
+
+		inW.Close()
+	}()
+
+	s := NewServer(func() (*runtime.Runtime, error) {
+		return runtime.NewRuntime(&terraform.PluginManager{Path: dir}), nil
+	})
+
+	go func() {
+		c.done <- s.Serve(inR, outW)
+		outW.Close()
+	}()
+
+	return c
+}
+
+func (c *testClient) Call(method string, params, result interface{}) {
+	assert.NoError(c.t, c.call(method, params, result))
+}
+
+func (c *testClient) call(method string, params, result interface{}) error {
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	c.write(&message{ID: &id, Method: method, Params: c.marshal(params)})
+
+	for {
+		m, err := readMessage(c.out)
+		assert.NoError(c.t, err)
+
+		if m.ID == nil {
+			c.notification(m)
+			continue
+		}
+
+		if m.Error != nil {
+			return m.Error
+		}
+
+		return json.Unmarshal(m.Result, result)
+	}
+}
+
+func (c *testClient) Notify(method string, params interface{}) {
+	c.write(&message{Method: method, Params: c.marshal(params)})
+}
+
+// Diagnostics waits for a diagnostics notification and returns it.
+func (c *testClient) Diagnostics() []Diagnostic {
+	for len(c.diags) == 0 {
+		m, err := readMessage(c.out)
+		assert.NoError(c.t, err)
+		c.notification(m)
+	}
+
+	diags := c.diags[0]
+	c.diags = c.diags[1:]
+	return diags
+}
+
+func (c *testClient) notification(m *message) {
+	if m.Method != "textDocument/publishDiagnostics" {
+		return
+	}
+
+	var p publishDiagnosticsParams
+	assert.NoError(c.t, json.Unmarshal(m.Params, &p))
+	c.diags = append(c.diags, p.Diagnostics)
+}
+
+func (c *testClient) Close() {
+	c.Notify("exit", nil)
+	close(c.in)
+	assert.NoError(c.t, <-c.done)
+}
+
+func (c *testClient) write(m *message) {
+	c.in <- m
+}
+
+func (c *testClient) marshal(v interface{}) json.RawMessage {
+	content, err := json.Marshal(v)
+	assert.NoError(c.t, err)
+	return content
+}
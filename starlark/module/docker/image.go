@@ -13,6 +13,7 @@ import (
 	"github.com/containers/image/v5/docker"
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/types"
+	"github.com/mcuadros/ascode/starlark/sandbox"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
 )
@@ -35,7 +36,8 @@ var (
 // It is concurrency-safe and idempotent.
 //
 //   outline: docker
-//     The docker modules allow you to manipulate docker image names.
+//     The docker modules allow you to manipulate docker image names. The
+//     methods querying the registry require the `--allow-net` flag.
 //     path: docker
 func LoadModule() (starlark.StringDict, error) {
 	once.Do(func() {
@@ -157,8 +159,11 @@ func (i *image) AttrNames() []string {
 }
 
 func (i *image) builtinVersionFunc(
-	_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
+	thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
 ) (starlark.Value, error) {
+	if err := sandbox.Check(thread, b, sandbox.Net); err != nil {
+		return nil, err
+	}
 
 	var full bool
 	starlark.UnpackArgs("version", args, kwargs, "full", &full)
@@ -176,8 +181,12 @@ func (i *image) builtinVersionFunc(
 }
 
 func (i *image) builtinTagsFunc(
-	_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
+	thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
 ) (starlark.Value, error) {
+	if err := sandbox.Check(thread, b, sandbox.Net); err != nil {
+		return nil, err
+	}
+
 	return i.getTags()
 }
 
@@ -3,6 +3,7 @@ package docker
 import (
 	"testing"
 
+	"github.com/mcuadros/ascode/starlark/sandbox"
 	"github.com/qri-io/starlib/testdata"
 	"go.starlark.net/resolve"
 	"go.starlark.net/starlark"
@@ -13,6 +14,7 @@ func TestImage(t *testing.T) {
 	resolve.AllowFloat = true
 	resolve.AllowLambda = true
 	thread := &starlark.Thread{Load: testdata.NewLoader(LoadModule, ModuleName)}
+	thread.SetLocal(sandbox.PermissionsLocal, sandbox.AllowAll())
 	starlarktest.SetReporter(thread, t)
 
 	// Execute test file
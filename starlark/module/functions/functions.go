@@ -0,0 +1,46 @@
+package functions
+
+import (
+	"sync"
+
+	"github.com/mcuadros/ascode/starlark/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+const (
+	// ModuleName defines the expected name for this Module when used
+	// in starlark's load() function, eg: load('terraform_functions', 'terraform_functions')
+	ModuleName = "terraform_functions"
+)
+
+var (
+	once            sync.Once
+	functionsModule starlark.StringDict
+)
+
+// LoadModule loads the terraform_functions module.
+// It is concurrency-safe and idempotent.
+//
+//   outline: terraform_functions
+//     terraform_functions exposes the Terraform
+//     [functions](https://www.terraform.io/docs/configuration/functions.html),
+//     evaluated at execution time, allowing to make decisions based on its
+//     results. Eg.: `terraform_functions.cidrsubnet("10.0.0.0/16", 8, 1)`
+//     returns `"10.0.1.0/24"`. If any of the arguments is an Attribute, its
+//     value is unknown until Terraform applies the plan, the function is
+//     returned as an Attribute, as `fn` does. The paths given to the file
+//     functions, such as `file`, are relative to the executed program.
+//     path: terraform_functions
+func LoadModule() (starlark.StringDict, error) {
+	once.Do(func() {
+		functionsModule = starlark.StringDict{
+			"terraform_functions": &starlarkstruct.Module{
+				Name:    "terraform_functions",
+				Members: types.BuiltinHCLFunctions(),
+			},
+		}
+	})
+
+	return functionsModule, nil
+}
@@ -0,0 +1 @@
+${name}: ${join(",", ports)}
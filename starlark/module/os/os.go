@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	gobs "github.com/gobs/args"
+	"github.com/mcuadros/ascode/starlark/sandbox"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
 )
@@ -42,6 +43,9 @@ var (
 //
 //   outline: os
 //     os provides a platform-independent interface to operating system functionality.
+//     The functions executing commands, accessing the environment or writing
+//     files require the `--allow-exec`, `--allow-env` and `--allow-write=<dir>`
+//     flags respectively.
 //     path: os
 func LoadModule() (starlark.StringDict, error) {
 	once.Do(func() {
@@ -57,8 +61,8 @@ func LoadModule() (starlark.StringDict, error) {
 					readFileFuncName:  starlark.NewBuiltin(readFileFuncName, ReadFile),
 					mkdirFuncName:     starlark.NewBuiltin(mkdirFuncName, Mkdir),
 					mkdirAllFuncName:  starlark.NewBuiltin(mkdirAllFuncName, MkdirAll),
-					removeFuncName:    starlark.NewBuiltin(mkdirFuncName, Remove),
-					removeAllFuncName: starlark.NewBuiltin(mkdirFuncName, RemoveAll),
+					removeFuncName:    starlark.NewBuiltin(removeFuncName, Remove),
+					removeAllFuncName: starlark.NewBuiltin(removeAllFuncName, RemoveAll),
 					renameFuncName:    starlark.NewBuiltin(renameFuncName, Rename),
 					tempDirFuncName:   starlark.NewBuiltin(tempDirFuncName, TempDir),
 					commandFuncName:   starlark.NewBuiltin(commandFuncName, Command),
@@ -112,7 +116,7 @@ func Getwd(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kw
 //             name of the environment variable
 //           value string
 //             value of the environment variable
-func Setenv(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+func Setenv(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var (
 		key   string
 		value string
@@ -123,6 +127,10 @@ func Setenv(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, k
 		return nil, err
 	}
 
+	if err := sandbox.Check(thread, b, sandbox.Env); err != nil {
+		return nil, err
+	}
+
 	return starlark.None, os.Setenv(key, value)
 }
 
@@ -135,7 +143,7 @@ func Setenv(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, k
 //         params:
 //           key string
 //             name of the environment variable
-func Getenv(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+func Getenv(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var (
 		key string
 		def string
@@ -146,6 +154,10 @@ func Getenv(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, k
 		return nil, err
 	}
 
+	if err := sandbox.Check(thread, b, sandbox.Env); err != nil {
+		return nil, err
+	}
+
 	value := os.Getenv(key)
 	if value == "" {
 		value = def
@@ -169,7 +181,7 @@ func Getenv(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, k
 //              content to be witten to the file
 //           perms int
 //              optional, permission of the file
-func WriteFile(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+func WriteFile(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var (
 		filename string
 		content  string
@@ -181,6 +193,10 @@ func WriteFile(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple
 		return nil, err
 	}
 
+	if err := sandbox.CheckWrite(thread, b, filename); err != nil {
+		return nil, err
+	}
+
 	return starlark.None, ioutil.WriteFile(filename, []byte(content), os.FileMode(perms))
 }
 
@@ -224,7 +240,7 @@ func ReadFile(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 //             name of the folder to be created
 //           perms int
 //              optional, permission of the folder
-func Mkdir(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+func Mkdir(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var (
 		name  string
 		perms = 0777
@@ -235,6 +251,10 @@ func Mkdir(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kw
 		return nil, err
 	}
 
+	if err := sandbox.CheckWrite(thread, b, name); err != nil {
+		return nil, err
+	}
+
 	return starlark.None, os.Mkdir(name, os.FileMode(perms))
 }
 
@@ -249,7 +269,7 @@ func Mkdir(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kw
 //             name of the folder to be created
 //           perms int
 //              optional, permission of the folder
-func MkdirAll(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+func MkdirAll(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var (
 		path  string
 		perms = 0777
@@ -260,6 +280,10 @@ func MkdirAll(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 		return nil, err
 	}
 
+	if err := sandbox.CheckWrite(thread, b, path); err != nil {
+		return nil, err
+	}
+
 	return starlark.None, os.MkdirAll(path, os.FileMode(perms))
 }
 
@@ -272,7 +296,7 @@ func MkdirAll(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 //         params:
 //           name string
 //             name of the file or directory to be deleted
-func Remove(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+func Remove(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var name string
 
 	err := starlark.UnpackArgs(removeFuncName, args, kwargs, "name", &name)
@@ -280,6 +304,10 @@ func Remove(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, k
 		return nil, err
 	}
 
+	if err := sandbox.CheckWrite(thread, b, name); err != nil {
+		return nil, err
+	}
+
 	return starlark.None, os.Remove(name)
 }
 
@@ -293,7 +321,7 @@ func Remove(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, k
 //         params:
 //           name string
 //             path to be deleted
-func RemoveAll(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+func RemoveAll(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var path string
 
 	err := starlark.UnpackArgs(removeAllFuncName, args, kwargs, "path", &path)
@@ -301,6 +329,10 @@ func RemoveAll(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple
 		return nil, err
 	}
 
+	if err := sandbox.CheckWrite(thread, b, path); err != nil {
+		return nil, err
+	}
+
 	return starlark.None, os.RemoveAll(path)
 }
 
@@ -317,7 +349,7 @@ func RemoveAll(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple
 //             old path
 //           newpath string
 //             new path
-func Rename(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+func Rename(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var (
 		oldpath string
 		newpath string
@@ -328,6 +360,10 @@ func Rename(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, k
 		return nil, err
 	}
 
+	if err := sandbox.CheckWrite(thread, b, oldpath, newpath); err != nil {
+		return nil, err
+	}
+
 	return starlark.None, os.Rename(oldpath, newpath)
 }
 
@@ -358,7 +394,7 @@ func TempDir(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 //           env list
 //             specifies the environment of the process, each value of the list
 //             should follow the pattern "key=value".
-func Command(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+func Command(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var (
 		command  string
 		env      *starlark.List
@@ -379,6 +415,10 @@ func Command(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple,
 		return nil, err
 	}
 
+	if err := sandbox.Check(thread, b, sandbox.Exec); err != nil {
+		return nil, err
+	}
+
 	if shell {
 		command = fmt.Sprintf("sh -c %q", command)
 	}
@@ -1,10 +1,13 @@
 package os
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/mcuadros/ascode/starlark/sandbox"
 	"github.com/qri-io/starlib/testdata"
+	"github.com/stretchr/testify/assert"
 	"go.starlark.net/resolve"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarktest"
@@ -21,6 +24,7 @@ func TestFile(t *testing.T) {
 	resolve.AllowLambda = true
 
 	thread := &starlark.Thread{Load: testdata.NewLoader(LoadModule, ModuleName)}
+	thread.SetLocal(sandbox.PermissionsLocal, sandbox.AllowAll())
 	starlarktest.SetReporter(thread, t)
 
 	// Execute test file
@@ -34,3 +38,33 @@ func TestFile(t *testing.T) {
 	}
 
 }
+
+func TestSandbox(t *testing.T) {
+	dir, err := os.MkdirTemp("", "ascode-sandbox")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	thread := &starlark.Thread{Load: testdata.NewLoader(LoadModule, ModuleName)}
+	thread.SetLocal(sandbox.PermissionsLocal, &sandbox.Permissions{Write: []string{dir}})
+
+	predeclared := starlark.StringDict{"dir": starlark.String(dir)}
+	for src, expected := range map[string]string{
+		`os.command("ls")`:                     `command: missing capability "exec", use --allow-exec to enable it`,
+		`os.getenv("HOME")`:                    `getenv: missing capability "env", use --allow-env to enable it`,
+		`os.write_file("/foo", "bar")`:         `write_file: missing capability "write" for "/foo", use --allow-write=<dir> to enable it`,
+		`os.rename(dir + "/foo", "/foo")`:      `rename: missing capability "write" for "/foo", use --allow-write=<dir> to enable it`,
+		`os.write_file(dir + "/../foo", "ba")`: `missing capability "write"`,
+		`os.write_file(dir + "/foo", "bar")`:   ``,
+	} {
+		_, err := starlark.ExecFile(thread, "test", "load('os', 'os')\n"+src, predeclared)
+		if expected == "" {
+			assert.NoError(t, err, src)
+			continue
+		}
+
+		assert.Error(t, err, src)
+		if err != nil {
+			assert.Contains(t, err.Error(), expected, src)
+		}
+	}
+}
@@ -0,0 +1,634 @@
+package runtime
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/mcuadros/ascode/starlark/sandbox"
+	"go.starlark.net/syntax"
+)
+
+// ManifestFileName is the name of the manifest declaring the packages
+// available to a script, stored at the directory of the executed file.
+const ManifestFileName = "ascode.mod"
+
+// VendorDir is the directory, relative to the manifest, where the packages
+// are vendored.
+const VendorDir = "vendor"
+
+const packagePrefix = "@"
+
+// Manifest declares the packages, shared Starlark libraries, available to be
+// loaded using the `@<name>//<path>` syntax. Eg.:
+//
+//   require "netlib" {
+//     git     = "https://example.com/netlib.git"
+//     version = "~> 1.2"
+//   }
+//
+//   require "common" {
+//     path = "../common"
+//   }
+//
+type Manifest struct {
+	Requirements []*Requirement `hcl:"require,block"`
+
+	filename string
+}
+
+// Requirement is a package declared at the Manifest.
+type Requirement struct {
+	// Name of the package, used in the load statements.
+	Name string `hcl:"name,label"`
+	// Path of a local package, relative to the manifest.
+	Path string `hcl:"path,optional"`
+	// Git repository of the package.
+	Git string `hcl:"git,optional"`
+	// Version is a semver constraint matched against the tags of the
+	// repository.
+	Version string `hcl:"version,optional"`
+	// Ref is a branch, tag or commit of the repository, used instead of a
+	// version.
+	Ref string `hcl:"ref,optional"`
+}
+
+// ReadManifest reads the Manifest at the given path.
+func ReadManifest(filename string) (*Manifest, error) {
+	file, diags := hclparse.NewParser().ParseHCLFile(filename)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	m := &Manifest{filename: filename}
+	if diags := gohcl.DecodeBody(file.Body, nil, m); diags.HasErrors() {
+		return nil, diags
+	}
+
+	names := make(map[string]bool)
+	for _, r := range m.Requirements {
+		if names[r.Name] {
+			return nil, fmt.Errorf("%s: package %q declared more than once", filename, r.Name)
+		}
+
+		names[r.Name] = true
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("%s: package %q: %s", filename, r.Name, err)
+		}
+	}
+
+	return m, nil
+}
+
+func (r *Requirement) validate() error {
+	if (r.Path == "") == (r.Git == "") {
+		return fmt.Errorf("expected one of path or git")
+	}
+
+	if r.Path != "" && (r.Version != "" || r.Ref != "") {
+		return fmt.Errorf("version and ref are only valid for git packages")
+	}
+
+	if err := validateGitArgs(r.Git, r.Ref); err != nil {
+		return err
+	}
+
+	if r.Version != "" && r.Ref != "" {
+		return fmt.Errorf("expected only one of version or ref")
+	}
+
+	if r.Version != "" {
+		if _, err := semver.NewConstraint(r.Version); err != nil {
+			return fmt.Errorf("invalid version %q: %s", r.Version, err)
+		}
+	}
+
+	return nil
+}
+
+// Requirement returns the Requirement with the given name, if any.
+func (m *Manifest) Requirement(name string) *Requirement {
+	for _, r := range m.Requirements {
+		if r.Name == name {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// LockedPackage represents a git package pinned at the LockFile.
+type LockedPackage struct {
+	// Git repository of the package.
+	Git string `json:"git"`
+	// Version is the tag matching the version constraint, if any.
+	Version string `json:"version,omitempty"`
+	// Ref is the ref used instead of version, if any.
+	Ref string `json:"ref,omitempty"`
+	// Commit is the resolved commit.
+	Commit string `json:"commit"`
+	// Hash is the content hash of the vendored package, eg.: `sha256:<hex>`.
+	Hash string `json:"hash,omitempty"`
+}
+
+func (l *LockedPackage) satisfies(r *Requirement) bool {
+	if l.Git != r.Git || l.Ref != r.Ref {
+		return false
+	}
+
+	if r.Version == "" {
+		return l.Version == ""
+	}
+
+	v, err := semver.NewVersion(l.Version)
+	if err != nil {
+		return false
+	}
+
+	c, _ := semver.NewConstraint(r.Version)
+	return c.Check(v)
+}
+
+// IsPackage returns true if the given module name references a package.
+func IsPackage(module string) bool {
+	return strings.HasPrefix(module, packagePrefix)
+}
+
+// ParsePackage parses a module name with the format `@<name>//<path>`.
+func ParsePackage(module string) (name, path string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(module, packagePrefix), "//", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid package module %q, expected @<name>//<path>", module)
+	}
+
+	path = filepath.ToSlash(filepath.Clean(parts[1]))
+	if strings.HasPrefix(path, "..") {
+		return "", "", fmt.Errorf("invalid package module %q, path escapes from the package", module)
+	}
+
+	return parts[0], path, nil
+}
+
+// Packages resolves the packages declared at the Manifest of a directory,
+// against the vendored packages, the local ones or the git repositories
+// pinned at the LockFile.
+type Packages struct {
+	// Dir containing the Manifest and the LockFile.
+	Dir string
+	// Cache used to fetch the git packages.
+	Cache *Cache
+
+	// permissions, if any, checked before fetching the git packages and
+	// writing the LockFile, eg.: the ones granted to the executed scripts.
+	permissions *sandbox.Permissions
+	manifest    *Manifest
+	lock        *LockFile
+	// verified are the vendored packages matching the hash of the LockFile.
+	verified map[string]bool
+}
+
+// NewPackages returns the Packages of the given directory, if the directory
+// doesn't contain any Manifest, no package is available.
+func NewPackages(dir string, c *Cache) (*Packages, error) {
+	lock, err := ReadLockFile(filepath.Join(dir, LockFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	return newPackages(dir, c, lock)
+}
+
+func newPackages(dir string, c *Cache, lock *LockFile) (*Packages, error) {
+	p := &Packages{Dir: dir, Cache: c, lock: lock, verified: make(map[string]bool)}
+
+	filename := filepath.Join(dir, ManifestFileName)
+	if _, err := os.Stat(filename); err != nil {
+		p.manifest = &Manifest{filename: filename}
+		return p, nil
+	}
+
+	var err error
+	p.manifest, err = ReadManifest(filename)
+	return p, err
+}
+
+// Manifest returns the Manifest of the packages.
+func (p *Packages) Manifest() *Manifest {
+	return p.manifest
+}
+
+// Resolve returns the location of a file of a package, a filename for the
+// vendored and local packages or a Source for the git packages.
+func (p *Packages) Resolve(name, path string) (filename string, src *Source, err error) {
+	r := p.manifest.Requirement(name)
+	if r == nil {
+		return "", nil, fmt.Errorf("package %q not declared at %s", name, ManifestFileName)
+	}
+
+	vendored := filepath.Join(p.Dir, VendorDir, name)
+	if _, err := os.Stat(vendored); err == nil {
+		if err := p.verify(name, vendored); err != nil {
+			return "", nil, err
+		}
+
+		return filepath.Join(vendored, filepath.FromSlash(path)), nil, nil
+	}
+
+	if r.Path != "" {
+		return filepath.Join(p.Dir, filepath.FromSlash(r.Path), filepath.FromSlash(path)), nil, nil
+	}
+
+	locked, err := p.resolve(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "", &Source{Kind: GitSource, URL: r.Git, Path: path, Ref: locked.Commit}, nil
+}
+
+// resolve returns the LockedPackage of a git Requirement, resolving and
+// pinning it, if it's not already locked.
+func (p *Packages) resolve(r *Requirement) (*LockedPackage, error) {
+	if locked, ok := p.lock.Packages[r.Name]; ok && locked.satisfies(r) {
+		return locked, nil
+	}
+
+	if p.Cache.Offline {
+		return nil, fmt.Errorf("package %q is not locked, unable to resolve in offline mode", r.Name)
+	}
+
+	if err := p.checkResolve(); err != nil {
+		return nil, fmt.Errorf("package %q: %s", r.Name, err)
+	}
+
+	locked := &LockedPackage{Git: r.Git, Ref: r.Ref}
+	ref := r.Ref
+	if r.Version != "" {
+		tag, err := p.matchVersion(r)
+		if err != nil {
+			return nil, err
+		}
+
+		locked.Version, ref = tag, tag
+	}
+
+	var err error
+	locked.Commit, err = p.Cache.ResolveGit(r.Git, ref)
+	if err != nil {
+		return nil, fmt.Errorf("package %q: %s", r.Name, err)
+	}
+
+	p.lock.Packages[r.Name] = locked
+	return locked, p.lock.Write()
+}
+
+func (p *Packages) checkResolve() error {
+	if p.permissions == nil {
+		return nil
+	}
+
+	if err := checkFetch(p.permissions, GitSource, false); err != nil {
+		return err
+	}
+
+	return p.permissions.CheckWrite(p.lock.path)
+}
+
+func (p *Packages) matchVersion(r *Requirement) (string, error) {
+	tags, err := p.Cache.GitTags(r.Git)
+	if err != nil {
+		return "", fmt.Errorf("package %q: %s", r.Name, err)
+	}
+
+	c, _ := semver.NewConstraint(r.Version)
+
+	var match string
+	var highest *semver.Version
+	for _, tag := range tags {
+		v, err := semver.NewVersion(tag)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+
+		if highest == nil || v.GreaterThan(highest) {
+			match, highest = tag, v
+		}
+	}
+
+	if match == "" {
+		return "", fmt.Errorf("package %q: no version matching %q", r.Name, r.Version)
+	}
+
+	return match, nil
+}
+
+// Download resolves and fetches all the git packages of the Manifest, pinning
+// them at the LockFile. If vendor is true, the packages are copied to the
+// vendor directory, and its content hash pinned at the LockFile.
+func (p *Packages) Download(vendor bool) error {
+	for _, r := range p.manifest.Requirements {
+		if r.Git == "" {
+			continue
+		}
+
+		locked, err := p.resolve(r)
+		if err != nil {
+			return err
+		}
+
+		if _, err := p.Cache.ResolveGit(r.Git, locked.Commit); err != nil {
+			return fmt.Errorf("package %q: %s", r.Name, err)
+		}
+
+		if !vendor {
+			continue
+		}
+
+		if err := p.vendor(r.Name, locked); err != nil {
+			return fmt.Errorf("package %q: %s", r.Name, err)
+		}
+	}
+
+	if !vendor {
+		return nil
+	}
+
+	return p.lock.Write()
+}
+
+func (p *Packages) vendor(name string, locked *LockedPackage) error {
+	dir := filepath.Join(p.Dir, VendorDir, name)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	archive, err := git(p.Cache.gitDir(locked.Git), "archive", "--format=tar", locked.Commit)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string][]byte)
+	r := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		filename := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(filename, dir+string(filepath.Separator)) {
+			return fmt.Errorf("invalid file %q in archive", header.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return err
+		}
+
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(filename, content, 0644); err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, filename)
+		files[filepath.ToSlash(rel)] = content
+	}
+
+	hash := packageHash(files)
+	if locked.Hash != "" && locked.Hash != hash {
+		return fmt.Errorf("hash mismatch, locked %s, got %s", locked.Hash, hash)
+	}
+
+	locked.Hash = hash
+	return nil
+}
+
+// verify returns an error if the content of the given vendored package
+// doesn't match the hash pinned at the LockFile.
+func (p *Packages) verify(name, dir string) error {
+	if p.verified[name] {
+		return nil
+	}
+
+	locked, ok := p.lock.Packages[name]
+	if !ok || locked.Hash == "" {
+		return fmt.Errorf("package %q: vendored, but not locked at %s", name, LockFileName)
+	}
+
+	files := make(map[string][]byte)
+	err := filepath.Walk(dir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, filename)
+		files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("package %q: %s", name, err)
+	}
+
+	if hash := packageHash(files); hash != locked.Hash {
+		return fmt.Errorf("package %q: vendored hash mismatch, locked %s, got %s", name, locked.Hash, hash)
+	}
+
+	p.verified[name] = true
+	return nil
+}
+
+// packageHash returns the content hash of a package, hashing the content hash
+// and the path of every file, sorted by path.
+func packageHash(files map[string][]byte) string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	var b bytes.Buffer
+	for _, path := range paths {
+		fmt.Fprintf(&b, "%s  %s\n", contentHash(files[path]), path)
+	}
+
+	return contentHash(b.Bytes())
+}
+
+// Tidy removes from the Manifest the packages not loaded by any Starlark file
+// of the directory, or by the local files loaded by them, and from the
+// LockFile the packages not present at the Manifest. An error is returned if
+// any file loads a package not declared. It returns the names of the removed
+// packages.
+func (p *Packages) Tidy() ([]string, error) {
+	used, err := p.usedPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for name := range used {
+		if p.manifest.Requirement(name) == nil {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("packages not declared at %s: %s", ManifestFileName, strings.Join(missing, ", "))
+	}
+
+	var removed []string
+	var requirements []*Requirement
+	for _, r := range p.manifest.Requirements {
+		if used[r.Name] {
+			requirements = append(requirements, r)
+			continue
+		}
+
+		removed = append(removed, r.Name)
+	}
+
+	if len(removed) != 0 {
+		if err := p.removeRequirements(removed); err != nil {
+			return nil, err
+		}
+
+		p.manifest.Requirements = requirements
+	}
+
+	var changed bool
+	for name := range p.lock.Packages {
+		if r := p.manifest.Requirement(name); r == nil || r.Git == "" {
+			delete(p.lock.Packages, name)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return removed, nil
+	}
+
+	return removed, p.lock.Write()
+}
+
+func (p *Packages) usedPackages() (map[string]bool, error) {
+	used := make(map[string]bool)
+	visited := make(map[string]bool)
+	vendor := filepath.Join(p.Dir, VendorDir)
+	err := filepath.Walk(p.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path == vendor || (path != p.Dir && strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if filepath.Ext(path) != ".star" {
+			return nil
+		}
+
+		return p.scanLoads(path, used, visited)
+	})
+
+	return used, err
+}
+
+// scanLoads adds to used the packages loaded by the given file, following the
+// local loads, resolved as the Runtime does, relative to the directory.
+func (p *Packages) scanLoads(filename string, used, visited map[string]bool) error {
+	if visited[filename] {
+		return nil
+	}
+
+	visited[filename] = true
+	f, err := syntax.Parse(filename, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range f.Stmts {
+		load, ok := stmt.(*syntax.LoadStmt)
+		if !ok {
+			continue
+		}
+
+		module := load.ModuleName()
+		switch {
+		case IsPackage(module):
+			name, _, err := ParsePackage(module)
+			if err != nil {
+				return fmt.Errorf("%s: %s", load.Module.TokenPos, err)
+			}
+
+			used[name] = true
+		case IsRemoteSource(module):
+			continue
+		default:
+			local := filepath.Join(p.Dir, module)
+			if info, err := os.Stat(local); err != nil || info.IsDir() {
+				// built-in modules, eg.: `encoding/json`.
+				continue
+			}
+
+			if err := p.scanLoads(local, used, visited); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Packages) removeRequirements(names []string) error {
+	content, err := ioutil.ReadFile(p.manifest.filename)
+	if err != nil {
+		return err
+	}
+
+	f, diags := hclwrite.ParseConfig(content, p.manifest.filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	for _, name := range names {
+		if block := f.Body().FirstMatchingBlock("require", []string{name}); block != nil {
+			f.Body().RemoveBlock(block)
+		}
+	}
+
+	return ioutil.WriteFile(p.manifest.filename, hclwrite.Format(f.Bytes()), 0644)
+}
@@ -0,0 +1,225 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadManifest(t *testing.T) {
+	dir := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, ManifestFileName), `
+require "netlib" {
+  git     = "https://example.com/netlib.git"
+  version = "~> 1.2"
+}
+
+require "common" {
+  path = "../common"
+}
+`)
+
+	m, err := ReadManifest(filepath.Join(dir, ManifestFileName))
+	assert.NoError(t, err)
+	assert.Len(t, m.Requirements, 2)
+	assert.Equal(t, &Requirement{
+		Name: "netlib", Git: "https://example.com/netlib.git", Version: "~> 1.2",
+	}, m.Requirement("netlib"))
+	assert.Equal(t, "../common", m.Requirement("common").Path)
+	assert.Nil(t, m.Requirement("foo"))
+}
+
+func TestReadManifestError(t *testing.T) {
+	for content, expected := range map[string]string{
+		`require "foo" {}`: "expected one of path or git",
+		`require "foo" {
+		  path = "foo"
+		  git  = "foo"
+		}`: "expected one of path or git",
+		`require "foo" {
+		  path    = "foo"
+		  version = "1.0"
+		}`: "version and ref are only valid for git packages",
+		`require "foo" {
+		  git     = "foo"
+		  version = "1.0"
+		  ref     = "master"
+		}`: "expected only one of version or ref",
+		`require "foo" {
+		  git     = "foo"
+		  version = "foo"
+		}`: `invalid version "foo"`,
+		`require "foo" {
+		  path = "foo"
+		}
+		require "foo" {
+		  path = "bar"
+		}`: `package "foo" declared more than once`,
+	} {
+		dir := tempDir(t)
+		writeTestFile(t, filepath.Join(dir, ManifestFileName), content)
+
+		_, err := ReadManifest(filepath.Join(dir, ManifestFileName))
+		assert.Error(t, err, content)
+		if err != nil {
+			assert.Contains(t, err.Error(), expected)
+		}
+	}
+}
+
+func TestParsePackage(t *testing.T) {
+	name, path, err := ParsePackage("@netlib//lib/./vpc.star")
+	assert.NoError(t, err)
+	assert.Equal(t, "netlib", name)
+	assert.Equal(t, "lib/vpc.star", path)
+
+	for _, module := range []string{"@netlib", "@//vpc.star", "@netlib//", "@netlib//../vpc.star"} {
+		_, _, err := ParsePackage(module)
+		assert.Error(t, err, module)
+	}
+}
+
+func TestLoadPackage(t *testing.T) {
+	repository := newTestRepository(t, map[string]string{
+		"vpc.star":  "load(\"util.star\", \"version\")\nname = \"vpc-\" + version\n",
+		"util.star": "version = \"1.0.0\"\n",
+	})
+
+	gitCommit(t, repository, "v1.0.0")
+	writeTestFile(t, filepath.Join(repository, "util.star"), "version = \"1.2.0\"\n")
+	commit := gitCommit(t, repository, "v1.2.0")
+	writeTestFile(t, filepath.Join(repository, "util.star"), "version = \"2.0.0\"\n")
+	gitCommit(t, repository, "v2.0.0")
+
+	dir := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, "common", "tags.star"), "tags = {\"team\": \"platform\"}\n")
+	writeTestFile(t, filepath.Join(dir, "main", ManifestFileName), `
+require "netlib" {
+  git     = "file://`+repository+`"
+  version = "^1.0"
+}
+
+require "common" {
+  path = "../common"
+}
+`)
+
+	writeTestFile(t, filepath.Join(dir, "main", "main.star"), `
+load("@netlib//vpc.star", "name")
+load("@common//tags.star", "tags")
+
+if name != "vpc-1.2.0":
+    fail("unexpected name %s" % name)
+
+if tags["team"] != "platform":
+    fail("unexpected tags %s" % tags)
+`)
+
+	cache := tempDir(t)
+	rc := newRemoteRuntime()
+	rc.Cache.Dir = cache
+	_, err := rc.ExecFile(filepath.Join(dir, "main", "main.star"))
+	assert.NoError(t, err)
+
+	lock, err := ReadLockFile(filepath.Join(dir, "main", LockFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, &LockedPackage{
+		Git: "file://" + repository, Version: "v1.2.0", Commit: commit,
+	}, lock.Packages["netlib"])
+	assert.Len(t, lock.Sources, 2)
+
+	rc = newRemoteRuntime()
+	rc.Cache = &Cache{Dir: cache, Offline: true}
+	_, err = rc.ExecFile(filepath.Join(dir, "main", "main.star"))
+	assert.NoError(t, err)
+
+	// vendored packages don't require the cache.
+	p, err := NewPackages(filepath.Join(dir, "main"), &Cache{Dir: cache})
+	assert.NoError(t, err)
+	assert.NoError(t, p.Download(true))
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "main", VendorDir, "netlib", "util.star"))
+	assert.NoError(t, err)
+	assert.Equal(t, "version = \"1.2.0\"\n", string(content))
+
+	rc = newRemoteRuntime()
+	rc.Cache = &Cache{Dir: tempDir(t), Offline: true}
+	_, err = rc.ExecFile(filepath.Join(dir, "main", "main.star"))
+	assert.NoError(t, err)
+
+	lock, err = ReadLockFile(filepath.Join(dir, "main", LockFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, lock.Packages["netlib"].Hash, "sha256:")
+
+	// the vendored packages are verified against the hash of the lock file.
+	writeTestFile(t, filepath.Join(dir, "main", VendorDir, "netlib", "util.star"), "version = \"1.3.0\"\n")
+
+	rc = newRemoteRuntime()
+	rc.Cache = &Cache{Dir: tempDir(t), Offline: true}
+	_, err = rc.ExecFile(filepath.Join(dir, "main", "main.star"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `package "netlib": vendored hash mismatch`)
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "main", LockFileName)))
+	rc = newRemoteRuntime()
+	rc.Cache = &Cache{Dir: tempDir(t), Offline: true}
+	_, err = rc.ExecFile(filepath.Join(dir, "main", "main.star"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `package "netlib": vendored, but not locked at ascode.lock`)
+}
+
+func TestLoadPackageNotDeclared(t *testing.T) {
+	dir := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, "main.star"), `load("@netlib//vpc.star", "name")`)
+
+	rc := NewRuntime(nil)
+	_, err := rc.ExecFile(filepath.Join(dir, "main.star"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `package "netlib" not declared at ascode.mod`)
+}
+
+func TestPackagesTidy(t *testing.T) {
+	root := tempDir(t)
+	dir := filepath.Join(root, "main")
+	writeTestFile(t, filepath.Join(dir, ManifestFileName), `require "common" {
+  path = "common"
+}
+
+require "shared" {
+  path = "shared"
+}
+
+require "unused" {
+  path = "unused"
+}
+`)
+
+	writeTestFile(t, filepath.Join(dir, "main.star"), `
+load("@common//tags.star", "tags")
+load("../lib/net.star", "net")
+# load("@unused//foo.star", "foo")
+`)
+
+	writeTestFile(t, filepath.Join(root, "lib", "net.star"), `load("@shared//net.star", "net")`)
+
+	p, err := NewPackages(dir, &Cache{Dir: tempDir(t)})
+	assert.NoError(t, err)
+
+	removed, err := p.Tidy()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"unused"}, removed)
+
+	_, err = os.Stat(filepath.Join(dir, LockFileName))
+	assert.True(t, os.IsNotExist(err))
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, ManifestFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, "require \"common\" {\n  path = \"common\"\n}\n\nrequire \"shared\" {\n  path = \"shared\"\n}\n\n", string(content))
+
+	writeTestFile(t, filepath.Join(dir, "other.star"), `load("@netlib//vpc.star", "name")`)
+	_, err = p.Tidy()
+	assert.EqualError(t, err, "packages not declared at ascode.mod: netlib")
+}
@@ -0,0 +1,458 @@
+package runtime
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mcuadros/ascode/starlark/sandbox"
+)
+
+// LockFileName is the name of the lock file, pinning the remote modules,
+// stored at the directory of the executed file.
+const LockFileName = "ascode.lock"
+
+// SourceKind defines the kind of a remote Source.
+type SourceKind string
+
+// SourceKind constants.
+const (
+	// GitSource is a file contained in a git repository.
+	GitSource SourceKind = "git"
+	// HTTPSSource is a file served over HTTPS.
+	HTTPSSource SourceKind = "https"
+)
+
+const gitSourcePrefix = "git::"
+
+// Source represents the location of a remote Starlark module, eg.:
+// `git::https://example.com/lib.git//net.star?ref=v1.2` or
+// `https://example.com/lib/net.star`.
+type Source struct {
+	// Kind of the source.
+	Kind SourceKind
+	// URL of the git repository or the file.
+	URL string
+	// Path of the file inside of the git repository.
+	Path string
+	// Ref is the git reference, a branch, tag or commit, if empty HEAD is
+	// used.
+	Ref string
+}
+
+// IsRemoteSource returns true if the given module name is a remote source.
+func IsRemoteSource(module string) bool {
+	return strings.HasPrefix(module, gitSourcePrefix) ||
+		strings.HasPrefix(module, "https://")
+}
+
+// ParseSource parses a remote source.
+func ParseSource(module string) (*Source, error) {
+	if strings.HasPrefix(module, "https://") {
+		if _, err := url.Parse(module); err != nil {
+			return nil, err
+		}
+
+		return &Source{Kind: HTTPSSource, URL: module}, nil
+	}
+
+	if !strings.HasPrefix(module, gitSourcePrefix) {
+		return nil, fmt.Errorf("invalid source %q, expected git:: or https://", module)
+	}
+
+	src := &Source{Kind: GitSource}
+	raw := strings.TrimPrefix(module, gitSourcePrefix)
+	if i := strings.LastIndex(raw, "?"); i != -1 {
+		query, err := url.ParseQuery(raw[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid source %q: %s", module, err)
+		}
+
+		src.Ref = query.Get("ref")
+		raw = raw[:i]
+	}
+
+	start := 0
+	if i := strings.Index(raw, "://"); i != -1 {
+		start = i + 3
+	}
+
+	i := strings.Index(raw[start:], "//")
+	if i == -1 {
+		return nil, fmt.Errorf("invalid source %q, expected <repository>//<path>", module)
+	}
+
+	src.URL = raw[:start+i]
+	src.Path = path.Clean(raw[start+i+2:])
+	if src.URL == "" || src.Path == "." || strings.HasPrefix(src.Path, "..") {
+		return nil, fmt.Errorf("invalid source %q, expected <repository>//<path>", module)
+	}
+
+	if err := validateGitArgs(src.URL, src.Ref); err != nil {
+		return nil, fmt.Errorf("invalid source %q: %s", module, err)
+	}
+
+	return src, nil
+}
+
+// validateGitArgs returns an error if any of the given repositories or refs,
+// given as arguments to git, could be interpreted as an option.
+func validateGitArgs(args ...string) error {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("%q can't start with a dash", arg)
+		}
+	}
+
+	return nil
+}
+
+// Join returns the Source of a module relative to the given one.
+func (s *Source) Join(module string) (*Source, error) {
+	switch s.Kind {
+	case GitSource:
+		p := path.Join(path.Dir(s.Path), module)
+		if strings.HasPrefix(p, "..") {
+			return nil, fmt.Errorf("%s: path %q escapes from the repository", s, module)
+		}
+
+		return &Source{Kind: GitSource, URL: s.URL, Path: p, Ref: s.Ref}, nil
+	case HTTPSSource:
+		base, err := url.Parse(s.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		rel, err := url.Parse(module)
+		if err != nil {
+			return nil, err
+		}
+
+		u := base.ResolveReference(rel)
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("%s: module %q is not served over https", s, module)
+		}
+
+		return &Source{Kind: HTTPSSource, URL: u.String()}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected source kind %q", s.Kind)
+}
+
+func (s *Source) String() string {
+	if s.Kind == HTTPSSource {
+		return s.URL
+	}
+
+	str := fmt.Sprintf("%s%s//%s", gitSourcePrefix, s.URL, s.Path)
+	if s.Ref != "" {
+		str += "?ref=" + s.Ref
+	}
+
+	return str
+}
+
+// LockFile pins the commits and hashes of the remote modules and packages
+// used by a script.
+type LockFile struct {
+	// Sources locked by the string representation of the Source.
+	Sources map[string]*LockedSource `json:"sources"`
+	// Packages locked by name.
+	Packages map[string]*LockedPackage `json:"packages,omitempty"`
+
+	path string
+}
+
+// LockedSource represents a pinned Source.
+type LockedSource struct {
+	// Commit is the resolved commit of a git Source.
+	Commit string `json:"commit,omitempty"`
+	// Hash is the content hash of the module, eg.: `sha256:<hex>`.
+	Hash string `json:"hash"`
+}
+
+// ReadLockFile reads the LockFile at the given path, if the file doesn't
+// exist an empty LockFile is returned.
+func ReadLockFile(filename string) (*LockFile, error) {
+	l := &LockFile{
+		Sources:  make(map[string]*LockedSource),
+		Packages: make(map[string]*LockedPackage),
+		path:     filename,
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, l); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	if l.Sources == nil {
+		l.Sources = make(map[string]*LockedSource)
+	}
+
+	if l.Packages == nil {
+		l.Packages = make(map[string]*LockedPackage)
+	}
+
+	return l, nil
+}
+
+// Write writes the LockFile to disk.
+func (l *LockFile) Write() error {
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(l.path, append(content, '\n'), 0644)
+}
+
+// Cache is a content-addressed cache of remote modules, it also keeps a
+// mirror of the git repositories.
+type Cache struct {
+	// Dir where the cache is stored.
+	Dir string
+	// Offline disables any network access, only the cached modules can be
+	// used.
+	Offline bool
+
+	client *http.Client
+}
+
+// Get returns the content of a module by hash, if present in the cache.
+func (c *Cache) Get(hash string) ([]byte, bool) {
+	content, err := ioutil.ReadFile(c.blobPath(hash))
+	if err != nil || contentHash(content) != hash {
+		return nil, false
+	}
+
+	return content, true
+}
+
+// Put stores the content of a module and returns its hash.
+func (c *Cache) Put(content []byte) (string, error) {
+	hash := contentHash(content)
+	filename := c.blobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", err
+	}
+
+	return hash, ioutil.WriteFile(filename, content, 0644)
+}
+
+func (c *Cache) blobPath(hash string) string {
+	return filepath.Join(c.Dir, "blobs", strings.Replace(hash, ":", string(filepath.Separator), 1))
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Fetch returns the content of the Source, resolving the given ref, if empty
+// the ref of the Source is used. The resolved commit is returned for the git
+// sources.
+func (c *Cache) Fetch(src *Source, ref string) (commit string, content []byte, err error) {
+	switch src.Kind {
+	case GitSource:
+		if ref == "" {
+			ref = src.Ref
+		}
+
+		return c.fetchGit(src, ref)
+	case HTTPSSource:
+		content, err := c.fetchHTTPS(src)
+		return "", content, err
+	}
+
+	return "", nil, fmt.Errorf("unexpected source kind %q", src.Kind)
+}
+
+// httpClient is the client used by default to fetch the https sources.
+var httpClient = &http.Client{Timeout: time.Minute, CheckRedirect: checkRedirect}
+
+// checkRedirect only follows the redirects to https URLs, as Source.Join
+// does with the relative loads.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to %q is not served over https", req.URL)
+	}
+
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+
+	return nil
+}
+
+func (c *Cache) fetchHTTPS(src *Source) ([]byte, error) {
+	if c.Offline {
+		return nil, fmt.Errorf("%s: not found in the cache, unable to fetch in offline mode", src)
+	}
+
+	client := httpClient
+	if c.client != nil {
+		client = &http.Client{
+			Transport:     c.client.Transport,
+			Timeout:       c.client.Timeout,
+			CheckRedirect: checkRedirect,
+		}
+	}
+
+	resp, err := client.Get(src.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status code %d", src, resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+var commitRegexp = regexp.MustCompile("^[0-9a-f]{40}$")
+
+func (c *Cache) fetchGit(src *Source, ref string) (string, []byte, error) {
+	commit, err := c.ResolveGit(src.URL, ref)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %s", src, err)
+	}
+
+	content, err := git(c.gitDir(src.URL), "cat-file", "blob", commit+":"+src.Path)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %s", src, err)
+	}
+
+	return commit, content, nil
+}
+
+// ResolveGit resolves the given ref of a git repository to a commit, the
+// repository is cloned or updated if required.
+func (c *Cache) ResolveGit(repository, ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	if err := validateGitArgs(repository, ref); err != nil {
+		return "", err
+	}
+
+	dir := c.gitDir(repository)
+	if c.Offline {
+		if _, err := os.Stat(dir); err != nil {
+			return "", fmt.Errorf("repository %q not found in the cache, unable to fetch in offline mode", repository)
+		}
+
+		return c.revParse(dir, ref)
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		if commit, err := c.revParse(dir, ref); err == nil && commitRegexp.MatchString(ref) {
+			return commit, nil
+		}
+
+		if _, err := git(dir, "fetch", "--quiet", "--prune", "origin"); err != nil {
+			return "", err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", err
+		}
+
+		if _, err := git("", "clone", "--quiet", "--mirror", "--", repository, dir); err != nil {
+			return "", err
+		}
+	}
+
+	return c.revParse(dir, ref)
+}
+
+// GitTags returns the tags of a git repository, the repository is cloned or
+// updated if required.
+func (c *Cache) GitTags(repository string) ([]string, error) {
+	if _, err := c.ResolveGit(repository, ""); err != nil {
+		return nil, err
+	}
+
+	output, err := git(c.gitDir(repository), "tag", "--list")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(output)), nil
+}
+
+func (c *Cache) revParse(dir, ref string) (string, error) {
+	output, err := git(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve ref %q", ref)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (c *Cache) gitDir(repository string) string {
+	sum := sha256.Sum256([]byte(repository))
+	return filepath.Join(c.Dir, "git", hex.EncodeToString(sum[:]))
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	command := args[0]
+	if dir != "" {
+		args = append([]string{"--git-dir", dir}, args...)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+
+		return nil, fmt.Errorf("git %s: %s", command, msg)
+	}
+
+	return output, nil
+}
+
+// checkFetch returns an error if the given Permissions don't grant the
+// capabilities required to fetch a module from the given kind of Source: Net,
+// unless in offline mode, and Exec for the git sources, since git is executed.
+func checkFetch(p *sandbox.Permissions, kind SourceKind, offline bool) error {
+	if !offline {
+		if err := p.Check(sandbox.Net); err != nil {
+			return err
+		}
+	}
+
+	if kind == GitSource {
+		return p.Check(sandbox.Exec)
+	}
+
+	return nil
+}
@@ -0,0 +1,265 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/mcuadros/ascode/starlark/sandbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSource(t *testing.T) {
+	for module, expected := range map[string]Source{
+		"git::https://example.com/lib.git//net.star?ref=v1.2": {
+			Kind: GitSource, URL: "https://example.com/lib.git", Path: "net.star", Ref: "v1.2",
+		},
+		"git::https://example.com/lib.git//lib/net.star": {
+			Kind: GitSource, URL: "https://example.com/lib.git", Path: "lib/net.star",
+		},
+		"git::file:///tmp/lib.git//lib/./net.star?ref=master": {
+			Kind: GitSource, URL: "file:///tmp/lib.git", Path: "lib/net.star", Ref: "master",
+		},
+		"https://example.com/lib/net.star": {
+			Kind: HTTPSSource, URL: "https://example.com/lib/net.star",
+		},
+	} {
+		src, err := ParseSource(module)
+		assert.NoError(t, err, module)
+		assert.Equal(t, expected, *src, module)
+	}
+}
+
+func TestParseSourceError(t *testing.T) {
+	for _, module := range []string{
+		"git::https://example.com/lib.git",
+		"git::https://example.com/lib.git//",
+		"git::https://example.com/lib.git//../foo.star",
+		"git::--upload-pack=touch foo//lib/net.star",
+		"git::https://example.com/lib.git//lib/net.star?ref=--output=foo",
+		"foo.star",
+	} {
+		_, err := ParseSource(module)
+		assert.Error(t, err, module)
+	}
+}
+
+func TestSourceJoin(t *testing.T) {
+	src, _ := ParseSource("git::https://example.com/lib.git//lib/net.star?ref=v1.2")
+	rel, err := src.Join("../util/cidr.star")
+	assert.NoError(t, err)
+	assert.Equal(t, "git::https://example.com/lib.git//util/cidr.star?ref=v1.2", rel.String())
+
+	_, err = src.Join("../../foo.star")
+	assert.Error(t, err)
+
+	src, _ = ParseSource("https://example.com/lib/net.star")
+	rel, err = src.Join("util.star")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/lib/util.star", rel.String())
+
+	_, err = src.Join("http://example.com/lib/util.star")
+	assert.Error(t, err)
+}
+
+func TestLoadGit(t *testing.T) {
+	repository := newTestRepository(t, map[string]string{
+		"lib/net.star":  "load(\"util.star\", \"prefix\")\nname = prefix + \"v1\"\n",
+		"lib/util.star": "prefix = \"net-\"\n",
+	})
+
+	commit := gitCommit(t, repository, "v1")
+	writeTestFile(t, filepath.Join(repository, "lib/net.star"), "name = \"v2\"\n")
+	gitCommit(t, repository, "v2")
+
+	dir := tempDir(t)
+	cache := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, "main.star"), `
+load("git::file://`+repository+`//lib/net.star?ref=v1", "name")
+if name != "net-v1":
+    fail("unexpected name %s" % name)
+`)
+
+	rc := newRemoteRuntime()
+	rc.Cache.Dir = cache
+	_, err := rc.ExecFile(filepath.Join(dir, "main.star"))
+	assert.NoError(t, err)
+
+	lock, err := ReadLockFile(filepath.Join(dir, LockFileName))
+	assert.NoError(t, err)
+	assert.Len(t, lock.Sources, 2)
+
+	locked := lock.Sources["git::file://"+repository+"//lib/net.star?ref=v1"]
+	assert.Equal(t, commit, locked.Commit)
+
+	// the tag is moved, but the lock file pins the commit.
+	gitRun(t, repository, "tag", "-f", "v1")
+
+	rc = newRemoteRuntime()
+	rc.Cache = &Cache{Dir: cache, Offline: true}
+	_, err = rc.ExecFile(filepath.Join(dir, "main.star"))
+	assert.NoError(t, err)
+
+	rc = newRemoteRuntime()
+	rc.Cache = &Cache{Dir: tempDir(t)}
+	_, err = rc.ExecFile(filepath.Join(dir, "main.star"))
+	assert.NoError(t, err)
+
+	// without the lock file, the cached mirror is used in offline mode.
+	assert.NoError(t, os.Remove(filepath.Join(dir, LockFileName)))
+	rc = newRemoteRuntime()
+	rc.Cache = &Cache{Dir: cache, Offline: true}
+	_, err = rc.ExecFile(filepath.Join(dir, "main.star"))
+	assert.NoError(t, err)
+}
+
+func TestLoadGitPermissions(t *testing.T) {
+	repository := newTestRepository(t, map[string]string{
+		"lib/net.star": "name = \"net\"\n",
+	})
+
+	gitCommit(t, repository, "v1")
+
+	dir := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, "main.star"), `
+load("git::file://`+repository+`//lib/net.star?ref=v1", "name")
+`)
+
+	for _, p := range []sandbox.Permissions{
+		{Exec: true, Write: []string{dir}},
+		{Net: true, Write: []string{dir}},
+		{Net: true, Exec: true},
+	} {
+		rc := NewRuntime(nil)
+		rc.Cache.Dir = tempDir(t)
+		rc.Permissions = p
+		_, err := rc.ExecFile(filepath.Join(dir, "main.star"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "missing capability")
+	}
+
+	_, err := os.Stat(filepath.Join(dir, LockFileName))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLoadOffline(t *testing.T) {
+	dir := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, "main.star"), `load("https://example.com/lib.star", "foo")`)
+
+	rc := NewRuntime(nil)
+	rc.Cache = &Cache{Dir: tempDir(t), Offline: true}
+	_, err := rc.ExecFile(filepath.Join(dir, "main.star"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to fetch in offline mode")
+}
+
+func TestLoadHTTPS(t *testing.T) {
+	content := map[string]string{
+		"/lib/net.star":  "load(\"util.star\", \"prefix\")\nname = prefix + \"v1\"\n",
+		"/lib/util.star": "prefix = \"net-\"\n",
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := content[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(c))
+	}))
+	defer srv.Close()
+
+	dir := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, "main.star"), `
+load("`+srv.URL+`/lib/net.star", "name")
+if name != "net-v1":
+    fail("unexpected name %s" % name)
+`)
+
+	rc := newRemoteRuntime()
+	rc.Cache = &Cache{Dir: tempDir(t), client: srv.Client()}
+	_, err := rc.ExecFile(filepath.Join(dir, "main.star"))
+	assert.NoError(t, err)
+
+	lock, err := ReadLockFile(filepath.Join(dir, LockFileName))
+	assert.NoError(t, err)
+	assert.Len(t, lock.Sources, 2)
+
+	content["/lib/util.star"] = "prefix = \"evil-\"\n"
+
+	rc = newRemoteRuntime()
+	rc.Cache = &Cache{Dir: tempDir(t), client: srv.Client()}
+	_, err = rc.ExecFile(filepath.Join(dir, "main.star"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "hash mismatch")
+}
+
+func TestLoadHTTPSRedirect(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://example.com/lib.star", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	dir := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, "main.star"), `load("`+srv.URL+`/lib.star", "name")`)
+
+	rc := newRemoteRuntime()
+	rc.Cache = &Cache{Dir: tempDir(t), client: srv.Client()}
+	_, err := rc.ExecFile(filepath.Join(dir, "main.star"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `redirect to "http://example.com/lib.star" is not served over https`)
+}
+
+func newTestRepository(t *testing.T, files map[string]string) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir := tempDir(t)
+	gitRun(t, dir, "init", "--quiet")
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(dir, name), content)
+	}
+
+	return dir
+}
+
+func gitCommit(t *testing.T, dir, tag string) string {
+	gitRun(t, dir, "add", "-A")
+	gitRun(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", tag)
+	gitRun(t, dir, "tag", tag)
+
+	output, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	assert.NoError(t, err)
+	return string(output[:40])
+}
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	output, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %s", args, output)
+	}
+}
+
+func newRemoteRuntime() *Runtime {
+	rc := NewRuntime(nil)
+	rc.Permissions = *sandbox.AllowAll()
+	return rc
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "ascode-runtime")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func writeTestFile(t *testing.T, filename, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(content), 0644))
+}
@@ -0,0 +1,447 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/chzyer/readline"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/mcuadros/ascode/starlark/types"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// REPLCommands are the commands available at the REPL, besides Starlark
+// statements, by name.
+var REPLCommands = map[string]string{
+	":help": "shows this help, or the documentation of the given value, eg.: `:help aws.resource.instance`",
+	":hcl":  "prints the HCL encoding of all the resources defined",
+	":load": "executes the given file, defining its globals, eg.: `:load main.star`",
+	":quit": "exits the REPL",
+}
+
+// REPL is a read, eval, print loop, executing the statements over the same
+// globals, with tab completion based on the values defined, and persistent
+// history.
+type REPL struct {
+	// HistoryFile is the file where the history is persisted, if empty the
+	// history is not persisted.
+	HistoryFile string
+	// Stdout and Stderr where the results and the errors are written.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	runtime *Runtime
+	thread  *starlark.Thread
+	globals starlark.StringDict
+}
+
+// NewREPL returns a new REPL, evaluating the statements with this Runtime.
+func (r *Runtime) NewREPL() *REPL {
+	thread := &starlark.Thread{Name: "repl", Load: r.load}
+	r.setLocals(thread)
+
+	globals := make(starlark.StringDict, len(r.predeclared))
+	for name, v := range r.predeclared {
+		globals[name] = v
+	}
+
+	return &REPL{
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+		runtime: r,
+		thread:  thread,
+		globals: globals,
+	}
+}
+
+// REPL executes a read, eval, print loop.
+func (r *Runtime) REPL() error {
+	return r.NewREPL().Run()
+}
+
+// Run reads the input from the terminal until EOF or `:quit`.
+func (r *REPL) Run() error {
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:       ">>> ",
+		HistoryFile:  r.HistoryFile,
+		AutoComplete: &replCompleter{r},
+		Stdout:       r.Stdout,
+		Stderr:       r.Stderr,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer rl.Close()
+	for {
+		rl.SetPrompt(">>> ")
+		err := r.rep(func() ([]byte, error) {
+			line, err := rl.Readline()
+			rl.SetPrompt("... ")
+			if err != nil {
+				return nil, err
+			}
+
+			return []byte(line + "\n"), nil
+		})
+
+		switch err {
+		case nil:
+		case readline.ErrInterrupt:
+			fmt.Fprintln(r.Stdout, err)
+		case io.EOF:
+			fmt.Fprintln(r.Stdout)
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// Exec executes the given source, as if it was typed at the REPL.
+func (r *REPL) Exec(src string) error {
+	lines := strings.SplitAfter(src, "\n")
+	for {
+		err := r.rep(func() ([]byte, error) {
+			if len(lines) == 0 || lines[0] == "" {
+				return nil, io.EOF
+			}
+
+			line := lines[0]
+			lines = lines[1:]
+			return []byte(line), nil
+		})
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// rep reads, evaluates, and prints one item, a statement or a command. It
+// returns an error only if the reading fails, or at `:quit` with io.EOF.
+func (r *REPL) rep(readline func() ([]byte, error)) error {
+	first, err := readline()
+	if err != nil {
+		return err
+	}
+
+	if line := strings.TrimSpace(string(first)); strings.HasPrefix(line, ":") {
+		return r.command(line)
+	}
+
+	read := false
+	f, err := syntax.ParseCompoundStmt("<stdin>", func() ([]byte, error) {
+		if !read {
+			read = true
+			return first, nil
+		}
+
+		return readline()
+	})
+
+	if err != nil {
+		if err == io.EOF {
+			return err
+		}
+
+		r.printError(err)
+		return nil
+	}
+
+	// load bindings are global in the REPL, as in go.starlark.net/repl.
+	defer func(prev bool) { resolve.LoadBindsGlobally = prev }(resolve.LoadBindsGlobally)
+	resolve.LoadBindsGlobally = true
+
+	if expr := soleExpr(f); expr != nil {
+		v, err := starlark.EvalExpr(r.thread, expr, r.globals)
+		if err != nil {
+			r.printError(err)
+			return nil
+		}
+
+		if v != starlark.None {
+			fmt.Fprintln(r.Stdout, v)
+		}
+
+		return nil
+	}
+
+	if err := starlark.ExecREPLChunk(f, r.thread, r.globals); err != nil {
+		r.printError(err)
+	}
+
+	return nil
+}
+
+func (r *REPL) command(line string) error {
+	parts := strings.Fields(line)
+	name, args := parts[0], parts[1:]
+
+	var err error
+	switch name {
+	case ":quit":
+		return io.EOF
+	case ":help":
+		err = r.help(strings.Join(args, " "))
+	case ":hcl":
+		f := hclwrite.NewEmptyFile()
+		r.runtime.Terraform.ToHCL(f.Body())
+		_, err = r.Stdout.Write(f.Bytes())
+	case ":load":
+		err = r.load(args)
+	default:
+		err = fmt.Errorf("unknown command %q, use :help to list the available commands", name)
+	}
+
+	if err != nil {
+		r.printError(err)
+	}
+
+	return nil
+}
+
+func (r *REPL) load(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf(":load: expected a file name")
+	}
+
+	globals, err := r.runtime.ExecFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	for name, v := range globals {
+		r.globals[name] = v
+	}
+
+	// the REPL thread executes the next statements relative to the script
+	r.runtime.setLocals(r.thread)
+	return nil
+}
+
+func (r *REPL) help(expr string) error {
+	w := tabwriter.NewWriter(r.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	if expr == "" {
+		names := make([]string, 0, len(REPLCommands))
+		for name := range REPLCommands {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\t%s\n", name, REPLCommands[name])
+		}
+
+		return nil
+	}
+
+	v, err := starlark.Eval(r.thread, "<help>", expr, r.globals)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s: %s\n", expr, v.Type())
+	if s := v.String(); s != v.Type() && len(s) < 80 {
+		fmt.Fprintln(w, s)
+	}
+
+	var schema *configschema.Block
+	switch v := v.(type) {
+	case *types.ResourceCollection:
+		schema = v.Schema()
+	case interface{ Schema() *configschema.Block }:
+		schema = v.Schema()
+	}
+
+	if schema == nil {
+		if attrs, ok := v.(starlark.HasAttrs); ok {
+			names := attrs.AttrNames()
+			sort.Strings(names)
+			fmt.Fprintf(w, "\nAttributes:\n  %s\n", strings.Join(names, ", "))
+		}
+
+		return nil
+	}
+
+	writeSchema(w, schema)
+	return nil
+}
+
+func writeSchema(w io.Writer, schema *configschema.Block) {
+	names := make([]string, 0, len(schema.Attributes))
+	for name := range schema.Attributes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	if len(names) != 0 {
+		fmt.Fprintln(w, "\nArguments:")
+	}
+
+	for _, name := range names {
+		attr := schema.Attributes[name]
+
+		var flags []string
+		switch {
+		case attr.Required:
+			flags = append(flags, "required")
+		case attr.Optional:
+			flags = append(flags, "optional")
+		}
+
+		if attr.Computed {
+			flags = append(flags, "computed")
+		}
+
+		description := strings.Join(strings.Fields(attr.Description), " ")
+		fmt.Fprintf(w, "  %s\t%s (%s)\t%s\n", name, attr.Type.FriendlyName(), strings.Join(flags, ", "), description)
+	}
+
+	names = names[:0]
+	for name := range schema.BlockTypes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	if len(names) != 0 {
+		fmt.Fprintln(w, "\nBlocks:")
+	}
+
+	for _, name := range names {
+		b := schema.BlockTypes[name]
+		fmt.Fprintf(w, "  %s\t%s\t\n", name, strings.ToLower(strings.TrimPrefix(b.Nesting.String(), "Nesting")))
+	}
+}
+
+// Complete returns the candidates to complete the last expression of the
+// given line, based on the names of the globals, or the attributes of the
+// values when the expression is dotted, eg.: `aws.resource.ins`.
+func (r *REPL) Complete(line string) []string {
+	if strings.HasPrefix(line, ":") && !strings.Contains(line, " ") {
+		return completeNames(line, REPLCommands)
+	}
+
+	i := len(line)
+	for i > 0 && (isIdentifier(rune(line[i-1])) || line[i-1] == '.') {
+		i--
+	}
+
+	parts := strings.Split(line[i:], ".")
+	partial := parts[len(parts)-1]
+	if len(parts) == 1 {
+		names := make(map[string]string)
+		for _, dict := range []starlark.StringDict{r.globals, starlark.Universe} {
+			for name := range dict {
+				names[name] = ""
+			}
+		}
+
+		return completeNames(partial, names)
+	}
+
+	v, ok := r.globals[parts[0]]
+	if !ok {
+		return nil
+	}
+
+	for _, name := range parts[1 : len(parts)-1] {
+		attrs, ok := v.(starlark.HasAttrs)
+		if !ok {
+			return nil
+		}
+
+		var err error
+		if v, err = attrs.Attr(name); err != nil || v == nil {
+			return nil
+		}
+	}
+
+	attrs, ok := v.(starlark.HasAttrs)
+	if !ok {
+		return nil
+	}
+
+	names := make(map[string]string)
+	for _, name := range attrs.AttrNames() {
+		names[name] = ""
+	}
+
+	return completeNames(partial, names)
+}
+
+func (r *REPL) printError(err error) {
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		fmt.Fprintln(r.Stderr, evalErr.Backtrace())
+		return
+	}
+
+	fmt.Fprintln(r.Stderr, err)
+}
+
+func completeNames(partial string, names map[string]string) []string {
+	var candidates []string
+	for name := range names {
+		if strings.HasPrefix(name, partial) {
+			candidates = append(candidates, name)
+		}
+	}
+
+	sort.Strings(candidates)
+	return candidates
+}
+
+func soleExpr(f *syntax.File) syntax.Expr {
+	if len(f.Stmts) == 1 {
+		if stmt, ok := f.Stmts[0].(*syntax.ExprStmt); ok {
+			return stmt.X
+		}
+	}
+
+	return nil
+}
+
+func isIdentifier(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// replCompleter implements readline.AutoCompleter using REPL.Complete.
+type replCompleter struct {
+	repl *REPL
+}
+
+// Do honors the readline.AutoCompleter interface.
+func (c *replCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	prefix := string(line[:pos])
+
+	var partial string
+	if i := strings.LastIndexFunc(prefix, func(r rune) bool {
+		return !isIdentifier(r) && r != ':'
+	}); i != -1 {
+		partial = prefix[i+1:]
+	} else {
+		partial = prefix
+	}
+
+	var candidates [][]rune
+	for _, name := range c.repl.Complete(prefix) {
+		candidates = append(candidates, []rune(strings.TrimPrefix(name, partial)))
+	}
+
+	return candidates, len([]rune(partial))
+}
@@ -0,0 +1,73 @@
+package runtime
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREPL(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	repl := NewRuntime(nil).NewREPL()
+	repl.Stdout = &stdout
+	repl.Stderr = &stderr
+
+	assert.NoError(t, repl.Exec("x = 1\ndef foo(a):\n    return a + x\n\nfoo(41)\n"))
+	assert.Equal(t, "42\n", stdout.String())
+	assert.Equal(t, "", stderr.String())
+
+	stdout.Reset()
+	assert.NoError(t, repl.Exec("b = backend(\"gcs\")\nb.bucket = \"tf-state\"\ntf.backend = b\n:hcl\n"))
+	assert.Contains(t, stdout.String(), "backend \"gcs\" {\n    bucket = \"tf-state\"\n  }")
+
+	stdout.Reset()
+	assert.NoError(t, repl.Exec(":help b\n"))
+	assert.Contains(t, stdout.String(), "b: Backend")
+	assert.Regexp(t, `bucket +string \(required\) +The name of the Google Cloud Storage bucket`, stdout.String())
+
+	stdout.Reset()
+	assert.NoError(t, repl.Exec(":help\n"))
+	assert.Contains(t, stdout.String(), ":load")
+
+	assert.NoError(t, repl.Exec("fail(\"foo\")\n:foo\n:help undefined\n"))
+	assert.Contains(t, stderr.String(), "Error in fail: fail: foo")
+	assert.Contains(t, stderr.String(), `unknown command ":foo"`)
+	assert.Contains(t, stderr.String(), "undefined: undefined")
+
+	// :quit stops the execution.
+	stdout.Reset()
+	assert.NoError(t, repl.Exec(":quit\nprint(1)\n"))
+	assert.Equal(t, "", stdout.String())
+}
+
+func TestREPLLoad(t *testing.T) {
+	dir := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, "lib.star"), "load(\"util.star\", \"bar\")\nfoo = bar + 1\n")
+	writeTestFile(t, filepath.Join(dir, "util.star"), "bar = 41\n")
+
+	var stdout bytes.Buffer
+
+	repl := NewRuntime(nil).NewREPL()
+	repl.Stdout = &stdout
+
+	assert.NoError(t, repl.Exec(":load "+filepath.Join(dir, "lib.star")+"\nfoo\n"))
+	assert.Equal(t, "42\n", stdout.String())
+}
+
+func TestREPLComplete(t *testing.T) {
+	repl := NewRuntime(nil).NewREPL()
+	assert.NoError(t, repl.Exec("b = backend(\"gcs\")\nbucket_name = \"foo\"\n"))
+
+	assert.Equal(t, []string{"b", "backend", "bool", "bucket_name", "bytes"}, repl.Complete("b"))
+	assert.Equal(t, []string{"bucket"}, repl.Complete("print(b.buc"))
+	assert.Equal(t, []string{":hcl", ":help"}, repl.Complete(":h"))
+	assert.Nil(t, repl.Complete("undefined.foo"))
+
+	c := &replCompleter{repl}
+	candidates, length := c.Do([]rune("print(b.buc"), 11)
+	assert.Equal(t, [][]rune{[]rune("ket")}, candidates)
+	assert.Equal(t, 3, length)
+}
@@ -2,12 +2,17 @@ package runtime
 
 import (
 	"fmt"
+	stdos "os"
 	osfilepath "path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/mcuadros/ascode/starlark/module/docker"
 	"github.com/mcuadros/ascode/starlark/module/filepath"
+	"github.com/mcuadros/ascode/starlark/module/functions"
 	"github.com/mcuadros/ascode/starlark/module/os"
 	"github.com/mcuadros/ascode/starlark/module/url"
+	"github.com/mcuadros/ascode/starlark/sandbox"
 	"github.com/mcuadros/ascode/starlark/types"
 	"github.com/mcuadros/ascode/terraform"
 	"github.com/qri-io/starlib/encoding/base64"
@@ -18,7 +23,6 @@ import (
 	"github.com/qri-io/starlib/math"
 	"github.com/qri-io/starlib/re"
 	"github.com/qri-io/starlib/time"
-	"go.starlark.net/repl"
 	"go.starlark.net/resolve"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
@@ -40,11 +44,25 @@ type LoadModuleFunc func() (starlark.StringDict, error)
 // Runtime represents the AsCode runtime, it defines the available modules,
 // the predeclared globals and handles how the `load` function behaves.
 type Runtime struct {
-	Terraform   *types.Terraform
+	Terraform *types.Terraform
+	// Vars contains the variables available to the scripts as `vars`.
+	Vars *types.Vars
+	// AllowStateWrites enables the persistence of changes made to a State.
+	AllowStateWrites bool
+	// Permissions defines the capabilities granted to the executed scripts,
+	// by default none is granted.
+	Permissions sandbox.Permissions
+	// Cache used to store the remote modules, loaded using `git::` or
+	// `https://` sources.
+	Cache *Cache
+
 	pm          *terraform.PluginManager
 	predeclared starlark.StringDict
 	modules     map[string]LoadModuleFunc
 	moduleCache map[string]*moduleCache
+	lock        *LockFile
+	packages    *Packages
+	globals     starlark.StringDict
 
 	path string
 }
@@ -52,11 +70,15 @@ type Runtime struct {
 // NewRuntime returns a new Runtime for the given terraform.PluginManager.
 func NewRuntime(pm *terraform.PluginManager) *Runtime {
 	tf := types.NewTerraform(pm)
+	vars := types.NewVars()
+
 	predeclared := starlark.StringDict{}
 	predeclared["tf"] = tf
+	predeclared["vars"] = vars
 	predeclared["provisioner"] = types.BuiltinProvisioner()
 	predeclared["backend"] = types.BuiltinBackend()
 	predeclared["validate"] = types.BuiltinValidate()
+	predeclared["diff"] = types.BuiltinDiff()
 	predeclared["hcl"] = types.BuiltinHCL()
 	predeclared["fn"] = types.BuiltinFunctionAttribute()
 	predeclared["ref"] = types.BuiltinRef()
@@ -66,12 +88,16 @@ func NewRuntime(pm *terraform.PluginManager) *Runtime {
 
 	return &Runtime{
 		Terraform:   tf,
+		Vars:        vars,
+		Cache:       &Cache{Dir: DefaultCacheDir()},
 		pm:          pm,
 		moduleCache: make(map[string]*moduleCache),
+		globals:     make(starlark.StringDict),
 		modules: map[string]LoadModuleFunc{
-			filepath.ModuleName: filepath.LoadModule,
-			os.ModuleName:       os.LoadModule,
-			docker.ModuleName:   docker.LoadModule,
+			filepath.ModuleName:  filepath.LoadModule,
+			os.ModuleName:        os.LoadModule,
+			docker.ModuleName:    docker.LoadModule,
+			functions.ModuleName: functions.LoadModule,
 
 			"encoding/json":   json.LoadModule,
 			"encoding/base64": base64.LoadModule,
@@ -80,35 +106,156 @@ func NewRuntime(pm *terraform.PluginManager) *Runtime {
 			"math":            math.LoadModule,
 			"re":              re.LoadModule,
 			"time":            time.LoadModule,
-			"http":            http.LoadModule,
+			"http":            sandbox.GuardModule(sandbox.Net, http.LoadModule),
 			"url":             url.LoadModule,
 		},
 		predeclared: predeclared,
 	}
 }
 
+// EntrypointFileName is the name of the file executed by ExecFiles when a
+// directory containing it is given.
+const EntrypointFileName = "main.star"
+
 // ExecFile parses, resolves, and executes a Starlark file.
 func (r *Runtime) ExecFile(filename string) (starlark.StringDict, error) {
+	return r.ExecSource(filename, nil)
+}
+
+// ExecSource parses, resolves, and executes the given Starlark source, as the
+// given filename, used to resolve the relative loads. If src is nil, the
+// source is read from the file. As starlark.ExecFile, the globals are
+// returned, even partially, in case of an evaluation error.
+func (r *Runtime) ExecSource(filename string, src interface{}) (starlark.StringDict, error) {
 	fullpath, _ := osfilepath.Abs(filename)
-	r.path, _ = osfilepath.Split(fullpath)
+	r.setPath(osfilepath.Dir(fullpath))
+
+	return starlark.ExecFile(r.NewThread("thread"), filename, src, r.predeclared)
+}
 
-	thread := &starlark.Thread{Name: "thread", Load: r.load}
+// NewThread returns a new starlark.Thread, with the given name, configured to
+// load the modules and to access the providers as the executed files.
+func (r *Runtime) NewThread(name string) *starlark.Thread {
+	thread := &starlark.Thread{Name: name, Load: r.load}
 	r.setLocals(thread)
 
-	return starlark.ExecFile(thread, filename, nil, r.predeclared)
+	return thread
 }
 
-// REPL executes a read, eval, print loop.
-func (r *Runtime) REPL() {
-	thread := &starlark.Thread{Name: "thread", Load: r.load}
-	r.setLocals(thread)
+// Load loads the given module, as a `load` statement of the last executed
+// file, returning the globals of the module.
+func (r *Runtime) Load(module string) (starlark.StringDict, error) {
+	return r.load(r.NewThread("load"), module)
+}
+
+// Predeclared returns the values predeclared to the executed files, such as
+// `tf` or `backend`.
+func (r *Runtime) Predeclared() starlark.StringDict {
+	return r.predeclared
+}
+
+// ExecFiles parses, resolves, and executes several Starlark files, or
+// directories, into the same Terraform. The files are executed in the given
+// order sharing the module cache, so a file already loaded by a previous one
+// is not executed again. An error is returned if any resource address is
+// defined more than once.
+func (r *Runtime) ExecFiles(paths ...string) error {
+	filenames, err := ExpandFiles(paths...)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range filenames {
+		r.setPath(osfilepath.Dir(filename))
+
+		globals, err := r.loadFile(r.NewThread("thread"), filename, false)
+		if err != nil {
+			return err
+		}
+
+		for name, v := range globals {
+			r.globals[name] = v
+		}
+	}
+
+	errs := r.Terraform.Duplicates()
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Errorf("%s", strings.Join(msgs, "\n"))
+}
+
+// Global returns the global value with the given name, defined by the files
+// executed by ExecFiles, a file overrides the globals of the previous ones.
+func (r *Runtime) Global(name string) (starlark.Value, bool) {
+	v, ok := r.globals[name]
+	return v, ok
+}
+
+// ExpandFiles returns the absolute path of the given files, the directories
+// are expanded to its entrypoint, `main.star`, if present, otherwise to all
+// the `.star` files contained, sorted by name.
+func ExpandFiles(paths ...string) ([]string, error) {
+	var filenames []string
+	for _, path := range paths {
+		path, err := osfilepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := stdos.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			filenames = append(filenames, path)
+			continue
+		}
+
+		entrypoint := osfilepath.Join(path, EntrypointFileName)
+		if _, err := stdos.Stat(entrypoint); err == nil {
+			filenames = append(filenames, entrypoint)
+			continue
+		}
+
+		matches, err := osfilepath.Glob(osfilepath.Join(path, "*.star"))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no .star files found", path)
+		}
+
+		sort.Strings(matches)
+		filenames = append(filenames, matches...)
+	}
+
+	return filenames, nil
+}
+
+func (r *Runtime) setPath(dir string) {
+	if r.path == dir {
+		return
+	}
 
-	repl.REPL(thread, r.predeclared)
+	r.path = dir
+	r.lock = nil
+	r.packages = nil
 }
 
 func (r *Runtime) setLocals(t *starlark.Thread) {
 	t.SetLocal("base_path", r.path)
 	t.SetLocal(types.PluginManagerLocal, r.pm)
+	t.SetLocal(types.AllowStateWritesLocal, r.AllowStateWrites)
+	t.SetLocal(sandbox.PermissionsLocal, &r.Permissions)
 }
 
 func (r *Runtime) load(t *starlark.Thread, module string) (starlark.StringDict, error) {
@@ -116,8 +263,172 @@ func (r *Runtime) load(t *starlark.Thread, module string) (starlark.StringDict,
 		return m()
 	}
 
+	if IsPackage(module) {
+		return r.loadPackage(t, module)
+	}
+
+	if IsRemoteSource(module) {
+		src, err := ParseSource(module)
+		if err != nil {
+			return nil, err
+		}
+
+		return r.loadRemote(t, src)
+	}
+
+	// relative loads from a remote module are resolved against its source.
+	if parent, ok := t.Local(remoteSourceLocal).(*Source); ok {
+		src, err := parent.Join(module)
+		if err != nil {
+			return nil, err
+		}
+
+		return r.loadRemote(t, src)
+	}
+
+	// relative loads from a package file are resolved against its directory.
+	if dir, ok := t.Local(packageDirLocal).(string); ok {
+		return r.loadFile(t, osfilepath.Join(dir, module), true)
+	}
+
 	filename := osfilepath.Join(r.path, module)
-	return r.loadFile(t, filename)
+	return r.loadFile(t, filename, false)
+}
+
+const (
+	remoteSourceLocal = "remote_source"
+	packageDirLocal   = "package_dir"
+)
+
+func (r *Runtime) loadRemote(thread *starlark.Thread, src *Source) (starlark.StringDict, error) {
+	key := src.String()
+	e, ok := r.moduleCache[key]
+	if e == nil {
+		if ok {
+			return nil, fmt.Errorf("cycle in load graph")
+		}
+
+		r.moduleCache[key] = nil
+
+		content, err := r.fetch(src)
+		if err != nil {
+			return nil, err
+		}
+
+		thread := &starlark.Thread{Name: "exec " + key, Load: thread.Load}
+		r.setLocals(thread)
+		thread.SetLocal(remoteSourceLocal, src)
+		globals, err := starlark.ExecFile(thread, key, content, r.predeclared)
+
+		e = &moduleCache{globals, err}
+		r.moduleCache[key] = e
+	}
+
+	return e.globals, e.err
+}
+
+// fetch returns the content of a remote module, the modules pinned at the lock
+// file are read from the cache or fetched at the locked commit, and verified
+// against the locked hash.
+func (r *Runtime) fetch(src *Source) ([]byte, error) {
+	lock, err := r.lockFile()
+	if err != nil {
+		return nil, err
+	}
+
+	key := src.String()
+	locked, ok := lock.Sources[key]
+	if ok {
+		if content, ok := r.Cache.Get(locked.Hash); ok {
+			return content, nil
+		}
+	}
+
+	var ref string
+	if ok {
+		ref = locked.Commit
+	}
+
+	if err := checkFetch(&r.Permissions, src.Kind, r.Cache.Offline); err != nil {
+		return nil, fmt.Errorf("%s: %s", key, err)
+	}
+
+	commit, content, err := r.Cache.Fetch(src, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := r.Cache.Put(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && locked.Hash != hash {
+		return nil, fmt.Errorf("%s: hash mismatch, locked %s, got %s", key, locked.Hash, hash)
+	}
+
+	if ok {
+		return content, nil
+	}
+
+	if err := r.Permissions.CheckWrite(lock.path); err != nil {
+		return nil, fmt.Errorf("%s: %s", key, err)
+	}
+
+	lock.Sources[key] = &LockedSource{Commit: commit, Hash: hash}
+	return content, lock.Write()
+}
+
+func (r *Runtime) lockFile() (*LockFile, error) {
+	if r.lock != nil {
+		return r.lock, nil
+	}
+
+	var err error
+	r.lock, err = ReadLockFile(osfilepath.Join(r.path, LockFileName))
+	return r.lock, err
+}
+
+func (r *Runtime) loadPackage(t *starlark.Thread, module string) (starlark.StringDict, error) {
+	name, path, err := ParsePackage(module)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.packages == nil {
+		lock, err := r.lockFile()
+		if err != nil {
+			return nil, err
+		}
+
+		r.packages, err = newPackages(r.path, r.Cache, lock)
+		if err != nil {
+			return nil, err
+		}
+
+		r.packages.permissions = &r.Permissions
+	}
+
+	filename, src, err := r.packages.Resolve(name, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if src != nil {
+		return r.loadRemote(t, src)
+	}
+
+	return r.loadFile(t, filename, true)
+}
+
+// DefaultCacheDir returns the default directory of the Cache.
+func DefaultCacheDir() string {
+	dir, err := stdos.UserCacheDir()
+	if err != nil {
+		dir = stdos.TempDir()
+	}
+
+	return osfilepath.Join(dir, "ascode")
 }
 
 type moduleCache struct {
@@ -125,7 +436,7 @@ type moduleCache struct {
 	err     error
 }
 
-func (r *Runtime) loadFile(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+func (r *Runtime) loadFile(thread *starlark.Thread, module string, pkg bool) (starlark.StringDict, error) {
 	e, ok := r.moduleCache[module]
 	if e == nil {
 		if ok {
@@ -137,6 +448,11 @@ func (r *Runtime) loadFile(thread *starlark.Thread, module string) (starlark.Str
 		r.moduleCache[module] = nil
 
 		thread := &starlark.Thread{Name: "exec " + module, Load: thread.Load}
+		r.setLocals(thread)
+		if pkg {
+			thread.SetLocal(packageDirLocal, osfilepath.Dir(module))
+		}
+
 		globals, err := starlark.ExecFile(thread, module, nil, r.predeclared)
 
 		e = &moduleCache{globals, err}
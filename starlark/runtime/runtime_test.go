@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,3 +12,73 @@ func TestLoad(t *testing.T) {
 	_, err := rc.ExecFile("testdata/load.star")
 	assert.NoError(t, err)
 }
+
+func TestSandbox(t *testing.T) {
+	rc := NewRuntime(nil)
+	_, err := rc.ExecFile("testdata/sandbox.star")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `command: missing capability "exec", use --allow-exec to enable it`)
+
+	rc = NewRuntime(nil)
+	rc.Permissions.Exec = true
+	_, err = rc.ExecFile("testdata/sandbox.star")
+	assert.NoError(t, err)
+}
+
+func TestVars(t *testing.T) {
+	rc := NewRuntime(nil)
+	assert.NoError(t, rc.Vars.SetFlag("region=eu-west-1"))
+	assert.NoError(t, rc.Vars.SetFlag("replicas=3"))
+
+	_, err := rc.ExecFile("testdata/vars.star")
+	assert.NoError(t, err)
+}
+
+func TestExpandFiles(t *testing.T) {
+	dir := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, "network", "vpc.star"), "")
+	writeTestFile(t, filepath.Join(dir, "network", "dns.star"), "")
+	writeTestFile(t, filepath.Join(dir, "network", "README.md"), "")
+	writeTestFile(t, filepath.Join(dir, "app", "main.star"), "")
+	writeTestFile(t, filepath.Join(dir, "app", "lib.star"), "")
+	writeTestFile(t, filepath.Join(dir, "extra.star"), "")
+
+	files, err := ExpandFiles(
+		filepath.Join(dir, "network"),
+		filepath.Join(dir, "app"),
+		filepath.Join(dir, "extra.star"),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "network", "dns.star"),
+		filepath.Join(dir, "network", "vpc.star"),
+		filepath.Join(dir, "app", "main.star"),
+		filepath.Join(dir, "extra.star"),
+	}, files)
+
+	_, err = ExpandFiles(filepath.Join(dir, "missing.star"))
+	assert.Error(t, err)
+
+	writeTestFile(t, filepath.Join(dir, "empty", "README.md"), "")
+	_, err = ExpandFiles(filepath.Join(dir, "empty"))
+	assert.Error(t, err)
+}
+
+func TestExecFiles(t *testing.T) {
+	dir := tempDir(t)
+	writeTestFile(t, filepath.Join(dir, "a.star"), "load(\"c.star\", \"c\")\na = c + 1\n")
+	writeTestFile(t, filepath.Join(dir, "b.star"), "load(\"a.star\", \"a\")\nif a != 2:\n    fail(\"unexpected a %d\" % a)\n")
+	writeTestFile(t, filepath.Join(dir, "c.star"), "c = 1\n")
+
+	rc := NewRuntime(nil)
+	assert.NoError(t, rc.ExecFiles(dir))
+	assert.Len(t, rc.moduleCache, 3)
+
+	writeTestFile(t, filepath.Join(dir, "d.star"), "fail(\"foo\")\n")
+
+	rc = NewRuntime(nil)
+	err := rc.ExecFiles(filepath.Join(dir, "c.star"), filepath.Join(dir, "d.star"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "foo")
+}
@@ -0,0 +1,244 @@
+// Package sandbox implements the capability-based permission model used by
+// the AsCode runtime, each module builtin performing a privileged operation
+// checks the Permissions stored in the thread before executing it.
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// PermissionsLocal is the key of the thread local containing the Permissions.
+const PermissionsLocal = "permissions"
+
+// Capability represents a privileged operation a script can perform.
+type Capability string
+
+// Capability constants.
+const (
+	// Exec allows the execution of external commands.
+	Exec Capability = "exec"
+	// Net allows network access.
+	Net Capability = "net"
+	// Write allows to write, rename and remove files.
+	Write Capability = "write"
+	// Env allows to read and write the environment variables.
+	Env Capability = "env"
+)
+
+// Permissions defines the capabilities granted to a script.
+type Permissions struct {
+	// Exec grants the Exec capability.
+	Exec bool
+	// Net grants the Net capability.
+	Net bool
+	// Env grants the Env capability.
+	Env bool
+	// Write is the list of directories where the Write capability is granted.
+	Write []string
+}
+
+// AllowAll returns a Permissions granting every capability.
+func AllowAll() *Permissions {
+	return &Permissions{Exec: true, Net: true, Env: true, Write: []string{"/"}}
+}
+
+// Check returns an error if the given capability is not granted.
+func (p *Permissions) Check(c Capability) error {
+	if p == nil {
+		return newMissingCapabilityError(c, "")
+	}
+
+	var granted bool
+	switch c {
+	case Exec:
+		granted = p.Exec
+	case Net:
+		granted = p.Net
+	case Env:
+		granted = p.Env
+	case Write:
+		granted = len(p.Write) != 0
+	}
+
+	if !granted {
+		return newMissingCapabilityError(c, "")
+	}
+
+	return nil
+}
+
+// CheckWrite returns an error if the given path is not contained in any of the
+// directories where the Write capability is granted. The symbolic links are
+// resolved, so a link inside a granted directory can't point outside it.
+func (p *Permissions) CheckWrite(path string) error {
+	target, err := resolvePath(path)
+	if err != nil {
+		return err
+	}
+
+	if p != nil {
+		for _, dir := range p.Write {
+			if isWithin(dir, target) {
+				return nil
+			}
+		}
+	}
+
+	return newMissingCapabilityError(Write, target)
+}
+
+func isWithin(dir, target string) bool {
+	dir, err := resolvePath(dir)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(dir, target)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolvePath returns the absolute path of the given path, with the symbolic
+// links resolved. Since the path may not exist yet, the links are resolved
+// in its nearest existing parent.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	var tail []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, tail...)...), nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, tail...)...), nil
+		}
+
+		tail = append([]string{filepath.Base(path)}, tail...)
+		path = parent
+	}
+}
+
+// MissingCapabilityError is returned when an operation requires a capability
+// not granted to the script.
+type MissingCapabilityError struct {
+	// Capability missing.
+	Capability Capability
+	// Path being written, only for the Write capability.
+	Path string
+}
+
+func newMissingCapabilityError(c Capability, path string) *MissingCapabilityError {
+	return &MissingCapabilityError{Capability: c, Path: path}
+}
+
+func (e *MissingCapabilityError) Error() string {
+	if e.Capability == Write && e.Path != "" {
+		return fmt.Sprintf("missing capability %q for %q, use --allow-write=<dir> to enable it", e.Capability, e.Path)
+	}
+
+	if e.Capability == Write {
+		return fmt.Sprintf("missing capability %q, use --allow-write=<dir> to enable it", e.Capability)
+	}
+
+	return fmt.Sprintf("missing capability %q, use --allow-%s to enable it", e.Capability, e.Capability)
+}
+
+// FromThread returns the Permissions stored in the given thread, if none
+// nil is returned, which doesn't grant any capability.
+func FromThread(t *starlark.Thread) *Permissions {
+	if t == nil {
+		return nil
+	}
+
+	p, _ := t.Local(PermissionsLocal).(*Permissions)
+	return p
+}
+
+// Check returns an error, prefixed by the name of the builtin, if the given
+// capability is not granted in the thread.
+func Check(t *starlark.Thread, b *starlark.Builtin, c Capability) error {
+	if err := FromThread(t).Check(c); err != nil {
+		return fmt.Errorf("%s: %w", b.Name(), err)
+	}
+
+	return nil
+}
+
+// CheckWrite returns an error, prefixed by the name of the builtin, if the
+// given paths can't be written with the permissions granted in the thread.
+func CheckWrite(t *starlark.Thread, b *starlark.Builtin, paths ...string) error {
+	p := FromThread(t)
+	for _, path := range paths {
+		if err := p.CheckWrite(path); err != nil {
+			return fmt.Errorf("%s: %w", b.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// Guard returns a copy of the builtin that requires the given capability to
+// be executed.
+func Guard(c Capability, b *starlark.Builtin) *starlark.Builtin {
+	return starlark.NewBuiltin(b.Name(), func(t *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := Check(t, b, c); err != nil {
+			return nil, err
+		}
+
+		return starlark.Call(t, b, args, kwargs)
+	})
+}
+
+// GuardModule returns a loader function returning the same modules as the
+// given one, where every builtin requires the given capability. Useful to
+// guard third-party modules, like `http`.
+func GuardModule(c Capability, load func() (starlark.StringDict, error)) func() (starlark.StringDict, error) {
+	return func() (starlark.StringDict, error) {
+		globals, err := load()
+		if err != nil {
+			return nil, err
+		}
+
+		output := make(starlark.StringDict, len(globals))
+		for name, value := range globals {
+			output[name] = guardValue(c, value)
+		}
+
+		return output, nil
+	}
+}
+
+func guardValue(c Capability, v starlark.Value) starlark.Value {
+	switch value := v.(type) {
+	case *starlark.Builtin:
+		return Guard(c, value)
+	case *starlarkstruct.Module:
+		members := make(starlark.StringDict, len(value.Members))
+		for name, member := range value.Members {
+			members[name] = guardValue(c, member)
+		}
+
+		return &starlarkstruct.Module{Name: value.Name, Members: members}
+	}
+
+	return v
+}
@@ -0,0 +1,83 @@
+package sandbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func TestPermissionsCheck(t *testing.T) {
+	var p *Permissions
+	assert.EqualError(t, p.Check(Exec), `missing capability "exec", use --allow-exec to enable it`)
+
+	p = &Permissions{Net: true}
+	assert.NoError(t, p.Check(Net))
+	assert.EqualError(t, p.Check(Env), `missing capability "env", use --allow-env to enable it`)
+	assert.EqualError(t, p.Check(Write), `missing capability "write", use --allow-write=<dir> to enable it`)
+
+	assert.NoError(t, AllowAll().Check(Exec))
+}
+
+func TestPermissionsCheckWrite(t *testing.T) {
+	p := &Permissions{Write: []string{"/foo/bar"}}
+	assert.NoError(t, p.CheckWrite("/foo/bar"))
+	assert.NoError(t, p.CheckWrite("/foo/bar/qux"))
+	assert.NoError(t, p.CheckWrite("/foo/bar/../bar/qux"))
+	assert.EqualError(t, p.CheckWrite("/foo/barqux"), `missing capability "write" for "/foo/barqux", use --allow-write=<dir> to enable it`)
+	assert.Error(t, p.CheckWrite("/foo/bar/../qux"))
+	assert.Error(t, p.CheckWrite("/foo"))
+}
+
+func TestPermissionsCheckWriteSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sandbox")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	allowed := filepath.Join(dir, "allowed")
+	outside := filepath.Join(dir, "outside")
+	assert.NoError(t, os.Mkdir(allowed, 0755))
+	assert.NoError(t, os.Mkdir(outside, 0755))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(allowed, "link")))
+
+	p := &Permissions{Write: []string{allowed}}
+	assert.NoError(t, p.CheckWrite(filepath.Join(allowed, "foo")))
+	assert.Error(t, p.CheckWrite(filepath.Join(allowed, "link")))
+	assert.Error(t, p.CheckWrite(filepath.Join(allowed, "link", "foo")))
+	assert.Error(t, p.CheckWrite(filepath.Join(allowed, "link", "qux", "foo")))
+
+	// the granted directory being a symbolic link itself.
+	p = &Permissions{Write: []string{filepath.Join(allowed, "link")}}
+	assert.NoError(t, p.CheckWrite(filepath.Join(outside, "foo")))
+}
+
+func TestGuardModule(t *testing.T) {
+	load := func() (starlark.StringDict, error) {
+		return starlark.StringDict{
+			"http": &starlarkstruct.Module{
+				Name: "http",
+				Members: starlark.StringDict{
+					"get": starlark.NewBuiltin("get", func(_ *starlark.Thread, _ *starlark.Builtin, _ starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+						return starlark.String("foo"), nil
+					}),
+				},
+			},
+		}, nil
+	}
+
+	globals, err := GuardModule(Net, load)()
+	assert.NoError(t, err)
+
+	thread := &starlark.Thread{}
+	_, err = starlark.Eval(thread, "test", `http.get()`, globals)
+	assert.EqualError(t, err, `get: missing capability "net", use --allow-net to enable it`)
+
+	thread.SetLocal(PermissionsLocal, &Permissions{Net: true})
+	v, err := starlark.Eval(thread, "test", `http.get()`, globals)
+	assert.NoError(t, err)
+	assert.Equal(t, starlark.String("foo"), v)
+}
@@ -2,9 +2,14 @@ package types
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/terraform/lang"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 // sTring alias required to avoid name collision with the method String.
@@ -57,6 +62,22 @@ func BuiltinRef() starlark.Value {
 //         that them can be assigned to other resource arguments of the same
 //         type. And, if the type is a list are indexable.
 //
+//         The string and number Attributes can be concatenated, using `+`, with
+//         strings, numbers and other Attributes, resulting in a string
+//         Attribute rendered as a HCL template. Eg.:
+//         `"arn:aws:s3:::" + bucket.id + "/*"` is rendered as
+//         `"arn:aws:s3:::${aws_s3_bucket.foo.id}/*"`. A string Attribute can
+//         be used as format, using `%`, with the same result. The number
+//         Attributes combined, using `+` or `%`, with numbers result in a
+//         number Attribute. Eg.: `${aws_instance.foo.cpu_core_count + 1}`.
+//
+//         A string formatted with Attributes, using `%` or `format`, eg.:
+//         `"arn:%s/*" % bucket.id`, results in a string containing the HCL
+//         interpolations of the Attributes, eg.:
+//         `"arn:${aws_s3_bucket.foo.id}/*"`. The resources and data sources
+//         referenced by the interpolations of the string values are
+//         dependencies, the same as the ones referenced by Attributes.
+//
 //         examples:
 //           attribute.star
 //
@@ -70,6 +91,12 @@ type Attribute struct {
 	t    cty.Type
 	name string
 	path string
+	// refs are the Attributes combined by a composite Attribute, such as a
+	// template or a function call, nil for the plain Attributes.
+	refs []*Attribute
+	// op is the operator of the composite number Attributes, see
+	// Attribute.Binary.
+	op syntax.Token
 
 	sString
 }
@@ -78,6 +105,7 @@ var _ starlark.Value = &Attribute{}
 var _ starlark.HasAttrs = &Attribute{}
 var _ starlark.Indexable = &Attribute{}
 var _ starlark.Comparable = &Attribute{}
+var _ starlark.HasBinary = &Attribute{}
 
 // NewAttribute returns a new Attribute for a given value or block of a Resource.
 // The path is calculated traversing the parents of the given Resource.
@@ -141,6 +169,10 @@ func (c *Attribute) InnerType() *Type {
 func (c *Attribute) Attr(name string) (starlark.Value, error) {
 	switch name {
 	case "__resource__":
+		if c.r == nil {
+			return starlark.None, nil
+		}
+
 		return c.r, nil
 	case "__type__":
 		return starlark.String(MustTypeFromCty(c.t).Starlark()), nil
@@ -155,8 +187,7 @@ func (c *Attribute) Attr(name string) (starlark.Value, error) {
 		return nil, starlark.NoSuchAttrError(errmsg)
 	}
 
-	path := fmt.Sprintf("%s.%s", c.path, name)
-	return NewAttributeWithPath(c.r, c.t.AttributeType(name), name, path), nil
+	return c.child(c.t.AttributeType(name), name, starlark.String(name)), nil
 }
 
 func (c *Attribute) String() string {
@@ -179,18 +210,18 @@ func (c *Attribute) doNested(name, path string, t cty.Type, index int) *Attribut
 
 // Index honors the starlark.Indexable interface.
 func (c *Attribute) Index(i int) starlark.Value {
-	path := fmt.Sprintf("%s.%d", c.path, i)
+	index := starlark.MakeInt(i)
 
 	if c.t.IsSetType() {
-		return NewAttributeWithPath(c.r, *c.t.SetElementType(), c.name, path)
+		return c.child(*c.t.SetElementType(), c.name, index)
 	}
 
 	if c.t.IsListType() {
-		return NewAttributeWithPath(c.r, *c.t.ListElementType(), c.name, path)
+		return c.child(*c.t.ListElementType(), c.name, index)
 	}
 
 	if c.t.IsMapType() {
-		return NewAttributeWithPath(c.r, c.t, c.name, path)
+		return c.child(c.t, c.name, index)
 	}
 
 	return starlark.None
@@ -211,13 +242,30 @@ func (c *Attribute) Get(key starlark.Value) (v starlark.Value, found bool, err e
 			return nil, false, fmt.Errorf("%s it's not a dict", c.name)
 		}
 
-		path := fmt.Sprintf("%s.%s", c.path, vKey.GoString())
-		return NewAttributeWithPath(c.r, c.t, c.name, path), true, nil
+		return c.child(c.t, c.name, vKey), true, nil
 	default:
 		return nil, false, fmt.Errorf("%s: unexpected key type %s", c.name, key.Type())
 	}
 }
 
+// child returns the Attribute of the given type, accessed from this one by
+// the given key. The key is written as `.key` or, for the composite
+// Attributes, as `["key"]`, since they aren't traversals.
+func (c *Attribute) child(t cty.Type, name string, key starlark.Value) *Attribute {
+	if c.refs == nil {
+		step := key.String()
+		if s, ok := key.(starlark.String); ok {
+			step = string(s)
+		}
+
+		return NewAttributeWithPath(c.r, t, name, c.path+"."+step)
+	}
+
+	a := NewAttributeWithPath(c.r, t, name, fmt.Sprintf("%s[%s]", c.path, key))
+	a.refs = c.refs
+	return a
+}
+
 // Len honors the starlark.Indexable interface.
 func (c *Attribute) Len() int {
 	if !c.t.IsSetType() && !c.t.IsListType() {
@@ -227,46 +275,304 @@ func (c *Attribute) Len() int {
 	return 1024
 }
 
+// Binary honors the starlark.HasBinary interface.
+func (c *Attribute) Binary(op syntax.Token, y starlark.Value, side starlark.Side) (starlark.Value, error) {
+	l, r := starlark.Value(c), y
+	if side == starlark.Right {
+		l, r = y, c
+	}
+
+	switch op {
+	case syntax.PLUS:
+		if isStringOperand(l) && (isStringOperand(r) || isNumberOperand(r)) ||
+			isStringOperand(r) && isNumberOperand(l) {
+			return newTemplateAttribute(template(l)+template(r), l, r), nil
+		}
+
+		if isNumberOperand(l) && isNumberOperand(r) {
+			return newExpressionAttribute(op, l, r), nil
+		}
+	case syntax.PERCENT:
+		if side == starlark.Left && c.t == cty.String {
+			v, err := starlark.Binary(op, c.sString, y)
+			if err != nil {
+				return nil, err
+			}
+
+			return newTemplateAttribute(string(v.(starlark.String)), c, y), nil
+		}
+
+		if isNumberOperand(l) && isNumberOperand(r) {
+			return newExpressionAttribute(op, l, r), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func isStringOperand(v starlark.Value) bool {
+	switch v := v.(type) {
+	case starlark.String:
+		return true
+	case *Attribute:
+		return v.t == cty.String
+	}
+
+	return false
+}
+
+func isNumberOperand(v starlark.Value) bool {
+	switch v := v.(type) {
+	case starlark.Int, starlark.Float:
+		return true
+	case *Attribute:
+		return v.t == cty.Number
+	}
+
+	return false
+}
+
+// template returns the given string or number operand as part of a HCL
+// template. The template sequences, `${` and `%{`, of the strings are escaped,
+// unless they contain interpolations, such as the strings formatted with
+// Attributes, same as the string values are written.
+func template(v starlark.Value) string {
+	switch v := v.(type) {
+	case starlark.String:
+		if containsInterpolation.MatchString(string(v)) {
+			return string(v)
+		}
+
+		return templateSequenceEscaper.Replace(string(v))
+	case *Attribute:
+		return string(v.sString)
+	}
+
+	return v.String()
+}
+
+// expression returns the given number operand as a HCL expression.
+func expression(v starlark.Value) string {
+	a, ok := v.(*Attribute)
+	if !ok {
+		return v.String()
+	}
+
+	if a.op != syntax.ILLEGAL {
+		return "(" + a.path + ")"
+	}
+
+	return a.path
+}
+
+var templateSequenceEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+
+var templateEscaper = strings.NewReplacer(
+	`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`,
+)
+
+// newTemplateAttribute returns a string Attribute rendered as the given
+// template, referencing the Attributes contained by the given values.
+func newTemplateAttribute(template string, values ...starlark.Value) *Attribute {
+	quoted := templateEscaper.Replace(template)
+	return newCompositeAttribute(cty.String, `"`+quoted+`"`, template, values)
+}
+
+// newExpressionAttribute returns a number Attribute rendered as the given
+// operation, referencing the Attributes of the given operands.
+func newExpressionAttribute(op syntax.Token, l, r starlark.Value) *Attribute {
+	path := fmt.Sprintf("%s %s %s", expression(l), op, expression(r))
+	a := newCompositeAttribute(cty.Number, path, "${"+path+"}", []starlark.Value{l, r})
+	a.op = op
+	return a
+}
+
+func newCompositeAttribute(t cty.Type, path, value string, values []starlark.Value) *Attribute {
+	a := &Attribute{
+		t:       t,
+		path:    path,
+		refs:    []*Attribute{},
+		sString: starlark.String(value),
+	}
+
+	for _, v := range values {
+		walkAttributes(v, func(ref *Attribute) {
+			a.refs = append(a.refs, ref)
+		})
+	}
+
+	// function calls, such as `timestamp()`, may not reference any resource.
+	if len(a.refs) != 0 {
+		a.r, a.name = a.refs[0].r, a.refs[0].name
+	}
+
+	return a
+}
+
 // BuiltinFunctionAttribute returns a built-in function that wraps Attributes
 // in HCL functions.
 //
 //   outline: types
 //     functions:
-//       fn(name, target) Attribute
-//         Fn wraps an Attribute in a HCL function. Since the Attributes value
+//       fn(name, *args) Attribute
+//         Fn wraps Attributes in a HCL function. Since the Attributes value
 //         are only available in the `apply` phase of Terraform, the only method
 //         to manipulate this values is using the Terraform
 //         [HCL functions](https://www.terraform.io/docs/configuration/functions.html).
 //
+//         The arguments can be Attributes, other `fn` calls or literal values,
+//         the number and type of the arguments are validated against the
+//         signature of the HCL function, and the returned Attribute has the
+//         type returned by it. Eg.: `fn("cidrsubnet", vpc.cidr_block, 8, 1)`
+//         is an `Attribute<string>`.
 //
 //         params:
 //           name string
 //             Name of the HCL function to be applied. Eg.: `base64encode`
-//           target Attribute
-//             Target Attribute of the HCL function.
+//           args
+//             Arguments of the HCL function.
 //
 func BuiltinFunctionAttribute() starlark.Value {
-	// TODO(mcuadros): implement multiple arguments support.
-	return starlark.NewBuiltin("fn", func(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-		var function starlark.String
-		var computed *Attribute
-		switch len(args) {
-		case 2:
-			var ok bool
-			function, ok = args.Index(0).(starlark.String)
-			if !ok {
-				return nil, fmt.Errorf("expected string, got %s", args.Index(0).Type())
+	return starlark.NewBuiltin("fn", func(t *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("unexpected positional arguments count")
+		}
+
+		name, ok := args.Index(0).(starlark.String)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %s", args.Index(0).Type())
+		}
+
+		return newFunctionAttribute(t, string(name), args[1:])
+	})
+}
+
+// newFunctionAttribute returns an Attribute calling the HCL function with the
+// given name, with the given arguments, validated against its signature.
+func newFunctionAttribute(t *starlark.Thread, name string, args starlark.Tuple) (*Attribute, error) {
+	f, ok := hclFunctions(t)[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown HCL function %q", name)
+	}
+
+	values := make([]cty.Value, len(args))
+	params := make([]string, len(args))
+	for i, arg := range args {
+		argType, err := hclType(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: argument %d: %s", name, i+1, err)
+		}
+
+		values[i] = cty.UnknownVal(argType)
+		params[i] = hclExpression(arg)
+	}
+
+	values, err := convertArguments(f, values)
+	if err != nil {
+		return nil, functionError(name, err)
+	}
+
+	typ, err := f.ReturnTypeForValues(values)
+	if err != nil {
+		return nil, functionError(name, err)
+	}
+
+	path := fmt.Sprintf("%s(%s)", name, strings.Join(params, ", "))
+	return newCompositeAttribute(typ, path, "${"+path+"}", args), nil
+}
+
+// functionError returns the given error, returned by the HCL function with
+// the given name, prefixed by the name and the argument causing it.
+func functionError(name string, err error) error {
+	if argErr, ok := err.(function.ArgError); ok {
+		return fmt.Errorf("%s: argument %d: %s", name, argErr.Index+1, err)
+	}
+
+	return fmt.Errorf("%s: %s", name, err)
+}
+
+// hclFunctionsLocal is the thread local caching the result of hclFunctions.
+const hclFunctionsLocal = "hcl_functions"
+
+// hclFunctions returns the HCL functions available in Terraform, used to
+// validate the arguments, and infer the type, of the `fn` calls. The paths
+// given to the file functions, such as `file` or `templatefile`, are relative
+// to the `base_path` thread local, as the Terraform module directory.
+func hclFunctions(t *starlark.Thread) map[string]function.Function {
+	if t == nil {
+		return (&lang.Scope{}).Functions()
+	}
+
+	if functions, ok := t.Local(hclFunctionsLocal).(map[string]function.Function); ok {
+		return functions
+	}
+
+	scope := &lang.Scope{}
+	if base, ok := t.Local("base_path").(string); ok {
+		scope.BaseDir = base
+	}
+
+	functions := scope.Functions()
+	t.SetLocal(hclFunctionsLocal, functions)
+	return functions
+}
+
+// hclType returns the type of the given value as a HCL expression, the
+// lists are tuples and the dicts are objects.
+func hclType(v starlark.Value) (cty.Type, error) {
+	switch v := v.(type) {
+	case *Attribute:
+		return v.t, nil
+	case starlark.String:
+		return cty.String, nil
+	case starlark.Int, starlark.Float:
+		return cty.Number, nil
+	case starlark.Bool:
+		return cty.Bool, nil
+	case starlark.NoneType:
+		return cty.DynamicPseudoType, nil
+	case starlark.Indexable:
+		types := make([]cty.Type, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			var err error
+			if types[i], err = hclType(v.Index(i)); err != nil {
+				return cty.NilType, err
 			}
+		}
 
-			computed, ok = args.Index(1).(*Attribute)
+		return cty.Tuple(types), nil
+	case *starlark.Dict:
+		types := make(map[string]cty.Type, v.Len())
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
 			if !ok {
-				return nil, fmt.Errorf("expected Attribute, got %s", args.Index(1).Type())
+				return cty.NilType, fmt.Errorf("expected string keys, got %s", item[0].Type())
 			}
-		default:
-			return nil, fmt.Errorf("unexpected positional arguments count")
+
+			t, err := hclType(item[1])
+			if err != nil {
+				return cty.NilType, err
+			}
+
+			types[string(key)] = t
 		}
 
-		path := fmt.Sprintf("%s(%s)", function.GoString(), computed.path)
-		return NewAttributeWithPath(computed.r, computed.t, computed.name, path), nil
-	})
+		return cty.Object(types), nil
+	}
+
+	return cty.NilType, fmt.Errorf("unexpected value type %s", v.Type())
+}
+
+// hclExpression returns the given value, validated by hclType, as a HCL
+// expression.
+func hclExpression(v starlark.Value) string {
+	if a, ok := v.(*Attribute); ok {
+		return a.path
+	}
+
+	if t, ok := v.(starlark.Tuple); ok {
+		v = starlark.NewList(t)
+	}
+
+	return string(hclwrite.Format(appendTokensForValue(v, nil).Bytes()))
 }
@@ -2,8 +2,141 @@ package types
 
 import (
 	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+	"go.starlark.net/starlark"
 )
 
 func TestAttribute(t *testing.T) {
 	doTest(t, "testdata/attribute.star")
 }
+
+func TestAttributeBinary(t *testing.T) {
+	p := newTestProvider("default")
+	foo := NewResource("foo", "null_resource", ResourceKind, &configschema.Block{}, p, p.Resource, nil)
+	bar := NewResource("bar", "null_resource", ResourceKind, &configschema.Block{}, p, p.Resource, nil)
+
+	predeclared := starlark.StringDict{
+		"id":    NewAttribute(foo, cty.String, "id"),
+		"other": NewAttribute(bar, cty.String, "id"),
+		"port":  NewAttribute(foo, cty.Number, "port"),
+		"count": NewAttribute(bar, cty.Number, "count"),
+	}
+
+	testCases := []struct {
+		expr, typ, str string
+	}{
+		{`"arn:aws:s3:::" + id + "/*"`, "Attribute<string>", `arn:aws:s3:::${null_resource.foo.id}/*`},
+		{`id + "-" + other`, "Attribute<string>", `${null_resource.foo.id}-${null_resource.bar.id}`},
+		{`id + 42`, "Attribute<string>", `${null_resource.foo.id}42`},
+		{`"port-" + port`, "Attribute<string>", `port-${null_resource.foo.port}`},
+		{`"%s:%d" % (id, 80)`, "string", `${null_resource.foo.id}:80`},
+		{`(id + "-%d") % 80`, "Attribute<string>", `${null_resource.foo.id}-80`},
+		{`"%s" + id % ()`, "Attribute<string>", `%s${null_resource.foo.id}`},
+		{`"{}:{}".format(id, port)`, "string", `${null_resource.foo.id}:${null_resource.foo.port}`},
+		{`("%s-" % id) + other`, "Attribute<string>", `${null_resource.foo.id}-${null_resource.bar.id}`},
+		{`"${" + id + "%{if}"`, "Attribute<string>", `$${${null_resource.foo.id}%%{if}`},
+		{`port + 1`, "Attribute<int>", `${null_resource.foo.port + 1}`},
+		{`1.5 + port`, "Attribute<int>", `${1.5 + null_resource.foo.port}`},
+		{`port % count`, "Attribute<int>", `${null_resource.foo.port % null_resource.bar.count}`},
+		{`(port + 1) + count`, "Attribute<int>", `${(null_resource.foo.port + 1) + null_resource.bar.count}`},
+		{`"n=" + (port + 1)`, "Attribute<string>", `n=${null_resource.foo.port + 1}`},
+	}
+
+	for _, tc := range testCases {
+		v, err := starlark.Eval(&starlark.Thread{}, "test.star", tc.expr, predeclared)
+		if !assert.NoError(t, err, tc.expr) {
+			continue
+		}
+
+		assert.Equal(t, tc.typ, v.Type(), tc.expr)
+		assert.Equal(t, tc.str, v.(interface{ GoString() string }).GoString(), tc.expr)
+	}
+}
+
+func TestAttributeBinaryHCL(t *testing.T) {
+	p := newTestProvider("default")
+	src := "" +
+		"foo = null.resource.resource(\"foo\")\n" +
+		"bar = null.resource.resource(\"bar\")\n" +
+		"bar.triggers = {\"arn\": \"arn:\\\"\" + foo.id + \"/*\", \"n\": \"n=\" + (foo.id + \"s\"), \"u\": fn(\"upper\", foo.id)}\n" +
+		"baz = null.resource.resource(\"baz\", name=\"%s/*\" % foo.id, triggers={\"bar\": \"{}\".format(bar.id), \"t\": \"%{\" + foo.id})\n"
+
+	_, err := starlark.ExecFile(&starlark.Thread{}, "test.star", src, starlark.StringDict{"null": p, "fn": BuiltinFunctionAttribute()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := hclwrite.NewEmptyFile()
+	p.resources.ToHCL(f.Body())
+	assert.Contains(t, string(f.Bytes()), ""+
+		"  triggers = { arn = \"arn:\\\"${null_resource.foo.id}/*\", n = \"n=${null_resource.foo.id}s\", u = upper(null_resource.foo.id) }\n")
+	assert.Contains(t, string(f.Bytes()), ""+
+		"  name     = \"${null_resource.foo.id}/*\"\n"+
+		"  triggers = { bar = \"${null_resource.bar.id}\", t = \"%%{${null_resource.foo.id}\" }\n")
+
+	g := newGraph(p.siblings())
+	assert.Len(t, g.Edges, 6)
+	assert.Equal(t, &GraphEdge{From: "null_resource.bar", To: "null_resource.foo", Kind: ReferenceEdge}, g.Edges[0])
+	assert.Equal(t, &GraphEdge{From: "null_resource.baz", To: "null_resource.bar", Kind: ReferenceEdge}, g.Edges[2])
+	assert.Equal(t, &GraphEdge{From: "null_resource.baz", To: "null_resource.foo", Kind: ReferenceEdge}, g.Edges[3])
+}
+
+func TestBuiltinFunctionAttribute(t *testing.T) {
+	p := newTestProvider("default")
+	foo := NewResource("foo", "null_resource", ResourceKind, &configschema.Block{}, p, p.Resource, nil)
+	bar := NewResource("bar", "null_resource", ResourceKind, &configschema.Block{}, p, p.Resource, nil)
+
+	predeclared := starlark.StringDict{
+		"fn":       BuiltinFunctionAttribute(),
+		"id":       NewAttribute(foo, cty.String, "id"),
+		"other":    NewAttribute(bar, cty.String, "id"),
+		"triggers": NewAttribute(foo, cty.Map(cty.String), "triggers"),
+	}
+
+	testCases := []struct {
+		expr, typ, str string
+	}{
+		{`fn("base64encode", id)`, "Attribute<string>", `${base64encode(null_resource.foo.id)}`},
+		{`fn("cidrsubnet", id, 8, 1)`, "Attribute<string>", `${cidrsubnet(null_resource.foo.id, 8, 1)}`},
+		{`fn("lookup", triggers, "k", "default")`, "Attribute<string>", `${lookup(null_resource.foo.triggers, "k", "default")}`},
+		{`fn("upper", fn("format", "%s-%s", id, other))`, "Attribute<string>", `${upper(format("%s-%s", null_resource.foo.id, null_resource.bar.id))}`},
+		{`fn("length", triggers) + 1`, "Attribute<int>", `${length(null_resource.foo.triggers) + 1}`},
+		{`fn("split", ",", id)[0]`, "Attribute<string>", `${split(",", null_resource.foo.id)[0]}`},
+		{`fn("keys", {"a": id})`, "Attribute<tuple>", `${keys({ a = "${null_resource.foo.id}" })}`},
+		{`fn("timestamp")`, "Attribute<string>", `${timestamp()}`},
+		{`fn("jsondecode", id)`, "Attribute<any>", `${jsondecode(null_resource.foo.id)}`},
+	}
+
+	for _, tc := range testCases {
+		v, err := starlark.Eval(&starlark.Thread{}, "test.star", tc.expr, predeclared)
+		if !assert.NoError(t, err, tc.expr) {
+			continue
+		}
+
+		assert.Equal(t, tc.typ, v.Type(), tc.expr)
+		assert.Equal(t, tc.str, v.(*Attribute).GoString(), tc.expr)
+	}
+
+	v, _ := starlark.Eval(&starlark.Thread{}, "test.star", `fn("coalesce", id, other, "")`, predeclared)
+	assert.Len(t, v.(*Attribute).refs, 2)
+	assert.Equal(t, foo, v.(*Attribute).r)
+
+	errors := []struct {
+		expr, err string
+	}{
+		{`fn()`, "unexpected positional arguments count"},
+		{`fn("foo", id)`, `unknown HCL function "foo"`},
+		{`fn("cidrsubnet", id)`, "cidrsubnet: wrong number of arguments (3 required; 1 given)"},
+		{`fn("upper", [id])`, "upper: argument 1: string required, but received tuple"},
+		{`fn("upper", {1: id})`, "upper: argument 1: expected string keys, got int"},
+	}
+
+	for _, tc := range errors {
+		_, err := starlark.Eval(&starlark.Thread{}, "test.star", tc.expr, predeclared)
+		assert.EqualError(t, err, tc.err, tc.expr)
+	}
+}
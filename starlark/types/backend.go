@@ -1,8 +1,8 @@
 package types
 
 import (
-	"encoding/json"
 	"fmt"
+	"sort"
 
 	"github.com/hashicorp/terraform/addrs"
 	"github.com/hashicorp/terraform/backend"
@@ -10,8 +10,8 @@ import (
 	"github.com/hashicorp/terraform/providers"
 	"github.com/hashicorp/terraform/states"
 	"github.com/hashicorp/terraform/states/statemgr"
+	"github.com/hashicorp/terraform/tfdiags"
 	"github.com/mcuadros/ascode/terraform"
-	"github.com/qri-io/starlib/util"
 	"go.starlark.net/starlark"
 )
 
@@ -90,6 +90,23 @@ func MakeBackend(
 //                 name of the module, empty equals to root.
 //               workspace string
 //                 backend workspace
+//           workspaces() list
+//             Returns the list of workspaces available in the backend.
+//           create_workspace(name)
+//             Creates a new workspace in the backend. Requires the flag
+//             `--allow-state-writes`.
+//             params:
+//               name string
+//                 name of the workspace to be created.
+//           delete_workspace(name, force=False)
+//             Deletes a workspace from the backend. Requires the flag
+//             `--allow-state-writes`.
+//             params:
+//               name string
+//                 name of the workspace to be deleted.
+//               force bool
+//                 if True the workspace is deleted even if it still contains
+//                 resources.
 type Backend struct {
 	pm *terraform.PluginManager
 	b  backend.Backend
@@ -121,6 +138,12 @@ func (b *Backend) Attr(name string) (starlark.Value, error) {
 	switch name {
 	case "state":
 		return starlark.NewBuiltin("state", b.state), nil
+	case "workspaces":
+		return starlark.NewBuiltin("workspaces", b.workspaces), nil
+	case "create_workspace":
+		return starlark.NewBuiltin("create_workspace", b.createWorkspace), nil
+	case "delete_workspace":
+		return starlark.NewBuiltin("delete_workspace", b.deleteWorkspace), nil
 	}
 
 	return b.Resource.Attr(name)
@@ -128,33 +151,50 @@ func (b *Backend) Attr(name string) (starlark.Value, error) {
 
 // AttrNames honors the starlark.HasAttrs interface.
 func (b *Backend) AttrNames() []string {
-	return append(b.Resource.AttrNames(), "state")
+	return append(b.Resource.AttrNames(),
+		"state", "workspaces", "create_workspace", "delete_workspace",
+	)
 }
 
-func (b *Backend) getStateMgr(workspace string) (statemgr.Full, error) {
+func (b *Backend) configure() error {
 	values, diag := b.b.PrepareConfig(b.values.Cty(b.b.ConfigSchema()))
 	if err := diag.Err(); err != nil {
-		return nil, err
+		return err
 	}
 
-	diag = b.b.Configure(values)
-	if err := diag.Err(); err != nil {
+	return b.b.Configure(values).Err()
+}
+
+// Workspaces returns the list of workspaces of the backend.
+func (b *Backend) Workspaces() ([]string, error) {
+	if err := b.configure(); err != nil {
 		return nil, err
 	}
 
-	workspaces, err := b.b.Workspaces()
+	return b.b.Workspaces()
+}
+
+func (b *Backend) hasWorkspace(workspace string) (bool, error) {
+	workspaces, err := b.Workspaces()
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	var found bool
 	for _, w := range workspaces {
 		if w == workspace {
-			found = true
-			break
+			return true, nil
 		}
 	}
 
+	return false, nil
+}
+
+func (b *Backend) getStateMgr(workspace string) (statemgr.Full, error) {
+	found, err := b.hasWorkspace(workspace)
+	if err != nil {
+		return nil, err
+	}
+
 	if !found {
 		return nil, fmt.Errorf("unable to find %q workspace", workspace)
 	}
@@ -162,6 +202,83 @@ func (b *Backend) getStateMgr(workspace string) (statemgr.Full, error) {
 	return b.b.StateMgr(workspace)
 }
 
+func (b *Backend) workspaces(
+	_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	if err := starlark.UnpackArgs("workspaces", args, kwargs); err != nil {
+		return nil, err
+	}
+
+	workspaces, err := b.Workspaces()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]starlark.Value, len(workspaces))
+	for i, w := range workspaces {
+		values[i] = starlark.String(w)
+	}
+
+	return starlark.NewList(values), nil
+}
+
+func (b *Backend) createWorkspace(
+	t *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	var name string
+	if err := starlark.UnpackArgs("create_workspace", args, kwargs, "name", &name); err != nil {
+		return nil, err
+	}
+
+	if err := checkStateWrites(t); err != nil {
+		return nil, err
+	}
+
+	found, err := b.hasWorkspace(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		return nil, fmt.Errorf("workspace %q already exists", name)
+	}
+
+	_, err = b.b.StateMgr(name)
+	return starlark.None, err
+}
+
+func (b *Backend) deleteWorkspace(
+	t *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	var name string
+	var force bool
+	err := starlark.UnpackArgs("delete_workspace", args, kwargs, "name", &name, "force?", &force)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkStateWrites(t); err != nil {
+		return nil, err
+	}
+
+	sm, err := b.getStateMgr(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !force {
+		if err := sm.RefreshState(); err != nil {
+			return nil, err
+		}
+
+		if state := sm.State(); state != nil && state.HasResources() {
+			return nil, fmt.Errorf("workspace %q is not empty, use force to delete it", name)
+		}
+	}
+
+	return starlark.None, b.b.DeleteWorkspace(name)
+}
+
 func (b *Backend) state(
 	_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
 ) (starlark.Value, error) {
@@ -174,6 +291,17 @@ func (b *Backend) state(
 		return nil, err
 	}
 
+	s, err := b.State(module, workspace)
+	if err != nil || s == nil {
+		return starlark.None, err
+	}
+
+	return s, nil
+}
+
+// State loads the latest state for a given module and workspace, returns nil
+// if the backend doesn't contain any state.
+func (b *Backend) State(module, workspace string) (*State, error) {
 	sm, err := b.getStateMgr(workspace)
 	if err != nil {
 		return nil, err
@@ -185,11 +313,16 @@ func (b *Backend) state(
 
 	state := sm.State()
 	if state == nil {
-		return starlark.None, nil
+		return nil, nil
 	}
 
-	return NewState(b.pm, module, state)
+	s, err := NewState(b.pm, module, state)
+	if err != nil {
+		return nil, err
+	}
 
+	s.sm = sm
+	return s, nil
 }
 
 // String honors the starlark.Value interface.
@@ -216,7 +349,9 @@ func (b *Backend) Type() string {
 //
 //         State implements a Dict, where the first level are the providers
 //         containing the keys `data` with the data sources and `resources` with
-//         the resources.
+//         the resources. The resources are decoded following the schema of
+//         the provider, behaving exactly as the ones defined in the
+//         configuration, including the nested blocks.
 //
 //         examples:
 //           backend_local.star
@@ -224,11 +359,58 @@ func (b *Backend) Type() string {
 //             count from the state.
 //
 //         fields:
+//           outputs dict
+//             Output values of the module.
+//           modules StateModules
+//             Child modules of the module, indexed by name. Eg.:
+//             `state.modules["network"].outputs`
 //           <provider> AttrDict
 //             provider and all the resources state.
+//
+//         methods:
+//           module(path) State
+//             Returns the State of a descendant module.
+//             params:
+//               path string
+//                 path of the module relative to the current one. Eg.:
+//                 `module.network.module.subnet`
+//           resources() list
+//             Returns the addresses of all the resource instances in the
+//             state, including the ones from child modules.
+//           mv(source, destination)
+//             Moves a resource or a resource instance to a new address, in
+//             the same way than `terraform state mv`. The change is only
+//             applied to the in-memory state until `persist` is called.
+//             The States of every module, even the ones already loaded,
+//             reflect the change, same as with `rm`.
+//             params:
+//               source string
+//                 address of the resource or resource instance to be moved.
+//               destination string
+//                 new address for the resource or resource instance.
+//           rm(address)
+//             Removes a resource or a resource instance from the state, in
+//             the same way than `terraform state rm`. The change is only
+//             applied to the in-memory state until `persist` is called.
+//             params:
+//               address string
+//                 address of the resource or resource instance to be removed.
+//           persist()
+//             Writes the state back to the backend. Requires the flag
+//             `--allow-state-writes`.
 type State struct {
 	*starlark.Dict
 	pm *terraform.PluginManager
+	sm statemgr.Full
+
+	state     *states.State
+	addr      addrs.ModuleInstance
+	providers map[string]*Provider
+	outputs   *starlark.Dict
+	modules   *StateModules
+	// views are all the States of the underlying state, the one of the root
+	// module and the ones of its descendants, reloaded after every change.
+	views *[]*State
 }
 
 var _ starlark.Value = &State{}
@@ -237,41 +419,92 @@ var _ starlark.Comparable = &State{}
 
 // NewState returns a new instance of State based on the given arguments,
 func NewState(pm *terraform.PluginManager, module string, state *states.State) (*State, error) {
-	var mod *states.Module
-	for _, m := range state.Modules {
-		if m.Addr.String() == module {
-			mod = m
+	addr := addrs.RootModuleInstance
+	if module != "" {
+		var diags tfdiags.Diagnostics
+		addr, diags = addrs.ParseModuleInstanceStr(module)
+		if err := diags.Err(); err != nil {
+			return nil, fmt.Errorf("invalid module address %q: %s", module, err)
 		}
 	}
 
+	return newState(pm, addr, state, make(map[string]*Provider, 0))
+}
+
+func newState(
+	pm *terraform.PluginManager, addr addrs.ModuleInstance, state *states.State,
+	providers map[string]*Provider,
+) (*State, error) {
+	mod := state.Module(addr)
 	if mod == nil {
-		return nil, fmt.Errorf("unable to find module with addr %q", module)
+		// modules without resources nor outputs aren't stored in the state, but
+		// we allow it if they contain child modules.
+		if len(stateChildModules(state, addr)) == 0 {
+			return nil, fmt.Errorf("unable to find module with addr %q", addr)
+		}
+
+		mod = states.NewModule(addr)
 	}
 
 	s := &State{
-		Dict: starlark.NewDict(0),
-		pm:   pm,
+		Dict:      starlark.NewDict(0),
+		pm:        pm,
+		state:     state,
+		addr:      addr,
+		providers: providers,
 	}
 
+	s.views = &[]*State{s}
 	return s, s.initialize(state, mod)
 }
 
 func (s *State) initialize(state *states.State, mod *states.Module) error {
-	providers := make(map[string]*Provider, 0)
-	addrs := state.ProviderAddrs()
-	for _, addr := range addrs {
-		typ := addr.ProviderConfig.Type.Type
-		p, err := NewProvider(s.pm, typ, "", addr.ProviderConfig.Alias, nil)
+	for _, r := range mod.Resources {
+		p, err := s.provider(r.ProviderConfig)
 		if err != nil {
 			return err
 		}
 
-		providers[addr.ProviderConfig.String()] = p
+		if err := s.initializeResource(p, r); err != nil {
+			return err
+		}
 	}
 
-	for _, r := range mod.Resources {
-		provider := r.ProviderConfig.String()
-		if err := s.initializeResource(providers[provider], r); err != nil {
+	return s.initializeOutputs(mod)
+}
+
+func (s *State) provider(addr addrs.AbsProviderConfig) (*Provider, error) {
+	key := addr.ProviderConfig.String()
+	if p, ok := s.providers[key]; ok {
+		return p, nil
+	}
+
+	typ := addr.ProviderConfig.Type.Type
+	p, err := NewProvider(s.pm, typ, "", addr.ProviderConfig.Alias, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.providers[key] = p
+	return p, nil
+}
+
+func (s *State) initializeOutputs(mod *states.Module) error {
+	s.outputs = starlark.NewDict(len(mod.OutputValues))
+
+	names := make([]string, 0, len(mod.OutputValues))
+	for name := range mod.OutputValues {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		v, err := ctyToStarlark(mod.OutputValues[name].Value)
+		if err != nil {
+			return fmt.Errorf("output %q: %s", name, err)
+		}
+
+		if err := s.outputs.SetKey(starlark.String(name), v); err != nil {
 			return err
 		}
 	}
@@ -302,21 +535,30 @@ func (s *State) initializeResource(p *Provider, r *states.Resource) error {
 		return fmt.Errorf("invalid resource type")
 	}
 
+	kind := ResourceKind
+	if r.Addr.Mode == addrs.DataResourceMode {
+		kind = DataSourceKind
+	}
+
+	ty := schema.Block.ImpliedType()
 	multi := r.EachMode != states.NoEach
-	for _, instance := range r.Instances {
-		r := NewResource(name, typ, ResourceKind, schema.Block, p, p.Resource, nil)
+	for _, key := range sortedInstanceKeys(r) {
+		instance := r.Instances[key]
+		if instance.Current == nil {
+			continue
+		}
 
-		var val interface{}
-		if err := json.Unmarshal(instance.Current.AttrsJSON, &val); err != nil {
-			return err
+		obj, err := instance.Current.Decode(ty)
+		if err != nil {
+			return fmt.Errorf("%s: %s", r.Addr.Instance(key), err)
 		}
 
-		values, _ := util.Marshal(val)
-		if err := r.loadDict(values.(*starlark.Dict)); err != nil {
-			return err
+		resource := NewResource(name, typ, kind, schema.Block, p, p.Resource, nil)
+		if err := resource.loadCty(obj.Value); err != nil {
+			return fmt.Errorf("%s: %s", r.Addr.Instance(key), err)
 		}
 
-		if err := s.set(mode, typ, name, r, multi); err != nil {
+		if err := s.set(mode, typ, name, resource, multi); err != nil {
 			return err
 		}
 	}
@@ -324,6 +566,25 @@ func (s *State) initializeResource(p *Provider, r *states.Resource) error {
 	return nil
 }
 
+func sortedInstanceKeys(r *states.Resource) []addrs.InstanceKey {
+	keys := make([]addrs.InstanceKey, 0, len(r.Instances))
+	for key := range r.Instances {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, aok := keys[i].(addrs.IntKey)
+		b, bok := keys[j].(addrs.IntKey)
+		if aok && bok {
+			return a < b
+		}
+
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+
+	return keys
+}
+
 func addrsResourceModeString(m addrs.ResourceMode) string {
 	switch m {
 	case addrs.ManagedResourceMode:
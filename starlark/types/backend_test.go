@@ -2,12 +2,95 @@ package types
 
 import (
 	"testing"
+
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/mcuadros/ascode/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+	"go.starlark.net/starlark"
 )
 
 func TestBackend(t *testing.T) {
 	doTest(t, "testdata/backend.star")
 }
 
+func TestBackendWritesDisabled(t *testing.T) {
+	thread := &starlark.Thread{}
+	thread.SetLocal(PluginManagerLocal, &terraform.PluginManager{})
+
+	predeclared := starlark.StringDict{"backend": BuiltinBackend()}
+	_, err := starlark.Eval(thread, "test", `backend("local").create_workspace("foo")`, predeclared)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "state writes are disabled")
+}
+
 func TestState(t *testing.T) {
 	doTest(t, "testdata/state.star")
 }
+
+func TestStateOutputs(t *testing.T) {
+	doTest(t, "testdata/state_outputs.star")
+}
+
+func TestResourceLoadCty(t *testing.T) {
+	block := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"name":  {Type: cty.String, Required: true},
+			"size":  {Type: cty.Number, Optional: true},
+			"ratio": {Type: cty.Number, Optional: true},
+			"zones": {Type: cty.Set(cty.String), Optional: true},
+		},
+		BlockTypes: map[string]*configschema.NestedBlock{
+			"network": {
+				Nesting:  configschema.NestingSingle,
+				MaxItems: 1,
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"cidr": {Type: cty.String, Optional: true},
+					},
+				},
+			},
+			"disk": {
+				Nesting: configschema.NestingList,
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"size": {Type: cty.Number, Optional: true},
+					},
+				},
+			},
+		},
+	}
+
+	v := cty.ObjectVal(map[string]cty.Value{
+		"name":    cty.StringVal("foo"),
+		"size":    cty.NumberIntVal(42),
+		"ratio":   cty.NumberFloatVal(0.5),
+		"zones":   cty.SetVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		"network": cty.ObjectVal(map[string]cty.Value{"cidr": cty.StringVal("10.0.0.0/8")}),
+		"disk": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"size": cty.NumberIntVal(10)}),
+			cty.ObjectVal(map[string]cty.Value{"size": cty.NumberIntVal(20)}),
+		}),
+	})
+
+	r := NewResource("foo", "test", ResourceKind, block, nil, nil, nil)
+	assert.NoError(t, r.loadCty(v))
+
+	assert.Equal(t, starlark.MakeInt(42), r.values.Get("size").Starlark())
+	assert.Equal(t, starlark.Float(0.5), r.values.Get("ratio").Starlark())
+	assert.Equal(t, `["a", "b"]`, r.values.Get("zones").Starlark().String())
+
+	network := r.values.Get("network").Starlark().(*Resource)
+	assert.Equal(t, starlark.String("10.0.0.0/8"), network.values.Get("cidr").Starlark())
+
+	disk := r.values.Get("disk").Starlark().(*ResourceCollection)
+	assert.Equal(t, 2, disk.Len())
+
+	found, err := disk.search(nil, nil, starlark.Tuple{starlark.String("size"), starlark.MakeInt(20)}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, found.(*starlark.List).Len())
+
+	unknown := cty.ObjectVal(map[string]cty.Value{"name": cty.UnknownVal(cty.String)})
+	err = NewResource("bar", "test", ResourceKind, block, nil, nil, nil).loadCty(unknown)
+	assert.EqualError(t, err, "name: unable to convert unknown value of type string")
+}
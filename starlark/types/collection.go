@@ -5,6 +5,7 @@ import (
 
 	"github.com/hashicorp/terraform/configs/configschema"
 	"github.com/mcuadros/ascode/terraform"
+	"github.com/zclconf/go-cty/cty"
 	"go.starlark.net/starlark"
 	"go.starlark.net/syntax"
 )
@@ -42,6 +43,20 @@ import (
 //               values dict
 //                 List of arguments and nested blocks to be set in the new
 //                 resource, these values can also be defined using `kwargs`.
+//           remove(resource)
+//             Removes the given resource from the collection. It fails if the
+//             resource is referenced by any other resource, using its
+//             attributes or `depends_on`.
+//             (Only in collections of kind "resource" and "data")
+//
+//             params:
+//               resource Resource
+//                 Resource to be removed.
+//           clear()
+//             Removes all the resources of the collection. It fails if any
+//             resource is referenced by a resource not contained in the
+//             collection.
+//             (Only in collections of kind "resource" and "data")
 //           search(key="id", value) list
 //             Return all the Resources with the given value in the given key.
 //
@@ -126,6 +141,15 @@ func (c *ResourceCollection) LoadList(l *starlark.List) error {
 	return nil
 }
 
+func (c *ResourceCollection) appendCty(v cty.Value) error {
+	r := NewResource("", c.typ, c.kind, c.block, c.provider, c.parent, nil)
+	if err := r.loadCty(v); err != nil {
+		return err
+	}
+
+	return c.List.Append(r)
+}
+
 // Path returns the path of the ResourceCollection.
 func (c *ResourceCollection) Path() string {
 	if c.parent != nil && c.parent.kind != ProviderKind {
@@ -135,6 +159,11 @@ func (c *ResourceCollection) Path() string {
 	return fmt.Sprintf("%s.%s.%s", c.provider.typ, c.kind, c.typ)
 }
 
+// Schema returns the schema of the resources of the collection.
+func (c *ResourceCollection) Schema() *configschema.Block {
+	return c.block
+}
+
 // String honors the starlark.Value interface.
 func (c *ResourceCollection) String() string {
 	return fmt.Sprintf("ResourceCollection<%s>", c.Path())
@@ -190,6 +219,14 @@ func (c *ResourceCollection) Attr(name string) (starlark.Value, error) {
 	switch name {
 	case "search":
 		return starlark.NewBuiltin("search", c.search), nil
+	case "remove":
+		if c.kind == ResourceKind || c.kind == DataSourceKind {
+			return starlark.NewBuiltin("remove", c.remove), nil
+		}
+	case "clear":
+		if c.kind == ResourceKind || c.kind == DataSourceKind {
+			return starlark.NewBuiltin("clear", c.clear), nil
+		}
 	case "__provider__":
 		if c.kind.IsProviderRelated() {
 			if c.provider == nil {
@@ -209,8 +246,80 @@ func (c *ResourceCollection) Attr(name string) (starlark.Value, error) {
 
 // AttrNames honors the starlark.HasAttrs interface.
 func (c *ResourceCollection) AttrNames() []string {
-	return append(c.List.AttrNames(),
-		"search", "__provider__", "__kind__", "__type__")
+	names := append(c.List.AttrNames(), "search")
+	if c.kind == ResourceKind || c.kind == DataSourceKind {
+		// remove and clear override the ones of the list, when present.
+		names = appendUnique(appendUnique(names, "remove"), "clear")
+	}
+
+	return append(names, "__provider__", "__kind__", "__type__")
+}
+
+func (c *ResourceCollection) remove(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var r *Resource
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &r); err != nil {
+		return nil, err
+	}
+
+	return starlark.None, c.doRemove(r)
+}
+
+func (c *ResourceCollection) clear(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+		return nil, err
+	}
+
+	return starlark.None, c.doRemove(c.resources()...)
+}
+
+// doRemove removes the given resources from the collection, failing if any of
+// them is referenced by a resource not being removed.
+func (c *ResourceCollection) doRemove(resources ...*Resource) error {
+	removed := make(map[*Resource]bool, len(resources))
+	for _, r := range resources {
+		removed[r] = true
+	}
+
+	var keep []*Resource
+	for _, r := range c.resources() {
+		if removed[r] {
+			delete(removed, r)
+			continue
+		}
+
+		keep = append(keep, r)
+	}
+
+	for _, r := range resources {
+		if removed[r] {
+			return fmt.Errorf("%s: not found in %s", r.Address(), c)
+		}
+	}
+
+	if err := removable(c.provider.siblings(), resources); err != nil {
+		return err
+	}
+
+	if err := c.List.Clear(); err != nil {
+		return err
+	}
+
+	for _, r := range keep {
+		if err := c.List.Append(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ResourceCollection) resources() []*Resource {
+	resources := make([]*Resource, c.Len())
+	for i := 0; i < c.Len(); i++ {
+		resources[i] = c.Index(i).(*Resource)
+	}
+
+	return resources
 }
 
 func (c *ResourceCollection) search(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
@@ -364,5 +473,20 @@ func (c *ProviderCollection) CallInternal(
 		return nil, err
 	}
 
+	p.collection = c
 	return v, nil
 }
+
+// providers returns all the providers sorted by type and name.
+func (c *ProviderCollection) providers() []*Provider {
+	var providers []*Provider
+	for _, typ := range c.Keys() {
+		d, _, _ := c.Get(typ)
+		for _, name := range d.(*Dict).Keys() {
+			p, _, _ := d.(*Dict).Get(name)
+			providers = append(providers, p.(*Provider))
+		}
+	}
+
+	return providers
+}
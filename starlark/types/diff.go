@@ -0,0 +1,357 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform/addrs"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// DifferenceAction describes how an argument differs between a Resource and
+// its state.
+type DifferenceAction string
+
+// DifferenceAction constants.
+const (
+	// Added arguments are defined in the configuration but not in the state.
+	Added DifferenceAction = "added"
+	// Removed arguments are present in the state but not in the configuration.
+	Removed DifferenceAction = "removed"
+	// Changed arguments have a different value in the configuration than in
+	// the state.
+	Changed DifferenceAction = "changed"
+)
+
+// Difference represents an argument or block that differs between a Resource
+// and its state.
+type Difference struct {
+	// Action kind of the difference.
+	Action DifferenceAction
+	// Path of the argument relative to the resource. Eg.: `tags.Name`.
+	Path string
+	// Config value of the argument at the configuration, if any.
+	Config starlark.Value
+	// State value of the argument at the state, if any.
+	State starlark.Value
+}
+
+func (d *Difference) String() string {
+	switch d.Action {
+	case Added:
+		return fmt.Sprintf("+ %s = %s", d.Path, diffValueString(d.Config))
+	case Removed:
+		return fmt.Sprintf("- %s = %s", d.Path, diffValueString(d.State))
+	}
+
+	return fmt.Sprintf("~ %s: %s => %s", d.Path,
+		diffValueString(d.State), diffValueString(d.Config),
+	)
+}
+
+func diffValueString(v starlark.Value) string {
+	switch value := v.(type) {
+	case *Resource:
+		return value.toDict().String()
+	case *ResourceCollection:
+		return value.toDict().String()
+	}
+
+	return v.String()
+}
+
+// Value returns the difference as a starlark.Value.
+func (d *Difference) Value() starlark.Value {
+	values := []starlark.Tuple{
+		{starlark.String("action"), starlark.String(d.Action)},
+		{starlark.String("path"), starlark.String(d.Path)},
+		{starlark.String("config"), noneIfNil(d.Config)},
+		{starlark.String("state"), noneIfNil(d.State)},
+	}
+
+	return starlarkstruct.FromKeywords(starlarkstruct.Default, values)
+}
+
+func noneIfNil(v starlark.Value) starlark.Value {
+	if v == nil {
+		return starlark.None
+	}
+
+	return v
+}
+
+// Differences represents a list of Differences.
+type Differences []*Difference
+
+// Value returns the differences as a starlark.Value.
+func (d Differences) Value() starlark.Value {
+	values := make([]starlark.Value, len(d))
+	for i, diff := range d {
+		values[i] = diff.Value()
+	}
+
+	return starlark.NewList(values)
+}
+
+// BuiltinDiff returns a starlak.Builtin function to compare a Resource against
+// the same resource loaded from a State.
+//
+//   outline: types
+//     functions:
+//       diff(resource, state_resource) list
+//         Returns a list with the differences between a resource and the same
+//         resource read from a State. A difference is a struct with four
+//         fields: `action` (`added`, `removed` or `changed`), `path`, `config`
+//         and `state`. The computed-only attributes are ignored.
+//         params:
+//           resource Resource
+//             resource defined in the configuration.
+//           state_resource Resource
+//             resource read from a State.
+//
+func BuiltinDiff() starlark.Value {
+	return starlark.NewBuiltin("diff", func(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var resource, state *Resource
+		err := starlark.UnpackArgs("diff", args, kwargs, "resource", &resource, "state_resource", &state)
+		if err != nil {
+			return nil, err
+		}
+
+		if resource.typ != state.typ {
+			return nil, fmt.Errorf("unable to compare %s with %s", resource.typ, state.typ)
+		}
+
+		diffs, err := resource.Diff(state)
+		if err != nil {
+			return nil, err
+		}
+
+		return diffs.Value(), nil
+	})
+}
+
+// Diff returns the differences between the Resource and the same resource
+// read from a State. The computed-only attributes are ignored, as well as
+// the optional computed attributes not defined by the Resource.
+func (r *Resource) Diff(state *Resource) (Differences, error) {
+	return r.doDiff("", state)
+}
+
+func (r *Resource) doDiff(prefix string, state *Resource) (diffs Differences, err error) {
+	names := make([]string, 0, len(r.block.Attributes)+len(r.block.BlockTypes))
+	for name := range r.block.Attributes {
+		names = append(names, name)
+	}
+
+	for name := range r.block.BlockTypes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		path := prefix + name
+
+		var config, current starlark.Value
+		if r.values.Has(name) {
+			config = r.values.Get(name).Starlark()
+		}
+
+		if state != nil && state.values.Has(name) {
+			current = state.values.Get(name).Starlark()
+		}
+
+		if attr, ok := r.block.Attributes[name]; ok {
+			if attr.Computed && (!attr.Optional || isEmptyValue(config)) {
+				continue
+			}
+
+			d, err := diffValue(path, config, current)
+			if err != nil {
+				return nil, err
+			}
+
+			if d != nil {
+				diffs = append(diffs, d)
+			}
+
+			continue
+		}
+
+		d, err := diffBlock(path, config, current)
+		if err != nil {
+			return nil, err
+		}
+
+		diffs = append(diffs, d...)
+	}
+
+	return diffs, nil
+}
+
+func diffValue(path string, config, state starlark.Value) (*Difference, error) {
+	if _, ok := config.(*Attribute); ok {
+		// the value of an attribute is unknown until the apply.
+		return nil, nil
+	}
+
+	switch {
+	case isEmptyValue(config) && isEmptyValue(state):
+		return nil, nil
+	case isEmptyValue(state):
+		return &Difference{Action: Added, Path: path, Config: config}, nil
+	case isEmptyValue(config):
+		return &Difference{Action: Removed, Path: path, State: state}, nil
+	}
+
+	eq, err := starlark.Equal(config, state)
+	if err != nil || eq {
+		return nil, err
+	}
+
+	return &Difference{Action: Changed, Path: path, Config: config, State: state}, nil
+}
+
+func diffBlock(path string, config, state starlark.Value) (Differences, error) {
+	switch c := config.(type) {
+	case *Resource:
+		s, _ := state.(*Resource)
+		if isEmptyValue(c) {
+			if isEmptyValue(s) {
+				return nil, nil
+			}
+
+			return Differences{{Action: Removed, Path: path, State: s}}, nil
+		}
+
+		if isEmptyValue(s) {
+			return Differences{{Action: Added, Path: path, Config: c}}, nil
+		}
+
+		return c.doDiff(path+".", s)
+	case *ResourceCollection:
+		s, _ := state.(*ResourceCollection)
+
+		var diffs Differences
+		for i := 0; i < c.Len(); i++ {
+			item := fmt.Sprintf("%s[%d]", path, i)
+			if s == nil || i >= s.Len() {
+				diffs = append(diffs, &Difference{Action: Added, Path: item, Config: c.Index(i)})
+				continue
+			}
+
+			d, err := c.Index(i).(*Resource).doDiff(item+".", s.Index(i).(*Resource))
+			if err != nil {
+				return nil, err
+			}
+
+			diffs = append(diffs, d...)
+		}
+
+		for i := c.Len(); s != nil && i < s.Len(); i++ {
+			item := fmt.Sprintf("%s[%d]", path, i)
+			diffs = append(diffs, &Difference{Action: Removed, Path: item, State: s.Index(i)})
+		}
+
+		return diffs, nil
+	case nil:
+		if isEmptyValue(state) {
+			return nil, nil
+		}
+
+		return Differences{{Action: Removed, Path: path, State: state}}, nil
+	}
+
+	return nil, fmt.Errorf("%s: unexpected value %s", path, config.Type())
+}
+
+func isEmptyValue(v starlark.Value) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case starlark.NoneType:
+		return true
+	case *Resource:
+		return value == nil || value.values.Len() == 0
+	case *ResourceCollection:
+		return value == nil || value.Len() == 0
+	case *starlark.List:
+		return value.Len() == 0
+	case *starlark.Dict:
+		return value.Len() == 0
+	}
+
+	return false
+}
+
+// Drift represents the differences between a Resource and its state.
+type Drift struct {
+	// Resource defined in the configuration.
+	Resource *Resource
+	// Instance is the key of the instance from the state, eg.: `[0]` or
+	// `["foo"]`, empty for the resources without `count` or `for_each`.
+	Instance string
+	// Missing is true when the Resource can't be found in the state.
+	Missing bool
+	// Differences between the Resource and its state.
+	Differences Differences
+}
+
+// Address returns the address of the drifted resource instance.
+func (d *Drift) Address() string {
+	return d.Resource.Address() + d.Instance
+}
+
+// Drift compares all the resources defined in Terraform with the ones found
+// in the given State, every instance of the resources with `count` or
+// `for_each` is compared with the resource. Only the resources missing from
+// the State or with differences are returned. The data sources are not
+// compared, since they are read again by every Terraform plan.
+func (t *Terraform) Drift(s *State) ([]*Drift, error) {
+	var drifts []*Drift
+	for _, typ := range t.p.Keys() {
+		providers, _, _ := t.p.Get(typ)
+		for _, name := range providers.(*Dict).Keys() {
+			p, _, _ := providers.(*Dict).Get(name)
+			d, err := p.(*Provider).drift(s)
+			if err != nil {
+				return nil, err
+			}
+
+			drifts = append(drifts, d...)
+		}
+	}
+
+	return drifts, nil
+}
+
+func (p *Provider) drift(s *State) ([]*Drift, error) {
+	var drifts []*Drift
+	for _, r := range p.resources.resources() {
+		instances := s.lookup(r)
+		if len(instances) == 0 {
+			drifts = append(drifts, &Drift{Resource: r, Missing: true})
+			continue
+		}
+
+		for _, instance := range instances {
+			diffs, err := r.Diff(instance.r)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(diffs) == 0 {
+				continue
+			}
+
+			d := &Drift{Resource: r, Differences: diffs}
+			if instance.key != addrs.NoKey {
+				d.Instance = instance.key.String()
+			}
+
+			drifts = append(drifts, d)
+		}
+	}
+
+	return drifts, nil
+}
@@ -0,0 +1,117 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/hashicorp/terraform/states"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+	"go.starlark.net/starlark"
+)
+
+func TestDiff(t *testing.T) {
+	doTest(t, "testdata/diff.star")
+}
+
+func TestResourceDiff(t *testing.T) {
+	block := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"id":       {Type: cty.String, Computed: true},
+			"name":     {Type: cty.String, Required: true},
+			"size":     {Type: cty.Number, Optional: true},
+			"zone":     {Type: cty.String, Optional: true, Computed: true},
+			"labels":   {Type: cty.Map(cty.String), Optional: true},
+			"replicas": {Type: cty.Number, Optional: true},
+		},
+		BlockTypes: map[string]*configschema.NestedBlock{
+			"disk": {
+				Nesting: configschema.NestingList,
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"size": {Type: cty.Number, Optional: true},
+					},
+				},
+			},
+		},
+	}
+
+	config := NewResource("foo", "test", ResourceKind, block, nil, nil, nil)
+	state := NewResource("foo", "test", ResourceKind, block, nil, nil, nil)
+
+	assert.NoError(t, config.SetField("name", starlark.String("foo")))
+	assert.NoError(t, config.SetField("size", starlark.MakeInt(42)))
+	assert.NoError(t, config.SetField("labels", starlark.NewDict(0)))
+	assert.NoError(t, config.SetField("disk", starlark.NewList([]starlark.Value{
+		newDict("size", starlark.MakeInt(10)),
+		newDict("size", starlark.MakeInt(20)),
+	})))
+
+	assert.NoError(t, state.doSetField("id", starlark.String("qux"), true))
+	assert.NoError(t, state.doSetField("zone", starlark.String("eu"), true))
+	assert.NoError(t, state.SetField("name", starlark.String("bar")))
+	assert.NoError(t, state.SetField("size", starlark.Float(42)))
+	assert.NoError(t, state.SetField("replicas", starlark.MakeInt(3)))
+	assert.NoError(t, state.SetField("disk", starlark.NewList([]starlark.Value{
+		newDict("size", starlark.MakeInt(15)),
+	})))
+
+	diffs, err := config.Diff(state)
+	assert.NoError(t, err)
+	assert.Len(t, diffs, 4)
+
+	assert.Equal(t, "~ disk[0].size: 15 => 10", diffs[0].String())
+	assert.Equal(t, "+ disk[1] = {\"size\": 20}", diffs[1].String())
+	assert.Equal(t, "~ name: \"bar\" => \"foo\"", diffs[2].String())
+	assert.Equal(t, "- replicas = 3", diffs[3].String())
+}
+
+func TestProviderDriftInstances(t *testing.T) {
+	p := newTestProvider("default")
+	src := "" +
+		"null.resource.resource(\"foo\", name=\"foo\")\n" +
+		"null.resource.resource(\"bar\", name=\"bar\")\n" +
+		"null.resource.resource(\"baz\", name=\"baz\")\n"
+
+	_, err := starlark.ExecFile(&starlark.Thread{}, "test.star", src, starlark.StringDict{"null": p})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := addrs.ProviderConfig{Type: addrs.NewLegacyProvider("null")}.Absolute(addrs.RootModuleInstance)
+	instance := func(s *states.SyncState, name string, key addrs.InstanceKey, attrs string) {
+		addr := addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "null_resource", Name: name}
+		s.SetResourceMeta(addr.Absolute(addrs.RootModuleInstance), eachModeForInstanceKey(key), provider)
+		s.SetResourceInstanceCurrent(addr.Instance(key).Absolute(addrs.RootModuleInstance),
+			&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(attrs)}, provider,
+		)
+	}
+
+	state := states.BuildState(func(s *states.SyncState) {
+		instance(s, "foo", addrs.IntKey(0), `{"id": "0", "name": "foo"}`)
+		instance(s, "foo", addrs.IntKey(1), `{"id": "1", "name": "qux"}`)
+		instance(s, "bar", addrs.StringKey("a"), `{"id": "a", "name": "bar"}`)
+	})
+
+	s, err := newState(nil, addrs.RootModuleInstance, state, map[string]*Provider{provider.ProviderConfig.String(): p})
+	assert.NoError(t, err)
+
+	drifts, err := p.drift(s)
+	assert.NoError(t, err)
+	assert.Len(t, drifts, 2)
+
+	assert.Equal(t, "null_resource.foo[1]", drifts[0].Address())
+	assert.False(t, drifts[0].Missing)
+	assert.Len(t, drifts[0].Differences, 1)
+	assert.Equal(t, "~ name: \"qux\" => \"foo\"", drifts[0].Differences[0].String())
+
+	assert.Equal(t, "null_resource.baz", drifts[1].Address())
+	assert.True(t, drifts[1].Missing)
+}
+
+func newDict(key string, value starlark.Value) *starlark.Dict {
+	d := starlark.NewDict(1)
+	d.SetKey(starlark.String(key), value)
+	return d
+}
@@ -0,0 +1,126 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"go.starlark.net/starlark"
+)
+
+// BuiltinHCLFunctions returns the HCL functions available in Terraform, as
+// built-in functions evaluated at execution time on the given values. The
+// values are converted using Value.Cty and the result back to Starlark, the
+// sets being lists, and the maps and objects being dicts.
+//
+// If any of the arguments contains an Attribute, since its value is unknown
+// until the `apply` phase of Terraform, the function is not evaluated and an
+// Attribute calling the function is returned, as `fn` does.
+func BuiltinHCLFunctions() starlark.StringDict {
+	names := hclFunctions(nil)
+	functions := make(starlark.StringDict, len(names))
+	for name := range names {
+		functions[name] = newHCLFunction(name)
+	}
+
+	return functions
+}
+
+// newHCLFunction returns the built-in of the HCL function with the given name,
+// resolved on every call, since it depends on the thread, see hclFunctions.
+func newHCLFunction(name string) *starlark.Builtin {
+	return starlark.NewBuiltin(name, func(t *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if len(kwargs) != 0 {
+			return nil, fmt.Errorf("%s: unexpected keyword arguments", name)
+		}
+
+		if isUnknown(args) {
+			return newFunctionAttribute(t, name, args)
+		}
+
+		f := hclFunctions(t)[name]
+
+		values := make([]cty.Value, len(args))
+		for i, arg := range args {
+			// hclType validates the value can be converted by Value.Cty.
+			if _, err := hclType(arg); err != nil {
+				return nil, fmt.Errorf("%s: argument %d: %s", name, i+1, err)
+			}
+
+			values[i] = MustValue(arg).Cty()
+		}
+
+		values, err := convertArguments(f, values)
+		if err != nil {
+			return nil, functionError(name, err)
+		}
+
+		result, err := f.Call(values)
+		if err != nil {
+			return nil, functionError(name, err)
+		}
+
+		return ctyToStarlark(result)
+	})
+}
+
+// convertArguments converts the given arguments to the types of the
+// parameters of the given function, as HCL does before calling it. Eg.: a
+// tuple of strings to a list of strings. The arguments exceeding the
+// parameters are kept, being reported by the function.
+func convertArguments(f function.Function, args []cty.Value) ([]cty.Value, error) {
+	params, varParam := f.Params(), f.VarParam()
+
+	converted := make([]cty.Value, len(args))
+	for i, arg := range args {
+		var param *function.Parameter
+		switch {
+		case i < len(params):
+			param = &params[i]
+		case varParam != nil:
+			param = varParam
+		default:
+			converted[i] = arg
+			continue
+		}
+
+		v, err := convert.Convert(arg, param.Type)
+		if err != nil {
+			// the conformance error, as the one of Function.ReturnType, also
+			// reports the received type.
+			if errs := arg.Type().TestConformance(param.Type); len(errs) != 0 {
+				err = errs[0]
+			}
+
+			return nil, function.NewArgError(i, err)
+		}
+
+		converted[i] = v
+	}
+
+	return converted, nil
+}
+
+// isUnknown returns true if the given value is, or contains, an Attribute.
+func isUnknown(v starlark.Value) bool {
+	switch v := v.(type) {
+	case *Attribute:
+		return true
+	case *starlark.List, starlark.Tuple:
+		list := v.(starlark.Indexable)
+		for i := 0; i < list.Len(); i++ {
+			if isUnknown(list.Index(i)) {
+				return true
+			}
+		}
+	case *starlark.Dict:
+		for _, item := range v.Items() {
+			if isUnknown(item[1]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
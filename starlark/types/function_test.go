@@ -0,0 +1,52 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+	"go.starlark.net/starlark"
+)
+
+func TestBuiltinHCLFunctions(t *testing.T) {
+	p := newTestProvider("default")
+	foo := NewResource("foo", "null_resource", ResourceKind, &configschema.Block{}, p, p.Resource, nil)
+
+	predeclared := BuiltinHCLFunctions()
+	predeclared["id"] = NewAttribute(foo, cty.String, "id")
+
+	testCases := []struct {
+		expr     string
+		expected starlark.Value
+	}{
+		{`cidrsubnet("10.0.0.0/16", 8, 1)`, starlark.String("10.0.1.0/24")},
+		{`join(",", ("a", "b"))`, starlark.String("a,b")},
+		{`concat([1], [2, "b"])`, starlark.NewList([]starlark.Value{starlark.MakeInt(1), starlark.MakeInt(2), starlark.String("b")})},
+		{`jsonencode({})`, starlark.String("{}")},
+		{`length(toset(["a", "a"]))`, starlark.MakeInt(1)},
+	}
+
+	for _, tc := range testCases {
+		v, err := starlark.Eval(&starlark.Thread{}, "test.star", tc.expr, predeclared)
+		if !assert.NoError(t, err, tc.expr) {
+			continue
+		}
+
+		assert.Equal(t, tc.expected.String(), v.String(), tc.expr)
+	}
+
+	v, err := starlark.Eval(&starlark.Thread{}, "test.star", `cidrsubnet(id, 8, 1)`, predeclared)
+	assert.NoError(t, err)
+	assert.Equal(t, "Attribute<string>", v.Type())
+	assert.Equal(t, "${cidrsubnet(null_resource.foo.id, 8, 1)}", v.(*Attribute).GoString())
+
+	v, err = starlark.Eval(&starlark.Thread{}, "test.star", `join(",", [id, "b"])`, predeclared)
+	assert.NoError(t, err)
+	assert.Equal(t, `${join(",", ["${null_resource.foo.id}", "b"])}`, v.(*Attribute).GoString())
+	thread := &starlark.Thread{}
+	thread.SetLocal("base_path", "testdata")
+	v, err = starlark.Eval(thread, "test.star", `fileexists("evaluate.star")`, predeclared)
+	assert.NoError(t, err)
+	assert.Equal(t, starlark.True, v)
+}
@@ -0,0 +1,685 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"go.starlark.net/starlark"
+)
+
+// Graph edge kinds.
+const (
+	// ReferenceEdge is an edge created by an Attribute of a resource used as
+	// value of another resource.
+	ReferenceEdge = "reference"
+	// DependsOnEdge is an edge created by `depends_on`.
+	DependsOnEdge = "depends_on"
+	// ProviderEdge is an edge from a resource or data source to its provider.
+	ProviderEdge = "provider"
+)
+
+// GraphNode is a provider, resource or data source of a Graph.
+type GraphNode struct {
+	// ID is the Terraform address of the node, eg.: `aws_instance.foo`,
+	// `data.aws_ami.foo` or `provider.aws.default`.
+	ID   string `json:"id"`
+	Kind Kind   `json:"kind"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+
+	r *Resource
+}
+
+// CallStack returns the call stack of the instantiation of the node.
+func (n *GraphNode) CallStack() starlark.CallStack {
+	return n.r.CallStack()
+}
+
+// GraphEdge is a dependency between two nodes of a Graph, From depends on To.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// Graph is the dependency graph of the providers, resources and data sources
+// defined by a Terraform.
+type Graph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+
+	nodes    map[*Resource]*GraphNode
+	ids      map[string]*GraphNode
+	edges    map[GraphEdge]bool
+	dangling []*danglingReference
+	seen     map[danglingReference]bool
+}
+
+// danglingReference is a reference to a resource not defined by the
+// Terraform, such as a resource loaded from a state.
+type danglingReference struct {
+	from *GraphNode
+	to   *Resource
+	kind string
+}
+
+// Graph returns the dependency graph of the providers, resources and data
+// sources. The edges are the references made by Attribute values, the
+// `depends_on` calls and the providers of each resource. References to
+// resources not defined by the Terraform are not part of the graph, they are
+// reported by Graph.Validate.
+func (t *Terraform) Graph() *Graph {
+	return newGraph(t.p.providers())
+}
+
+// siblings returns all the providers defined along with this one, including
+// itself.
+func (p *Provider) siblings() []*Provider {
+	if p.collection == nil {
+		return []*Provider{p}
+	}
+
+	return p.collection.providers()
+}
+
+func newGraph(providers []*Provider) *Graph {
+	g := &Graph{
+		nodes: make(map[*Resource]*GraphNode),
+		ids:   make(map[string]*GraphNode),
+		edges: make(map[GraphEdge]bool),
+		seen:  make(map[danglingReference]bool),
+	}
+
+	for _, p := range providers {
+		g.addNode(p.address(), p.Resource)
+	}
+
+	resources := definedResources(providers)
+	for _, r := range resources {
+		g.addNode(r.Address(), r)
+	}
+
+	for _, p := range providers {
+		p.Resource.references(func(to *Resource, address string) {
+			g.addReference(p.Resource, to, address, ReferenceEdge)
+		})
+	}
+
+	for _, r := range resources {
+		g.addEdge(r, r.provider.Resource, ProviderEdge)
+		r.walkDependencies(func(to *Resource, address, kind string) {
+			g.addReference(r, to, address, kind)
+		})
+	}
+
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+
+		if a.To != b.To {
+			return a.To < b.To
+		}
+
+		return a.Kind < b.Kind
+	})
+
+	return g
+}
+
+// definedResources returns the data sources and resources of the given
+// providers.
+func definedResources(providers []*Provider) []*Resource {
+	var resources []*Resource
+	for _, p := range providers {
+		resources = append(resources, p.dataSources.resources()...)
+		resources = append(resources, p.resources.resources()...)
+	}
+
+	return resources
+}
+
+// address returns the address of the provider as graph node.
+func (p *Provider) address() string {
+	return fmt.Sprintf("%s.%s.%s", ProviderKind, p.typ, p.name)
+}
+
+func (g *Graph) addNode(id string, r *Resource) {
+	if n, ok := g.ids[id]; ok {
+		g.nodes[r] = n
+		return
+	}
+
+	n := &GraphNode{ID: id, Kind: r.kind, Type: r.typ, Name: r.Name(), r: r}
+	if r.kind == ProviderKind {
+		n.Name = r.name
+	}
+
+	g.Nodes = append(g.Nodes, n)
+	g.nodes[r] = n
+	g.ids[id] = n
+}
+
+func (g *Graph) addEdge(from, to *Resource, kind string) {
+	f, ok := g.nodes[from]
+	if !ok {
+		return
+	}
+
+	t, ok := g.nodes[to]
+	if !ok {
+		g.addDangling(f, to, kind)
+		return
+	}
+
+	g.link(f, t, kind)
+}
+
+// addReference adds an edge to the given resource or, when nil, to the node
+// with the given address, if any.
+func (g *Graph) addReference(from, to *Resource, address, kind string) {
+	if to != nil {
+		g.addEdge(from, to, kind)
+		return
+	}
+
+	f, ok := g.nodes[from]
+	if !ok {
+		return
+	}
+
+	if t, ok := g.ids[address]; ok {
+		g.link(f, t, kind)
+	}
+}
+
+func (g *Graph) link(from, to *GraphNode, kind string) {
+	e := GraphEdge{From: from.ID, To: to.ID, Kind: kind}
+	if g.edges[e] {
+		return
+	}
+
+	g.edges[e] = true
+	g.Edges = append(g.Edges, &e)
+}
+
+func (g *Graph) addDangling(from *GraphNode, to *Resource, kind string) {
+	d := danglingReference{from: from, to: to, kind: kind}
+	if g.seen[d] {
+		return
+	}
+
+	g.seen[d] = true
+	g.dangling = append(g.dangling, &d)
+}
+
+// walkDependencies calls fn for every resource the given resource or data source
+// depends on: the ones referenced by its values, including its nested blocks
+// and provisioners, and the ones given to `depends_on`. The references made
+// by the interpolations of strings are given by address, see
+// Resource.references.
+func (r *Resource) walkDependencies(fn func(to *Resource, address, kind string)) {
+	reference := func(to *Resource, address string) {
+		fn(to, address, ReferenceEdge)
+	}
+
+	r.references(reference)
+	for _, dep := range r.dependencies {
+		fn(dep, "", DependsOnEdge)
+	}
+
+	for _, p := range r.provisioners {
+		p.Resource.references(reference)
+	}
+}
+
+// references calls fn for every resource referenced by the values of r,
+// including its nested blocks. The Attributes give the referenced resource,
+// while the interpolations of the strings, such as the ones formatted with
+// Attributes, eg.: `"arn:%s/*" % bucket.id`, give only its address.
+func (r *Resource) references(fn func(to *Resource, address string)) {
+	r.values.ForEach(func(v *NamedValue) error {
+		walkValues(v.Starlark(), func(v starlark.Value) {
+			switch v := v.(type) {
+			case *Attribute:
+				forEachRef(v, func(a *Attribute) {
+					if a.r != nil {
+						fn(a.r.root(), "")
+					}
+				})
+			case starlark.String:
+				for _, address := range interpolatedAddresses(string(v)) {
+					fn(nil, address)
+				}
+			}
+		})
+
+		return nil
+	})
+}
+
+// interpolatedAddresses returns the addresses of the resources and data
+// sources referenced by the interpolations of the given string, eg.:
+// `aws_instance.foo` for `"${aws_instance.foo.id}:80"`.
+func interpolatedAddresses(s string) []string {
+	if !containsInterpolation.MatchString(s) {
+		return nil
+	}
+
+	expr, diags := hclsyntax.ParseTemplate([]byte(s), "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil
+	}
+
+	var addresses []string
+	for _, traversal := range expr.Variables() {
+		parts := []string{traversal.RootName()}
+		length := 2
+		if parts[0] == string(DataSourceKind) {
+			length = 3
+		}
+
+		for _, step := range traversal[1:] {
+			attr, ok := step.(hcl.TraverseAttr)
+			if !ok || len(parts) == length {
+				break
+			}
+
+			parts = append(parts, attr.Name)
+		}
+
+		if len(parts) == length {
+			addresses = append(addresses, strings.Join(parts, "."))
+		}
+	}
+
+	return addresses
+}
+
+// root returns the resource, data source or provider containing the given
+// nested block.
+func (r *Resource) root() *Resource {
+	for r.parent != nil && r.kind == NestedKind {
+		r = r.parent
+	}
+
+	return r
+}
+
+// walkAttributes calls fn for every Attribute contained by the given value,
+// the composite Attributes are replaced by the Attributes combined by them.
+func walkAttributes(v starlark.Value, fn func(*Attribute)) {
+	walkValues(v, func(v starlark.Value) {
+		if a, ok := v.(*Attribute); ok {
+			forEachRef(a, fn)
+		}
+	})
+}
+
+// forEachRef calls fn with the given Attribute or, if composite, with every
+// Attribute combined by it.
+func forEachRef(a *Attribute, fn func(*Attribute)) {
+	if a.refs == nil {
+		fn(a)
+	}
+
+	for _, ref := range a.refs {
+		fn(ref)
+	}
+}
+
+// walkValues calls fn for every value, not being a container, contained by
+// the given value, including the values of the nested blocks.
+func walkValues(v starlark.Value, fn func(starlark.Value)) {
+	switch v := v.(type) {
+	case *starlark.List:
+		for i := 0; i < v.Len(); i++ {
+			walkValues(v.Index(i), fn)
+		}
+	case starlark.Tuple:
+		for _, e := range v {
+			walkValues(e, fn)
+		}
+	case *starlark.Dict:
+		for _, k := range v.Keys() {
+			e, _, _ := v.Get(k)
+			walkValues(e, fn)
+		}
+	case *Resource:
+		v.values.ForEach(func(e *NamedValue) error {
+			walkValues(e.Starlark(), fn)
+			return nil
+		})
+	case *ResourceCollection:
+		for i := 0; i < v.Len(); i++ {
+			walkValues(v.Index(i), fn)
+		}
+	default:
+		fn(v)
+	}
+}
+
+// Cycles returns the dependency cycles of the graph, every cycle is a list of
+// nodes, sorted as defined, where each one depends on the following one.
+func (g *Graph) Cycles() [][]*GraphNode {
+	edges := make(map[string][]string)
+	for _, e := range g.Edges {
+		edges[e.From] = append(edges[e.From], e.To)
+	}
+
+	nodes := make(map[string]*GraphNode, len(g.Nodes))
+	position := make(map[string]int, len(g.Nodes))
+	for i, n := range g.Nodes {
+		nodes[n.ID] = n
+		position[n.ID] = i
+	}
+
+	// Tarjan's strongly connected components algorithm.
+	var (
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		cycles  [][]*GraphNode
+		connect func(id string)
+	)
+
+	connect = func(id string) {
+		index[id] = len(index)
+		lowlink[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, to := range edges[id] {
+			if _, ok := index[to]; !ok {
+				connect(to)
+				lowlink[id] = minInt(lowlink[id], lowlink[to])
+			} else if onStack[to] {
+				lowlink[id] = minInt(lowlink[id], index[to])
+			}
+		}
+
+		if lowlink[id] != index[id] {
+			return
+		}
+
+		var component []string
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == id {
+				break
+			}
+		}
+
+		if len(component) == 1 && !contains(edges[id], id) {
+			return
+		}
+
+		cycle := shortestCycle(component, edges, position)
+		list := make([]*GraphNode, len(cycle))
+		for i, id := range cycle {
+			list[i] = nodes[id]
+		}
+
+		cycles = append(cycles, list)
+	}
+
+	for _, n := range g.Nodes {
+		if _, ok := index[n.ID]; !ok {
+			connect(n.ID)
+		}
+	}
+
+	sort.SliceStable(cycles, func(i, j int) bool {
+		return position[cycles[i][0].ID] < position[cycles[j][0].ID]
+	})
+
+	return cycles
+}
+
+// shortestCycle returns the shortest cycle of a strongly connected component
+// starting by its first defined node.
+func shortestCycle(component []string, edges map[string][]string, position map[string]int) []string {
+	in := make(map[string]bool, len(component))
+	first := component[0]
+	for _, id := range component {
+		in[id] = true
+		if position[id] < position[first] {
+			first = id
+		}
+	}
+
+	prev := make(map[string]string)
+	queue := []string{first}
+	for len(queue) != 0 && prev[first] == "" {
+		id := queue[0]
+		queue = queue[1:]
+		for _, to := range edges[id] {
+			if _, ok := prev[to]; !in[to] || ok {
+				continue
+			}
+
+			prev[to] = id
+			queue = append(queue, to)
+		}
+	}
+
+	cycle := []string{first}
+	for id := prev[first]; id != first; id = prev[id] {
+		cycle = append(cycle, id)
+	}
+
+	for i, j := 1, len(cycle)-1; i < j; i, j = i+1, j-1 {
+		cycle[i], cycle[j] = cycle[j], cycle[i]
+	}
+
+	return cycle
+}
+
+// removable returns an error if any of the given resources is referenced by
+// a resource, data source or provider, of the given providers, not contained
+// in the list. Only the references to the removed resources are collected,
+// without building the Graph.
+func removable(providers []*Provider, resources []*Resource) error {
+	removed := make(map[string]bool, len(resources))
+	for _, r := range resources {
+		removed[r.Address()] = true
+	}
+
+	var refs []GraphEdge
+	reference := func(from string) func(*Resource, string, string) {
+		return func(to *Resource, address, _ string) {
+			if to != nil {
+				address = to.Address()
+			}
+
+			if removed[address] {
+				refs = append(refs, GraphEdge{From: from, To: address})
+			}
+		}
+	}
+
+	for _, p := range providers {
+		fn := reference(p.address())
+		p.Resource.references(func(to *Resource, address string) {
+			fn(to, address, ReferenceEdge)
+		})
+	}
+
+	for _, r := range definedResources(providers) {
+		if !removed[r.Address()] {
+			r.walkDependencies(reference(r.Address()))
+		}
+	}
+
+	if len(refs) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].From != refs[j].From {
+			return refs[i].From < refs[j].From
+		}
+
+		return refs[i].To < refs[j].To
+	})
+
+	to := refs[0].To
+	var from []string
+	for _, e := range refs {
+		if e.To == to && !contains(from, e.From) {
+			from = append(from, e.From)
+		}
+	}
+
+	return fmt.Errorf("can't remove %s, referenced by %s", to, strings.Join(from, ", "))
+}
+
+// Validate honors the Validabler interface. A ValidationError is returned for
+// every reference to a resource not defined by the Terraform, and for every
+// dependency cycle, containing the call stacks of its participants.
+func (g *Graph) Validate() (errs ValidationErrors) {
+	for _, d := range g.dangling {
+		err := NewValidationError(d.from.CallStack(),
+			"%s: reference to %q, not defined by any provider", d.from.r, d.to.Address(),
+		)
+
+		if d.kind == DependsOnEdge {
+			err.Msg = fmt.Sprintf("%s: depends on %q, not defined by any provider", d.from.r, d.to.Address())
+		}
+
+		err.Related = ValidationErrors{
+			{Msg: d.to.Address(), CallStack: d.to.CallStack()},
+		}
+
+		errs = append(errs, err)
+	}
+
+	for _, cycle := range g.Cycles() {
+		ids := make([]string, len(cycle)+1)
+		related := make(ValidationErrors, len(cycle))
+		for i, n := range cycle {
+			ids[i] = n.ID
+			related[i] = &ValidationError{Msg: n.ID, CallStack: n.CallStack()}
+		}
+
+		ids[len(cycle)] = cycle[0].ID
+		err := NewValidationError(cycle[0].CallStack(),
+			"dependency cycle: %s", strings.Join(ids, " -> "),
+		)
+
+		err.Related = related
+		errs = append(errs, err)
+	}
+
+	return
+}
+
+// DOT returns the graph in the Graphviz DOT language.
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph {\n")
+	b.WriteString("  rankdir = \"RL\";\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "  %q [label=%q, shape=%s];\n", n.ID, n.ID, dotShape(n.Kind))
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %q -> %q", e.From, e.To)
+		if e.Kind != ReferenceEdge {
+			fmt.Fprintf(&b, " [style=dashed, label=%q]", e.Kind)
+		}
+
+		b.WriteString(";\n")
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func dotShape(k Kind) string {
+	switch k {
+	case ProviderKind:
+		return "diamond"
+	case DataSourceKind:
+		return "note"
+	}
+
+	return "box"
+}
+
+// Mermaid returns the graph as a Mermaid flowchart.
+func (g *Graph) Mermaid() string {
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart RL\n")
+	for _, n := range g.Nodes {
+		open, close := "[", "]"
+		switch n.Kind {
+		case ProviderKind:
+			open, close = "{{", "}}"
+		case DataSourceKind:
+			open, close = "[(", ")]"
+		}
+
+		fmt.Fprintf(&b, "  %s%s\"%s\"%s\n", ids[n.ID], open, n.ID, close)
+	}
+
+	for _, e := range g.Edges {
+		if e.Kind == ReferenceEdge {
+			fmt.Fprintf(&b, "  %s --> %s\n", ids[e.From], ids[e.To])
+			continue
+		}
+
+		fmt.Fprintf(&b, "  %s -. %s .-> %s\n", ids[e.From], e.Kind, ids[e.To])
+	}
+
+	return b.String()
+}
+
+// JSON returns the graph encoded as JSON.
+func (g *Graph) JSON() ([]byte, error) {
+	nodes, edges := g.Nodes, g.Edges
+	if nodes == nil {
+		nodes = []*GraphNode{}
+	}
+
+	if edges == nil {
+		edges = []*GraphEdge{}
+	}
+
+	return json.MarshalIndent(struct {
+		Nodes []*GraphNode `json:"nodes"`
+		Edges []*GraphEdge `json:"edges"`
+	}{nodes, edges}, "", "  ")
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
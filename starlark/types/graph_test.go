@@ -0,0 +1,194 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/hashicorp/terraform/providers"
+	"github.com/mcuadros/ascode/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+	"go.starlark.net/starlark"
+)
+
+func TestTerraformGraph(t *testing.T) {
+	pm := &terraform.PluginManager{Path: ".providers"}
+	tf := NewTerraform(pm)
+
+	thread := &starlark.Thread{}
+	thread.SetLocal(PluginManagerLocal, pm)
+
+	_, err := starlark.ExecFile(thread, "testdata/graph.star", nil, starlark.StringDict{"tf": tf})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := tf.Graph()
+
+	var ids []string
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+
+	assert.Equal(t, []string{
+		"provider.null.default",
+		"data.null_data_source.data",
+		"null_resource.bar",
+		"null_resource.foo",
+	}, ids)
+
+	assert.Equal(t, []*GraphEdge{
+		{From: "data.null_data_source.data", To: "provider.null.default", Kind: ProviderEdge},
+		{From: "null_resource.bar", To: "data.null_data_source.data", Kind: DependsOnEdge},
+		{From: "null_resource.bar", To: "null_resource.foo", Kind: ReferenceEdge},
+		{From: "null_resource.bar", To: "provider.null.default", Kind: ProviderEdge},
+		{From: "null_resource.foo", To: "data.null_data_source.data", Kind: ReferenceEdge},
+		{From: "null_resource.foo", To: "provider.null.default", Kind: ProviderEdge},
+	}, g.Edges)
+
+	assert.Len(t, g.Cycles(), 0)
+}
+
+// newTestProvider returns a Provider, not backed by any plugin, defining the
+// resource `null_resource` with a `triggers` argument, and the nested blocks
+// `timeouts`, capped to one item, and `rule`.
+func newTestProvider(name string) *Provider {
+	p := &Provider{}
+	p.Resource = NewResource(name, "null", ProviderKind, &configschema.Block{}, p, nil, nil)
+	p.dataSources = NewResourceCollectionGroup(p, DataSourceKind, nil)
+	p.resources = NewResourceCollectionGroup(p, ResourceKind, map[string]providers.Schema{
+		"null_resource": {Block: &configschema.Block{
+			Attributes: map[string]*configschema.Attribute{
+				"id":       {Type: cty.String, Computed: true},
+				"triggers": {Type: cty.Map(cty.String), Optional: true},
+				"name":     {Type: cty.String, Optional: true},
+			},
+			BlockTypes: map[string]*configschema.NestedBlock{
+				"timeouts": {Nesting: configschema.NestingList, MaxItems: 1, Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"create": {Type: cty.String, Optional: true},
+					},
+				}},
+				"rule": {Nesting: configschema.NestingList, Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"port": {Type: cty.Number, Optional: true},
+					},
+				}},
+			},
+		}},
+	})
+
+	return p
+}
+
+func TestGraphValidate(t *testing.T) {
+	tf := NewTerraform(nil)
+	p := newTestProvider("default")
+	tf.p.SetKey(starlark.String("null"), NewDict())
+	providers, _, _ := tf.p.Get(starlark.String("null"))
+	providers.(*Dict).SetKey(starlark.String("default"), p)
+
+	src := "" +
+		"foo = null.resource.resource(\"foo\")\n" +
+		"qux = other.resource.resource(\"qux\")\n" +
+		"bar = null.resource.resource(\"bar\", triggers={\"foo\": foo.id, \"qux\": qux.id})\n" +
+		"foo.triggers = {\"bar\": bar.id}\n" +
+		"bar.depends_on(qux)\n"
+
+	predeclared := starlark.StringDict{"null": p, "other": newTestProvider("other")}
+	_, err := starlark.ExecFile(&starlark.Thread{}, "test.star", src, predeclared)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := tf.Validate()
+	assert.Len(t, errs, 3)
+	assert.Equal(t, ""+
+		"test.star:3:29: Resource<null.resource.null_resource>: reference to \"null_resource.qux\", not defined by any provider\n"+
+		"null_resource.qux:\n"+
+		"Traceback (most recent call last):\n"+
+		"  test.star:2:30: in <toplevel>", errs[0].Backtrace())
+
+	assert.Equal(t, ""+
+		"test.star:3:29: Resource<null.resource.null_resource>: depends on \"null_resource.qux\", not defined by any provider", errs[1].Error())
+
+	assert.Equal(t, ""+
+		"test.star:1:29: dependency cycle: null_resource.foo -> null_resource.bar -> null_resource.foo\n"+
+		"null_resource.foo:\n"+
+		"Traceback (most recent call last):\n"+
+		"  test.star:1:29: in <toplevel>\n"+
+		"null_resource.bar:\n"+
+		"Traceback (most recent call last):\n"+
+		"  test.star:3:29: in <toplevel>", errs[2].Backtrace())
+}
+
+func TestGraphCycles(t *testing.T) {
+	g := &Graph{
+		Nodes: []*GraphNode{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}, {ID: "e"}},
+		Edges: []*GraphEdge{
+			{From: "a", To: "b"},
+			{From: "b", To: "c"},
+			{From: "c", To: "a"},
+			{From: "c", To: "d"},
+			{From: "e", To: "e"},
+		},
+	}
+
+	var cycles [][]string
+	for _, cycle := range g.Cycles() {
+		var ids []string
+		for _, n := range cycle {
+			ids = append(ids, n.ID)
+		}
+
+		cycles = append(cycles, ids)
+	}
+
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"e"}}, cycles)
+}
+
+func testGraph() *Graph {
+	return &Graph{
+		Nodes: []*GraphNode{
+			{ID: "provider.null.default", Kind: ProviderKind, Type: "null", Name: "default"},
+			{ID: "data.null_data_source.foo", Kind: DataSourceKind, Type: "null_data_source", Name: "foo"},
+			{ID: "null_resource.bar", Kind: ResourceKind, Type: "null_resource", Name: "bar"},
+		},
+		Edges: []*GraphEdge{
+			{From: "data.null_data_source.foo", To: "provider.null.default", Kind: ProviderEdge},
+			{From: "null_resource.bar", To: "data.null_data_source.foo", Kind: ReferenceEdge},
+		},
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	assert.Equal(t, ""+
+		"digraph {\n"+
+		"  rankdir = \"RL\";\n"+
+		"  \"provider.null.default\" [label=\"provider.null.default\", shape=diamond];\n"+
+		"  \"data.null_data_source.foo\" [label=\"data.null_data_source.foo\", shape=note];\n"+
+		"  \"null_resource.bar\" [label=\"null_resource.bar\", shape=box];\n"+
+		"  \"data.null_data_source.foo\" -> \"provider.null.default\" [style=dashed, label=\"provider\"];\n"+
+		"  \"null_resource.bar\" -> \"data.null_data_source.foo\";\n"+
+		"}\n", testGraph().DOT())
+}
+
+func TestGraphMermaid(t *testing.T) {
+	assert.Equal(t, ""+
+		"flowchart RL\n"+
+		"  n0{{\"provider.null.default\"}}\n"+
+		"  n1[(\"data.null_data_source.foo\")]\n"+
+		"  n2[\"null_resource.bar\"]\n"+
+		"  n1 -. provider .-> n0\n"+
+		"  n2 --> n1\n", testGraph().Mermaid())
+}
+
+func TestGraphJSON(t *testing.T) {
+	out, err := testGraph().JSON()
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `"from": "null_resource.bar"`)
+
+	out, err = (&Graph{}).JSON()
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"nodes\": [],\n  \"edges\": []\n}", string(out))
+}
@@ -3,8 +3,10 @@ package types
 import (
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -58,6 +60,148 @@ func (s *Terraform) ToHCL(b *hclwrite.Body) {
 	s.p.ToHCL(b)
 }
 
+// HCL file names used by Terraform.ToHCLFiles.
+const (
+	// BackendFileName is the file where the backend is encoded.
+	BackendFileName = "backend.tf"
+	// VersionsFileName is the file where the provider versions are encoded.
+	VersionsFileName = "versions.tf"
+	// DefaultFileName is the file used by SplitByFile when the origin of a
+	// resource is unknown.
+	DefaultFileName = "main.tf"
+)
+
+// SplitFunc returns the name of the file where the given resource, or
+// provider, is encoded by Terraform.ToHCLFiles.
+type SplitFunc func(r *Resource) (string, error)
+
+// SplitByProvider is a SplitFunc that encodes every provider, and its
+// resources, in a file named after the provider type. Eg.: `google.tf`.
+func SplitByProvider(r *Resource) (string, error) {
+	return r.provider.typ + ".tf", nil
+}
+
+// SplitByFile is a SplitFunc that encodes every provider and resource in a
+// file named after the Starlark file where it was instantiated. Eg.: resources
+// from `network.star` are encoded in `network.tf`.
+func SplitByFile(r *Resource) (string, error) {
+	cs := r.CallStack()
+	if len(cs) < 2 {
+		return DefaultFileName, nil
+	}
+
+	filename := filepath.Base(cs.At(1).Pos.Filename())
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".tf", nil
+}
+
+// SplitByFunction returns a SplitFunc calling the given starlark.Callable,
+// with the resource as argument, the returned string is used as file name.
+func SplitByFunction(t *starlark.Thread, fn starlark.Callable) SplitFunc {
+	return func(r *Resource) (string, error) {
+		v, err := starlark.Call(t, fn, starlark.Tuple{r}, nil)
+		if err != nil {
+			return "", err
+		}
+
+		filename, ok := v.(starlark.String)
+		if !ok || filename == "" {
+			return "", fmt.Errorf("%s: expected non-empty string, got %s", fn.Name(), v.Type())
+		}
+
+		if filepath.Base(string(filename)) != string(filename) {
+			return "", fmt.Errorf("%s: invalid file name %q", fn.Name(), filename)
+		}
+
+		return string(filename), nil
+	}
+}
+
+// ToHCLFiles returns the HCL encoding of the Terraform split in several
+// files. The file of every provider and resource is decided by the given
+// SplitFunc, except the backend and the provider versions, encoded at
+// BackendFileName and VersionsFileName. Since the versions are required by
+// type, an error is returned if providers of the same type have different
+// versions.
+func (s *Terraform) ToHCLFiles(split SplitFunc) (map[string]*hclwrite.File, error) {
+	files := make(map[string]*hclwrite.File)
+	body := func(filename string) *hclwrite.Body {
+		if _, ok := files[filename]; !ok {
+			files[filename] = hclwrite.NewEmptyFile()
+		}
+
+		return files[filename].Body()
+	}
+
+	if s.b != nil {
+		s.b.ToHCL(body(BackendFileName))
+	}
+
+	var types []string
+	versions := make(map[string]string)
+	for _, typ := range s.p.Keys() {
+		providers, _, _ := s.p.Get(typ)
+		for _, name := range providers.(*Dict).Keys() {
+			v, _, _ := providers.(*Dict).Get(name)
+			p := v.(*Provider)
+
+			filename, err := split(p.Resource)
+			if err != nil {
+				return nil, err
+			}
+
+			b := body(filename)
+			if len(b.Blocks()) != 0 || len(b.Attributes()) != 0 {
+				b.AppendNewline()
+			}
+
+			p.toHCLBlock(b, false)
+
+			resources := append(p.dataSources.resources(), p.resources.resources()...)
+			for _, r := range resources {
+				filename, err := split(r)
+				if err != nil {
+					return nil, err
+				}
+
+				r.ToHCL(body(filename))
+			}
+
+			version := string(p.meta.Version)
+			if other, ok := versions[p.typ]; ok && other != version {
+				return nil, fmt.Errorf("provider %q: conflicting versions %q and %q", p.typ, other, version)
+			}
+
+			if _, ok := versions[p.typ]; !ok {
+				types = append(types, p.typ)
+			}
+
+			versions[p.typ] = version
+		}
+	}
+
+	if len(types) == 0 {
+		return files, nil
+	}
+
+	parent := body(VersionsFileName).AppendNewBlock("terraform", nil)
+	required := parent.Body().AppendNewBlock("required_providers", nil)
+	for _, typ := range types {
+		required.Body().SetAttributeValue(typ, cty.StringVal(versions[typ]))
+	}
+
+	return files, nil
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, e := range list {
+		if e == v {
+			return list
+		}
+	}
+
+	return append(list, v)
+}
+
 // ToHCL honors the HCLCompatible interface.
 func (s *Dict) ToHCL(b *hclwrite.Body) {
 	for _, v := range s.Keys() {
@@ -73,15 +217,21 @@ func (s *Dict) ToHCL(b *hclwrite.Body) {
 
 // ToHCL honors the HCLCompatible interface.
 func (s *Provider) ToHCL(b *hclwrite.Body) {
+	s.toHCLBlock(b, true)
+	s.dataSources.ToHCL(b)
+	s.resources.ToHCL(b)
+	b.AppendNewline()
+}
+
+func (s *Provider) toHCLBlock(b *hclwrite.Body, version bool) {
 	block := b.AppendNewBlock("provider", []string{s.typ})
 
 	block.Body().SetAttributeValue("alias", cty.StringVal(s.name))
-	block.Body().SetAttributeValue("version", cty.StringVal(string(s.meta.Version)))
-	s.Resource.doToHCLAttributes(block.Body())
+	if version {
+		block.Body().SetAttributeValue("version", cty.StringVal(string(s.meta.Version)))
+	}
 
-	s.dataSources.ToHCL(b)
-	s.resources.ToHCL(b)
-	b.AppendNewline()
+	s.Resource.doToHCLAttributes(block.Body())
 }
 
 // ToHCL honors the HCLCompatible interface.
@@ -340,9 +490,15 @@ func appendTokensForValue(val starlark.Value, toks hclwrite.Tokens) hclwrite.Tok
 			Bytes: []byte{'}'},
 		})
 	case *Attribute:
+		src := v.sString.String()
+		if v.refs != nil {
+			// composite attributes, templates or expressions, are written as is.
+			src = v.path
+		}
+
 		toks = append(toks, &hclwrite.Token{
 			Type:  hclsyntax.TokenIdent,
-			Bytes: []byte(v.sString.String()),
+			Bytes: []byte(src),
 		})
 	default:
 		panic(fmt.Sprintf("cannot produce tokens for %#v", val))
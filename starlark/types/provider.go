@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/mcuadros/ascode/terraform"
@@ -114,6 +115,8 @@ type Provider struct {
 
 	dataSources *ResourceCollectionGroup
 	resources   *ResourceCollectionGroup
+	// collection is the ProviderCollection containing the provider, if any.
+	collection *ProviderCollection
 
 	*Resource
 }
@@ -238,7 +241,7 @@ func (p *Provider) setPrefix(_ *starlark.Thread, _ *starlark.Builtin, args starl
 
 // AttrNames honors the starlark.HasAttrs interface.
 func (p *Provider) AttrNames() []string {
-	return append(p.Resource.AttrNames(), "data", "resource", "__version__")
+	return append(p.Resource.AttrNames(), "data", "resource", "set_prefix", "__version__")
 }
 
 // CompareSameType honors starlark.Comparable interface.
@@ -356,3 +359,22 @@ func (g *ResourceCollectionGroup) AttrNames() []string {
 
 	return append(names, "__kind__", "__provider__")
 }
+
+func (g *ResourceCollectionGroup) resources() []*Resource {
+	names := make(sort.StringSlice, 0, len(g.collections))
+	for name := range g.collections {
+		names = append(names, name)
+	}
+
+	sort.Sort(names)
+
+	var resources []*Resource
+	for _, name := range names {
+		c := g.collections[name]
+		for i := 0; i < c.Len(); i++ {
+			resources = append(resources, c.Index(i).(*Resource))
+		}
+	}
+
+	return resources
+}
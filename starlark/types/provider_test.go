@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/mcuadros/ascode/starlark/module/os"
+	"github.com/mcuadros/ascode/starlark/sandbox"
 	"github.com/mcuadros/ascode/starlark/test"
 	"github.com/mcuadros/ascode/terraform"
 	"go.starlark.net/resolve"
@@ -67,12 +68,14 @@ func doTestPrint(t *testing.T, filename string, print func(*starlark.Thread, str
 	id = 0
 
 	dir, _ := filepath.Split(filename)
-	pm := &terraform.PluginManager{".providers"}
+	pm := &terraform.PluginManager{Path: ".providers"}
 
 	log.SetOutput(ioutil.Discard)
 	thread := &starlark.Thread{Load: load, Print: print}
 	thread.SetLocal("base_path", dir)
 	thread.SetLocal(PluginManagerLocal, pm)
+	thread.SetLocal(AllowStateWritesLocal, true)
+	thread.SetLocal(sandbox.PermissionsLocal, sandbox.AllowAll())
 
 	test.SetReporter(thread, t)
 
@@ -82,8 +85,10 @@ func doTestPrint(t *testing.T, filename string, print func(*starlark.Thread, str
 	predeclared["backend"] = BuiltinBackend()
 	predeclared["hcl"] = BuiltinHCL()
 	predeclared["validate"] = BuiltinValidate()
+	predeclared["diff"] = BuiltinDiff()
 	predeclared["fn"] = BuiltinFunctionAttribute()
 	predeclared["ref"] = BuiltinRef()
+	predeclared["vars"] = newTestVars()
 	predeclared["evaluate"] = BuiltinEvaluate(predeclared)
 	predeclared["struct"] = starlark.NewBuiltin("struct", starlarkstruct.Make)
 	predeclared["module"] = starlark.NewBuiltin("module", starlarkstruct.MakeModule)
@@ -7,6 +7,7 @@ import (
 
 	"github.com/hashicorp/terraform/configs/configschema"
 	"github.com/oklog/ulid/v2"
+	"github.com/zclconf/go-cty/cty"
 	"go.starlark.net/starlark"
 	"go.starlark.net/syntax"
 )
@@ -197,6 +198,18 @@ func unpackResourceArgs(
 //             params:
 //               provisioner Provisioner
 //                 provisioner resource to be executed.
+//           clone(name, **kwargs)
+//             Returns a copy of the resource, added to the same collection,
+//             with the given arguments overridden. The arguments named `name`,
+//             such as the one of `aws_db_instance`, can be set using a dict
+//             as positional argument, after the name if any.
+//             (Only in resources of kind "resource" and "data")
+//             params:
+//               name string
+//                 name of the new resource, positional or keyword, if none is
+//                 given it's auto-generated.
+//               kwargs
+//                 arguments to override in the copy.
 type Resource struct {
 	name   string
 	typ    string
@@ -252,6 +265,77 @@ func (r *Resource) loadDict(d *starlark.Dict) error {
 	return nil
 }
 
+// loadCty loads the values of an object cty.Value, as the ones read from a
+// state, following the schema of the Resource.
+func (r *Resource) loadCty(v cty.Value) error {
+	if v.IsNull() || !v.IsKnown() || !v.Type().IsObjectType() {
+		return nil
+	}
+
+	for name := range r.block.Attributes {
+		if !v.Type().HasAttribute(name) {
+			continue
+		}
+
+		value, err := ctyToStarlark(v.GetAttr(name))
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+
+		if value == starlark.None {
+			continue
+		}
+
+		r.values.Set(name, MustValue(value))
+	}
+
+	for name, b := range r.block.BlockTypes {
+		if !v.Type().HasAttribute(name) {
+			continue
+		}
+
+		if err := r.loadCtyBlock(name, b, v.GetAttr(name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Resource) loadCtyBlock(name string, b *configschema.NestedBlock, v cty.Value) error {
+	if v.IsNull() || !v.IsKnown() {
+		return nil
+	}
+
+	var items []cty.Value
+	if v.Type().IsObjectType() {
+		items = []cty.Value{v}
+	} else {
+		for it := v.ElementIterator(); it.Next(); {
+			_, item := it.Element()
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	block, _ := r.attrBlock(name, b)
+	switch nested := block.(type) {
+	case *Resource:
+		return nested.loadCty(items[0])
+	case *ResourceCollection:
+		for _, item := range items {
+			if err := nested.appendCty(item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func (r *Resource) loadKeywordArgs(kwargs []starlark.Tuple) error {
 	for _, kwarg := range kwargs {
 		name := kwarg.Index(0).(starlark.String)
@@ -277,6 +361,16 @@ func (r *Resource) Path() string {
 	return fmt.Sprintf("%s.%s.%s", r.provider.typ, r.kind, r.typ)
 }
 
+// Address returns the Terraform address of a Resource of kind resource or
+// data. Eg.: `aws_instance.foo` or `data.aws_ami.foo`.
+func (r *Resource) Address() string {
+	if r.kind == DataSourceKind {
+		return fmt.Sprintf("%s.%s.%s", r.kind, r.typ, r.Name())
+	}
+
+	return fmt.Sprintf("%s.%s", r.typ, r.Name())
+}
+
 // Type honors the starlark.Value interface.
 func (r *Resource) Type() string {
 	return fmt.Sprintf("Resource<%s>", r.kind)
@@ -315,6 +409,14 @@ func (r *Resource) Attr(name string) (starlark.Value, error) {
 		if r.kind == ResourceKind {
 			return starlark.NewBuiltin("add_provisioner", r.addProvisioner), nil
 		}
+	case "clone":
+		if r.kind == ResourceKind || r.kind == DataSourceKind {
+			return starlark.NewBuiltin("clone", r.doClone), nil
+		}
+	case "remove":
+		if r.kind == ResourceKind || r.kind == DataSourceKind {
+			return starlark.NewBuiltin("remove", r.remove), nil
+		}
 	case "__provider__":
 		if r.kind.IsProviderRelated() {
 			if r.provider == nil {
@@ -395,6 +497,10 @@ func (r *Resource) AttrNames() []string {
 		names = append(names, "depends_on", "add_provisioner")
 	}
 
+	if r.kind == ResourceKind || r.kind == DataSourceKind {
+		names = append(names, "clone", "remove")
+	}
+
 	if r.kind.IsProviderRelated() {
 		names = append(names, "__provider__")
 	}
@@ -523,6 +629,152 @@ func (r *Resource) addProvisioner(_ *starlark.Thread, _ *starlark.Builtin, args
 	return starlark.None, nil
 }
 
+func (r *Resource) doClone(t *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	// the name keyword argument is the name of the clone, not an argument
+	// of the schema, eg.: `name` of `aws_db_instance`.
+	var nameKwarg starlark.Value
+	var overrides []starlark.Tuple
+	for _, kwarg := range kwargs {
+		if kwarg[0].(starlark.String) == "name" {
+			nameKwarg = kwarg[1]
+			continue
+		}
+
+		overrides = append(overrides, kwarg)
+	}
+
+	name, dict, err := unpackResourceArgs(args, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	if nameKwarg != nil {
+		s, ok := nameKwarg.(starlark.String)
+		if !ok {
+			return nil, fmt.Errorf("%s: for parameter name: got %s, want string", b.Name(), nameKwarg.Type())
+		}
+
+		if name != "" {
+			return nil, fmt.Errorf("%s: got multiple values for parameter name", b.Name())
+		}
+
+		name = string(s)
+	}
+
+	c, err := r.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = NameGenerator()
+	}
+
+	clone := r.clone(name, r.parent, t.CallStack())
+	if dict != nil && dict.Len() != 0 {
+		if err := clone.loadDict(dict); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := clone.loadKeywordArgs(overrides); err != nil {
+		return nil, err
+	}
+
+	return clone, c.List.Append(clone)
+}
+
+func (r *Resource) remove(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+		return nil, err
+	}
+
+	c, err := r.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	return starlark.None, c.doRemove(r)
+}
+
+// collection returns the ResourceCollection of the provider containing
+// resources of the same kind and type.
+func (r *Resource) collection() (*ResourceCollection, error) {
+	group := r.provider.resources
+	if r.kind == DataSourceKind {
+		group = r.provider.dataSources
+	}
+
+	c, ok := group.collections[r.typ]
+	if !ok {
+		return nil, fmt.Errorf("%s: not defined by any provider collection", r)
+	}
+
+	return c, nil
+}
+
+// clone returns a deep copy of the resource, with the given name, parent and
+// call stack. The nested blocks and the provisioners are copied too, the
+// Attributes keep referencing the original resources.
+func (r *Resource) clone(name string, parent *Resource, cs starlark.CallStack) *Resource {
+	c := NewResource(name, r.typ, r.kind, r.block, r.provider, parent, cs)
+	r.values.ForEach(func(v *NamedValue) error {
+		c.values.Set(v.Name, MustValue(cloneValue(v.Starlark(), c)))
+		return nil
+	})
+
+	c.dependencies = append(c.dependencies, r.dependencies...)
+	for _, p := range r.provisioners {
+		c.provisioners = append(c.provisioners, &Provisioner{
+			provisioner: p.provisioner,
+			meta:        p.meta,
+			Resource:    p.Resource.clone(p.name, p.parent, p.cs),
+		})
+	}
+
+	return c
+}
+
+// cloneValue returns a deep copy of the given value, the nested blocks are
+// copied with the given parent.
+func cloneValue(v starlark.Value, parent *Resource) starlark.Value {
+	switch v := v.(type) {
+	case *Resource:
+		return v.clone(v.name, parent, v.cs)
+	case *ResourceCollection:
+		c := NewNestedResourceCollection(v.typ, v.nestedblock, v.provider, parent)
+		for i := 0; i < v.Len(); i++ {
+			c.List.Append(cloneValue(v.Index(i), parent))
+		}
+
+		return c
+	case *starlark.List:
+		values := make([]starlark.Value, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			values[i] = cloneValue(v.Index(i), parent)
+		}
+
+		return starlark.NewList(values)
+	case starlark.Tuple:
+		values := make(starlark.Tuple, len(v))
+		for i, e := range v {
+			values[i] = cloneValue(e, parent)
+		}
+
+		return values
+	case *starlark.Dict:
+		d := starlark.NewDict(v.Len())
+		for _, k := range v.Keys() {
+			e, _, _ := v.Get(k)
+			d.SetKey(k, cloneValue(e, parent))
+		}
+
+		return d
+	}
+
+	return v
+}
+
 // CompareSameType honors starlark.Comparable interface.
 func (r *Resource) CompareSameType(op syntax.Token, yv starlark.Value, depth int) (bool, error) {
 	y := yv.(*Resource)
@@ -578,6 +830,13 @@ func (r *Resource) doCompareSameType(y *Resource, depth int) (bool, error) {
 	return true, nil
 }
 
+// Schema returns the schema of the resource.
+func (r *Resource) Schema() *configschema.Block {
+	return r.block
+}
+
+// CallStack returns the call stack of the instantiation of the resource, or
+// of its parent if it was instantiated implicitly.
 func (r *Resource) CallStack() starlark.CallStack {
 	if r.cs != nil {
 		return r.cs
@@ -0,0 +1,124 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestResourceClone(t *testing.T) {
+	id = 0
+	src := "" +
+		"foo = null.resource.resource(\"foo\", triggers={\"a\": \"1\"})\n" +
+		"foo.timeouts.create = \"5m\"\n" +
+		"foo.rule(port=80)\n" +
+		"foo.depends_on(null.resource.resource(\"dep\"))\n" +
+		"bar = foo.clone(\"bar\", triggers={\"b\": \"2\"})\n" +
+		"bar.timeouts.create = \"10m\"\n" +
+		"bar.rule[0].port = 8080\n" +
+		"bar.rule(port=443)\n" +
+		"qux = foo.clone()\n" +
+		"db = null.resource.resource(\"db\", name=\"main\")\n" +
+		"baz = db.clone(name=\"baz\", triggers={\"c\": \"3\"})\n"
+
+	p := newTestProvider("default")
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "test.star", src, starlark.StringDict{"null": p})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foo := globals["foo"].(*Resource)
+	bar := globals["bar"].(*Resource)
+
+	assert.Equal(t, "bar", bar.Name())
+	assert.Equal(t, "null_resource.bar", bar.Address())
+	assert.Equal(t, "test.star:5:16", bar.CallStack().At(1).Pos.String())
+	assert.Equal(t, foo.dependencies, bar.dependencies)
+
+	assert.Equal(t, `{"rule": [{"port": 80}], "timeouts": {"create": "5m"}, "triggers": {"a": "1"}}`, foo.toDict().String())
+	assert.Equal(t, `{"rule": [{"port": 8080}, {"port": 443}], "timeouts": {"create": "10m"}, "triggers": {"b": "2"}}`, bar.toDict().String())
+
+	rule := bar.values.Get("rule").Starlark().(*ResourceCollection).Index(0).(*Resource)
+	assert.Equal(t, bar, rule.parent)
+
+	c := p.resources.collections["null_resource"]
+	assert.Equal(t, 6, c.Len())
+	assert.Equal(t, globals["qux"], c.Index(3))
+	assert.Equal(t, "id_1", globals["qux"].(*Resource).Name())
+
+	baz := globals["baz"].(*Resource)
+	assert.Equal(t, "baz", baz.Name())
+	assert.Equal(t, `{"name": "main", "triggers": {"c": "3"}}`, baz.toDict().String())
+}
+
+func TestResourceCloneErrors(t *testing.T) {
+	src := "" +
+		"foo = null.resource.resource(\"foo\")\n" +
+		"def unknown(): foo.clone(\"bar\", foo=\"qux\")\n" +
+		"def computed(): foo.clone(\"bar\", id=\"qux\")\n" +
+		"def names(): foo.clone(\"bar\", name=\"qux\")\n" +
+		"def nameType(): foo.clone(name=1)\n"
+
+	p := newTestProvider("default")
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "test.star", src, starlark.StringDict{"null": p})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = starlark.Call(&starlark.Thread{}, globals["unknown"], nil, nil)
+	assert.EqualError(t, err, "Resource<null.resource.null_resource> has no .foo field or method")
+
+	_, err = starlark.Call(&starlark.Thread{}, globals["computed"], nil, nil)
+	assert.EqualError(t, err, "Resource<null.resource.null_resource>: can't set computed id attribute")
+
+	_, err = starlark.Call(&starlark.Thread{}, globals["names"], nil, nil)
+	assert.EqualError(t, err, "clone: got multiple values for parameter name")
+
+	_, err = starlark.Call(&starlark.Thread{}, globals["nameType"], nil, nil)
+	assert.EqualError(t, err, "clone: for parameter name: got int, want string")
+}
+
+func TestResourceRemove(t *testing.T) {
+	src := "" +
+		"foo = null.resource.resource(\"foo\")\n" +
+		"bar = null.resource.resource(\"bar\", triggers={\"foo\": foo.id})\n" +
+		"baz = null.resource.resource(\"baz\")\n" +
+		"baz.depends_on(bar)\n" +
+		"qux = null.resource.resource(\"qux\")\n" +
+		"qux.remove()\n" +
+		"def removeFoo(): foo.remove()\n" +
+		"def removeBar(): null.resource.resource.remove(bar)\n" +
+		"def removeQux(): null.resource.resource.remove(qux)\n" +
+		"def clear(): null.resource.resource.clear()\n"
+
+	p := newTestProvider("default")
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "test.star", src, starlark.StringDict{"null": p})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := p.resources.collections["null_resource"]
+	assert.Equal(t, 3, c.Len())
+
+	call := func(name string) error {
+		_, err := starlark.Call(&starlark.Thread{}, globals[name], nil, nil)
+		return err
+	}
+
+	assert.EqualError(t, call("removeFoo"), "can't remove null_resource.foo, referenced by null_resource.bar")
+	assert.EqualError(t, call("removeBar"), "can't remove null_resource.bar, referenced by null_resource.baz")
+	assert.EqualError(t, call("removeQux"), "null_resource.qux: not found in ResourceCollection<null.resource.null_resource>")
+	assert.Equal(t, 3, c.Len())
+
+	assert.NoError(t, call("clear"))
+	assert.Equal(t, 0, c.Len())
+	var names int
+	for _, name := range c.AttrNames() {
+		if name == "remove" || name == "clear" {
+			names++
+		}
+	}
+
+	assert.Equal(t, 2, names)
+}
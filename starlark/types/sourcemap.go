@@ -0,0 +1,230 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/mcuadros/ascode/terraform"
+	"github.com/zclconf/go-cty/cty"
+	"go.starlark.net/starlark"
+)
+
+// SourceMapFileName is the name of the source map written next to the
+// generated HCL files.
+const SourceMapFileName = "ascode.map.json"
+
+// SourceMap maps the blocks of the generated HCL files to the Starlark call
+// stack that instantiated them, allowing to translate the positions reported
+// by Terraform to Starlark positions.
+type SourceMap struct {
+	// Files contains the blocks of every HCL file, by file name, sorted by
+	// line.
+	Files map[string][]*SourceMapping `json:"files"`
+}
+
+// SourceMapping is the origin of a block of a generated HCL file.
+type SourceMapping struct {
+	// Address of the block, eg.: `google_compute_network.main`.
+	Address string `json:"address"`
+	// Start and End are the first and last lines of the block.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// CallStack of the instantiation, outermost call first.
+	CallStack []SourceFrame `json:"call_stack"`
+}
+
+// SourceFrame is a frame of a SourceMapping call stack.
+type SourceFrame struct {
+	Name string `json:"name"`
+	Pos  string `json:"pos"`
+}
+
+// NewSourceMap returns a new empty SourceMap.
+func NewSourceMap() *SourceMap {
+	return &SourceMap{Files: make(map[string][]*SourceMapping)}
+}
+
+// ReadSourceMap reads a SourceMap from the given file.
+func ReadSourceMap(filename string) (*SourceMap, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewSourceMap()
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return m, nil
+}
+
+// Write writes the SourceMap to the given file.
+func (m *SourceMap) Write(filename string) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, append(content, '\n'), 0644)
+}
+
+// Lookup returns the SourceMapping of the block containing the given line of
+// the given file, or nil if the line doesn't belong to any mapped block.
+func (m *SourceMap) Lookup(filename string, line int) *SourceMapping {
+	for _, mapping := range m.Files[filename] {
+		if line >= mapping.Start && line <= mapping.End {
+			return mapping
+		}
+	}
+
+	return nil
+}
+
+// Explain returns a description of the given Terraform diagnostic, if the
+// range of the diagnostic belongs to a mapped block, the call stack of the
+// block is included, following the format of starlark.EvalError.Backtrace.
+func (m *SourceMap) Explain(d *terraform.Diagnostic) string {
+	var buf strings.Builder
+
+	var mapping *SourceMapping
+	if d.Range != nil {
+		mapping = m.Lookup(filepath.Base(d.Range.Filename), d.Range.Start.Line)
+	}
+
+	if mapping != nil && len(mapping.CallStack) != 0 {
+		buf.WriteString("Traceback (most recent call last):\n")
+		for _, frame := range mapping.CallStack {
+			fmt.Fprintf(&buf, "  %s: in %s\n", frame.Pos, frame.Name)
+		}
+	}
+
+	severity := "Error"
+	if d.Severity == "warning" {
+		severity = "Warning"
+	}
+
+	fmt.Fprintf(&buf, "%s: %s", severity, d.Summary)
+	if d.Range != nil {
+		fmt.Fprintf(&buf, " (%s)", d.Range)
+	}
+
+	if d.Detail != "" {
+		fmt.Fprintf(&buf, "\n\n%s", d.Detail)
+	}
+
+	return buf.String()
+}
+
+// AnnotateHCL returns the given HCL file, encoded from the Terraform, adding
+// before every backend, provider, resource and data source block a comment
+// referencing the Starlark position where it was instantiated. The lines of
+// every block are recorded at the given SourceMap under the given filename.
+func (t *Terraform) AnnotateHCL(filename string, src []byte, m *SourceMap) ([]byte, error) {
+	f, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	origins := t.origins()
+	lines := bytes.SplitAfter(src, []byte("\n"))
+
+	var out bytes.Buffer
+	var next, offset int
+	for _, block := range f.Body.(*hclsyntax.Body).Blocks {
+		address := blockAddress(block)
+		r, ok := origins[address]
+		if !ok || len(r.CallStack()) < 2 {
+			continue
+		}
+
+		start := block.TypeRange.Start.Line
+		for ; next < start-1; next++ {
+			out.Write(lines[next])
+		}
+
+		cs := r.CallStack()
+		fmt.Fprintf(&out, "# source: %s\n", cs.At(1).Pos)
+		offset++
+
+		m.Files[filename] = append(m.Files[filename], &SourceMapping{
+			Address:   address,
+			Start:     start + offset,
+			End:       block.Range().End.Line + offset,
+			CallStack: sourceFrames(cs),
+		})
+	}
+
+	for ; next < len(lines); next++ {
+		out.Write(lines[next])
+	}
+
+	return out.Bytes(), nil
+}
+
+// origins returns the backend, providers and resources by address.
+func (t *Terraform) origins() map[string]*Resource {
+	origins := make(map[string]*Resource)
+	if t.b != nil {
+		origins["backend"] = t.b.Resource
+	}
+
+	for _, typ := range t.p.Keys() {
+		providers, _, _ := t.p.Get(typ)
+		for _, name := range providers.(*Dict).Keys() {
+			p, _, _ := providers.(*Dict).Get(name)
+			r := p.(*Provider).Resource
+			origins[fmt.Sprintf("provider.%s.%s", r.typ, r.Name())] = r
+		}
+	}
+
+	for _, r := range t.Resources() {
+		origins[r.Address()] = r
+	}
+
+	return origins
+}
+
+// blockAddress returns the address of a top-level block, following the
+// format of Terraform.origins.
+func blockAddress(b *hclsyntax.Block) string {
+	switch b.Type {
+	case "terraform":
+		for _, nested := range b.Body.Blocks {
+			if nested.Type == "backend" {
+				return "backend"
+			}
+		}
+	case "provider":
+		attr, ok := b.Body.Attributes["alias"]
+		if !ok {
+			break
+		}
+
+		v, diags := attr.Expr.Value(nil)
+		if !diags.HasErrors() && v.Type() == cty.String {
+			return fmt.Sprintf("provider.%s.%s", b.Labels[0], v.AsString())
+		}
+	case string(ResourceKind):
+		return strings.Join(b.Labels, ".")
+	}
+
+	return strings.Join(append([]string{b.Type}, b.Labels...), ".")
+}
+
+// sourceFrames returns the frames of the given call stack, outermost first,
+// skipping the innermost frame, the builtin instantiating the value.
+func sourceFrames(cs starlark.CallStack) []SourceFrame {
+	frames := make([]SourceFrame, len(cs)-1)
+	for i, frame := range cs[:len(cs)-1] {
+		frames[i] = SourceFrame{Name: frame.Name, Pos: frame.Pos.String()}
+	}
+
+	return frames
+}
@@ -0,0 +1,122 @@
+package types
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/mcuadros/ascode/terraform"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestTerraformAnnotateHCL(t *testing.T) {
+	pm := &terraform.PluginManager{Path: ".providers"}
+	tf := NewTerraform(pm)
+	predeclared := starlark.StringDict{"tf": tf, "backend": BuiltinBackend()}
+
+	src := "def gcs(bucket):\n" +
+		"    b = backend(\"gcs\")\n" +
+		"    b.bucket = bucket\n" +
+		"    return b\n" +
+		"\n" +
+		"tf.backend = gcs(\"tf-state-prod\")\n"
+
+	thread := &starlark.Thread{}
+	thread.SetLocal(PluginManagerLocal, pm)
+
+	_, err := starlark.ExecFile(thread, "main.star", src, predeclared)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := hclwrite.NewEmptyFile()
+	tf.ToHCL(f.Body())
+
+	m := NewSourceMap()
+	content, err := tf.AnnotateHCL("main.tf", append([]byte("# header\n\n"), f.Bytes()...), m)
+	assert.NoError(t, err)
+	assert.Equal(t, ""+
+		"# header\n"+
+		"\n"+
+		"# source: main.star:2:16\n"+
+		"terraform {\n"+
+		"  backend \"gcs\" {\n"+
+		"    bucket = \"tf-state-prod\"\n"+
+		"  }\n"+
+		"}\n"+
+		"\n", string(content))
+
+	assert.Equal(t, []*SourceMapping{{
+		Address: "backend",
+		Start:   4,
+		End:     8,
+		CallStack: []SourceFrame{
+			{Name: "<toplevel>", Pos: "main.star:6:17"},
+			{Name: "gcs", Pos: "main.star:2:16"},
+		},
+	}}, m.Files["main.tf"])
+
+	assert.Nil(t, m.Lookup("main.tf", 3))
+	assert.Equal(t, "backend", m.Lookup("main.tf", 6).Address)
+	assert.Nil(t, m.Lookup("other.tf", 6))
+}
+
+func TestSourceMapReadWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ascode-sourcemap")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewSourceMap()
+	m.Files["main.tf"] = []*SourceMapping{{
+		Address:   "google_compute_network.main",
+		Start:     1,
+		End:       4,
+		CallStack: []SourceFrame{{Name: "<toplevel>", Pos: "main.star:1:33"}},
+	}}
+
+	filename := filepath.Join(dir, SourceMapFileName)
+	assert.NoError(t, m.Write(filename))
+
+	read, err := ReadSourceMap(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, m, read)
+}
+
+func TestSourceMapExplain(t *testing.T) {
+	m := NewSourceMap()
+	m.Files["main.tf"] = []*SourceMapping{{
+		Address: "google_compute_network.main",
+		Start:   10,
+		End:     14,
+		CallStack: []SourceFrame{
+			{Name: "<toplevel>", Pos: "main.star:9:8"},
+			{Name: "network", Pos: "network.star:2:38"},
+		},
+	}}
+
+	f, err := os.Open("../../terraform/testdata/validate.json")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	diags, err := terraform.ReadDiagnostics(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ""+
+		"Traceback (most recent call last):\n"+
+		"  main.star:9:8: in <toplevel>\n"+
+		"  network.star:2:38: in network\n"+
+		"Error: Unsupported argument (main.tf:12:3)\n"+
+		"\n"+
+		"An argument named \"foo\" is not expected here.", m.Explain(diags[0]))
+
+	assert.Equal(t, "Warning: Provider configuration not present", m.Explain(diags[1]))
+
+	diags[0].Range.Start.Line = 20
+	assert.Equal(t, ""+
+		"Error: Unsupported argument (main.tf:20:3)\n"+
+		"\n"+
+		"An argument named \"foo\" is not expected here.", m.Explain(diags[0]))
+}
@@ -0,0 +1,539 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statemgr"
+	"go.starlark.net/starlark"
+)
+
+const (
+	// AllowStateWritesLocal is the key of the thread local that enables the
+	// persistence of the State changes.
+	AllowStateWritesLocal = "allow_state_writes"
+)
+
+// Attr honors the starlark.HasAttrs interface.
+func (s *State) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "outputs":
+		return s.outputs, nil
+	case "modules":
+		if s.modules == nil {
+			s.modules = NewStateModules(s)
+		}
+
+		return s.modules, nil
+	case "module":
+		return starlark.NewBuiltin("module", s.module), nil
+	case "resources":
+		return starlark.NewBuiltin("resources", s.resources), nil
+	case "mv":
+		return starlark.NewBuiltin("mv", s.mv), nil
+	case "rm":
+		return starlark.NewBuiltin("rm", s.rm), nil
+	case "persist":
+		return starlark.NewBuiltin("persist", s.persist), nil
+	}
+
+	return s.Dict.Attr(name)
+}
+
+// AttrNames honors the starlark.HasAttrs interface.
+func (s *State) AttrNames() []string {
+	return append(s.Dict.AttrNames(),
+		"outputs", "modules", "module", "resources", "mv", "rm", "persist",
+	)
+}
+
+func (s *State) module(
+	_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	var path string
+	if err := starlark.UnpackArgs("module", args, kwargs, "path", &path); err != nil {
+		return nil, err
+	}
+
+	rel, diags := addrs.ParseModuleInstanceStr(path)
+	if err := diags.Err(); err != nil {
+		return nil, fmt.Errorf("invalid module path %q: %s", path, err)
+	}
+
+	addr := make(addrs.ModuleInstance, 0, len(s.addr)+len(rel))
+	addr = append(append(addr, s.addr...), rel...)
+	return s.child(addr)
+}
+
+// child returns the State of a descendant module, sharing the underlying
+// state and providers.
+func (s *State) child(addr addrs.ModuleInstance) (*State, error) {
+	c, err := newState(s.pm, addr, s.state, s.providers)
+	if err != nil {
+		return nil, err
+	}
+
+	c.sm = s.sm
+	c.views = s.views
+	*c.views = append(*c.views, c)
+	return c, nil
+}
+
+func (s *State) resources(
+	_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	if err := starlark.UnpackArgs("resources", args, kwargs); err != nil {
+		return nil, err
+	}
+
+	var list []string
+	for _, m := range s.state.Modules {
+		for _, r := range m.Resources {
+			for key := range r.Instances {
+				list = append(list, r.Addr.Instance(key).Absolute(m.Addr).String())
+			}
+		}
+	}
+
+	sort.Strings(list)
+
+	values := make([]starlark.Value, len(list))
+	for i, addr := range list {
+		values[i] = starlark.String(addr)
+	}
+
+	return starlark.NewList(values), nil
+}
+
+func (s *State) mv(
+	_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	var source, destination string
+	err := starlark.UnpackArgs("mv", args, kwargs, "source", &source, "destination", &destination)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := parseStateAddr(source)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseStateAddr(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.doMove(from, to); err != nil {
+		return nil, err
+	}
+
+	return starlark.None, s.reload()
+}
+
+func (s *State) doMove(from, to addrs.AbsResourceInstance) error {
+	fromResource, toResource := from.ContainingResource(), to.ContainingResource()
+	if fromResource.Resource.Mode != toResource.Resource.Mode {
+		return fmt.Errorf("cannot move %s to %s: the resource mode can't be changed", from, to)
+	}
+
+	if fromResource.Resource.Type != toResource.Resource.Type {
+		return fmt.Errorf("cannot move %s to %s: the resource type can't be changed", from, to)
+	}
+
+	rs := s.state.Resource(fromResource)
+	if rs == nil {
+		return fmt.Errorf("unable to find %q in the state", from)
+	}
+
+	ss := s.state.SyncWrapper()
+	whole := from.Resource.Key == addrs.NoKey &&
+		(rs.EachMode != states.NoEach || to.Resource.Key == addrs.NoKey)
+
+	if whole {
+		if to.Resource.Key != addrs.NoKey {
+			return fmt.Errorf("cannot move %s to %s: the destination must be a whole resource", from, to)
+		}
+
+		if s.state.Resource(toResource) != nil {
+			return fmt.Errorf("cannot move %s to %s: destination already exists", from, to)
+		}
+
+		ss.RemoveResource(fromResource)
+		rs.Addr = toResource.Resource
+		s.state.EnsureModule(toResource.Module).Resources[toResource.Resource.String()] = rs
+		return nil
+	}
+
+	is := rs.Instance(from.Resource.Key)
+	if is == nil {
+		return fmt.Errorf("unable to find %q in the state", from)
+	}
+
+	if s.state.ResourceInstance(to) != nil {
+		return fmt.Errorf("cannot move %s to %s: destination already exists", from, to)
+	}
+
+	provider := rs.ProviderConfig
+	ss.ForgetResourceInstanceAll(from)
+	ss.RemoveResourceIfEmpty(fromResource)
+
+	s.state.EnsureModule(toResource.Module)
+	each := eachModeForInstanceKey(to.Resource.Key)
+	target := s.state.Resource(toResource)
+	if target == nil {
+		ss.SetResourceMeta(toResource, each, provider)
+		target = s.state.Resource(toResource)
+	}
+
+	target.EachMode = each
+	target.Instances[to.Resource.Key] = is
+	return nil
+}
+
+func (s *State) rm(
+	_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	var address string
+	err := starlark.UnpackArgs("rm", args, kwargs, "address", &address)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := parseStateAddr(address)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := addr.ContainingResource()
+	rs := s.state.Resource(resource)
+	if rs == nil {
+		return nil, fmt.Errorf("unable to find %q in the state", address)
+	}
+
+	ss := s.state.SyncWrapper()
+	if addr.Resource.Key == addrs.NoKey {
+		ss.RemoveResource(resource)
+		return starlark.None, s.reload()
+	}
+
+	if rs.Instance(addr.Resource.Key) == nil {
+		return nil, fmt.Errorf("unable to find %q in the state", address)
+	}
+
+	ss.ForgetResourceInstanceAll(addr)
+	ss.RemoveResourceIfEmpty(resource)
+	return starlark.None, s.reload()
+}
+
+func (s *State) persist(
+	t *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	if err := starlark.UnpackArgs("persist", args, kwargs); err != nil {
+		return nil, err
+	}
+
+	if err := checkStateWrites(t); err != nil {
+		return nil, err
+	}
+
+	if s.sm == nil {
+		return nil, fmt.Errorf("state is not associated to any backend")
+	}
+
+	info := statemgr.NewLockInfo()
+	info.Operation = "ascode"
+
+	id, err := s.sm.Lock(info)
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.sm.Unlock(id)
+	if err := s.sm.WriteState(s.state); err != nil {
+		return nil, err
+	}
+
+	return starlark.None, s.sm.PersistState()
+}
+
+func checkStateWrites(t *starlark.Thread) error {
+	if allow, _ := t.Local(AllowStateWritesLocal).(bool); !allow {
+		return fmt.Errorf("state writes are disabled, use --allow-state-writes to enable them")
+	}
+
+	return nil
+}
+
+// reload rebuilds the Dict representation, and the child modules, of every
+// State sharing the underlying state, after a change in it.
+func (s *State) reload() error {
+	for _, v := range *s.views {
+		if err := v.doReload(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *State) doReload() error {
+	if err := s.Dict.Clear(); err != nil {
+		return err
+	}
+
+	if s.modules != nil {
+		s.modules.refresh()
+	}
+
+	mod := s.state.Module(s.addr)
+	if mod == nil {
+		mod = states.NewModule(s.addr)
+	}
+
+	return s.initialize(s.state, mod)
+}
+
+// StateModules represents the child modules of a State.
+//
+//   outline: types
+//     types:
+//       StateModules
+//         StateModules holds the child modules of a State indexed by name, the
+//         name includes the instance key if any. Eg.: `network` or
+//         `network[0]`. The State of each module is only loaded when is
+//         accessed.
+//
+//         examples:
+//           backend_local.star
+type StateModules struct {
+	parent *State
+	names  []string
+	addrs  map[string]addrs.ModuleInstance
+	cache  map[string]*State
+}
+
+var _ starlark.Value = &StateModules{}
+var _ starlark.Mapping = &StateModules{}
+var _ starlark.Sequence = &StateModules{}
+
+// NewStateModules returns the child modules of the given State.
+func NewStateModules(s *State) *StateModules {
+	m := &StateModules{
+		parent: s,
+		cache:  make(map[string]*State),
+	}
+
+	m.refresh()
+	return m
+}
+
+// refresh updates the child modules from the state, the already loaded ones
+// not present anymore are discarded.
+func (m *StateModules) refresh() {
+	m.names = nil
+	m.addrs = make(map[string]addrs.ModuleInstance)
+	for _, addr := range stateChildModules(m.parent.state, m.parent.addr) {
+		step := addr[len(addr)-1]
+		name := step.Name
+		if step.InstanceKey != addrs.NoKey {
+			name += step.InstanceKey.String()
+		}
+
+		m.names = append(m.names, name)
+		m.addrs[name] = addr
+	}
+
+	sort.Strings(m.names)
+	for name := range m.cache {
+		if _, ok := m.addrs[name]; !ok {
+			delete(m.cache, name)
+		}
+	}
+}
+
+// String honors the starlark.Value interface.
+func (m *StateModules) String() string {
+	return fmt.Sprintf("StateModules<%s>", m.names)
+}
+
+// Type honors the starlark.Value interface.
+func (m *StateModules) Type() string {
+	return "StateModules"
+}
+
+// Freeze honors the starlark.Value interface.
+func (m *StateModules) Freeze() {}
+
+// Truth honors the starlark.Value interface.
+func (m *StateModules) Truth() starlark.Bool {
+	return len(m.names) != 0
+}
+
+// Hash honors the starlark.Value interface.
+func (m *StateModules) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: %s", m.Type())
+}
+
+// Len honors the starlark.Sequence interface.
+func (m *StateModules) Len() int {
+	return len(m.names)
+}
+
+// Iterate honors the starlark.Iterable interface.
+func (m *StateModules) Iterate() starlark.Iterator {
+	names := make([]starlark.Value, len(m.names))
+	for i, name := range m.names {
+		names[i] = starlark.String(name)
+	}
+
+	return starlark.NewList(names).Iterate()
+}
+
+// Get honors the starlark.Mapping interface.
+func (m *StateModules) Get(key starlark.Value) (starlark.Value, bool, error) {
+	name, ok := key.(starlark.String)
+	if !ok {
+		return nil, false, fmt.Errorf("expected string, got %s", key.Type())
+	}
+
+	if s, ok := m.cache[name.GoString()]; ok {
+		return s, true, nil
+	}
+
+	addr, ok := m.addrs[name.GoString()]
+	if !ok {
+		return nil, false, nil
+	}
+
+	s, err := m.parent.child(addr)
+	if err != nil {
+		return nil, false, err
+	}
+
+	m.cache[name.GoString()] = s
+	return s, true, nil
+}
+
+// stateChildModules returns the address of the direct child modules of the
+// given module, including the ones only containing other modules.
+func stateChildModules(state *states.State, parent addrs.ModuleInstance) []addrs.ModuleInstance {
+	var children []addrs.ModuleInstance
+	seen := make(map[string]bool)
+	for _, m := range state.Modules {
+		if len(m.Addr) <= len(parent) || m.Addr[:len(parent)].String() != parent.String() {
+			continue
+		}
+
+		addr := m.Addr[:len(parent)+1]
+		if seen[addr.String()] {
+			continue
+		}
+
+		seen[addr.String()] = true
+		children = append(children, addr)
+	}
+
+	return children
+}
+
+// stateInstance is an instance of a resource or data source from a State.
+type stateInstance struct {
+	key addrs.InstanceKey
+	r   *Resource
+}
+
+// lookup returns the instances, from the state, of the resource or data
+// source with the same kind, type and name of the given one, if any. The
+// resources with `count` or `for_each` have an instance per key.
+func (s *State) lookup(r *Resource) []stateInstance {
+	if r.provider == nil || len(r.typ) <= len(r.provider.typ) {
+		return nil
+	}
+
+	keys := []string{
+		r.provider.typ,
+		addrsResourceModeString(resourceKindMode(r.kind)),
+		r.typ[len(r.provider.typ)+1:],
+		r.Name(),
+	}
+
+	var v starlark.Value = s.Dict
+	for _, key := range keys {
+		dict, ok := v.(*starlark.Dict)
+		if !ok {
+			return nil
+		}
+
+		v, ok, _ = dict.Get(starlark.String(key))
+		if !ok {
+			return nil
+		}
+	}
+
+	switch v := v.(type) {
+	case *Resource:
+		return []stateInstance{{key: addrs.NoKey, r: v}}
+	case *starlark.List:
+		return s.instances(r, v)
+	}
+
+	return nil
+}
+
+// instances returns the given instances, of a resource with `count` or
+// `for_each`, along with their keys, loaded in the same order by
+// initializeResource.
+func (s *State) instances(r *Resource, list *starlark.List) []stateInstance {
+	mod := s.state.Module(s.addr)
+	if mod == nil {
+		return nil
+	}
+
+	rs := mod.Resource(addrs.Resource{Mode: resourceKindMode(r.kind), Type: r.typ, Name: r.Name()})
+	if rs == nil {
+		return nil
+	}
+
+	var instances []stateInstance
+	for _, key := range sortedInstanceKeys(rs) {
+		if rs.Instances[key].Current == nil || len(instances) == list.Len() {
+			continue
+		}
+
+		instance := list.Index(len(instances)).(*Resource)
+		instances = append(instances, stateInstance{key: key, r: instance})
+	}
+
+	return instances
+}
+
+func resourceKindMode(k Kind) addrs.ResourceMode {
+	if k == DataSourceKind {
+		return addrs.DataResourceMode
+	}
+
+	return addrs.ManagedResourceMode
+}
+
+func parseStateAddr(addr string) (addrs.AbsResourceInstance, error) {
+	a, diags := addrs.ParseAbsResourceInstanceStr(addr)
+	if err := diags.Err(); err != nil {
+		return a, fmt.Errorf("invalid address %q: %s", addr, err)
+	}
+
+	return a, nil
+}
+
+func eachModeForInstanceKey(key addrs.InstanceKey) states.EachMode {
+	switch key.(type) {
+	case addrs.IntKey:
+		return states.EachList
+	case addrs.StringKey:
+		return states.EachMap
+	}
+
+	return states.NoEach
+}
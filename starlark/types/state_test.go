@@ -0,0 +1,53 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestStateMoveReload(t *testing.T) {
+	p := newTestProvider("default")
+	provider := addrs.ProviderConfig{Type: addrs.NewLegacyProvider("null")}.Absolute(addrs.RootModuleInstance)
+	instance := func(s *states.SyncState, module addrs.ModuleInstance, name string) {
+		addr := addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "null_resource", Name: name}
+		s.SetResourceInstanceCurrent(addr.Instance(addrs.NoKey).Absolute(module),
+			&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{"id": "` + name + `"}`)}, provider,
+		)
+	}
+
+	network := addrs.RootModuleInstance.Child("network", addrs.NoKey)
+	state := states.BuildState(func(s *states.SyncState) {
+		instance(s, addrs.RootModuleInstance, "foo")
+		instance(s, addrs.RootModuleInstance, "bar")
+		instance(s, network, "qux")
+	})
+
+	s, err := newState(nil, addrs.RootModuleInstance, state, map[string]*Provider{provider.ProviderConfig.String(): p})
+	assert.NoError(t, err)
+
+	src := "" +
+		"net = state.modules[\"network\"]\n" +
+		"state.mv(\"null_resource.foo\", \"module.network.null_resource.foo\")\n" +
+		"state.mv(\"null_resource.bar\", \"module.db.null_resource.bar\")\n" +
+		"root = \"null\" in state\n" +
+		"moved = sorted(net[\"null\"][\"resource\"][\"resource\"].keys())\n" +
+		"modules = list(state.modules)\n" +
+		"db = sorted(state.modules[\"db\"][\"null\"][\"resource\"][\"resource\"].keys())\n" +
+		"state.rm(\"module.network.null_resource.qux\")\n" +
+		"removed = sorted(state.modules[\"network\"][\"null\"][\"resource\"][\"resource\"].keys())\n"
+
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "test.star", src, starlark.StringDict{"state": s})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, starlark.False, globals["root"])
+	assert.Equal(t, `["foo", "qux"]`, globals["moved"].String())
+	assert.Equal(t, `["db", "network"]`, globals["modules"].String())
+	assert.Equal(t, `["bar"]`, globals["db"].String())
+	assert.Equal(t, `["foo"]`, globals["removed"].String())
+}
@@ -82,6 +82,23 @@ func (t *Terraform) AttrNames() []string {
 	return []string{"provider", "backend", "version"}
 }
 
+// Backend returns the Backend defined by the configuration, if any.
+func (t *Terraform) Backend() *Backend {
+	return t.b
+}
+
+// Resources returns all the resources and data sources defined, sorted by
+// provider and type, in order of definition.
+func (t *Terraform) Resources() []*Resource {
+	var resources []*Resource
+	for _, p := range t.p.providers() {
+		resources = append(resources, p.dataSources.resources()...)
+		resources = append(resources, p.resources.resources()...)
+	}
+
+	return resources
+}
+
 // Freeze honors the starlark.Value interface.
 func (t *Terraform) Freeze() {} // immutable
 
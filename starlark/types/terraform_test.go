@@ -1,9 +1,104 @@
 package types
 
 import (
+	"path/filepath"
+	"sort"
 	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/mcuadros/ascode/terraform"
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
 )
 
 func TestTerraform(t *testing.T) {
 	doTest(t, "testdata/terraform.star")
 }
+
+func TestTerraformToHCLFiles(t *testing.T) {
+	pm := &terraform.PluginManager{Path: ".providers"}
+	tf := NewTerraform(pm)
+
+	predeclared := starlark.StringDict{"tf": tf, "backend": BuiltinBackend()}
+	newThread := func() *starlark.Thread {
+		thread := &starlark.Thread{}
+		thread.SetLocal(PluginManagerLocal, pm)
+		thread.Load = func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			filename := filepath.Join("testdata/hcl_files", module)
+			return starlark.ExecFile(thread, filename, nil, predeclared)
+		}
+
+		return thread
+	}
+
+	globals, err := starlark.ExecFile(newThread(), "testdata/hcl_files/main.star", nil, predeclared)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := tf.ToHCLFiles(SplitByProvider)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"backend.tf", "google.tf", "null.tf", "versions.tf"}, fileNames(files))
+	assert.Equal(t, ""+
+		"provider \"google\" {\n"+
+		"  alias = \"default\"\n"+
+		"}\n"+
+		"\n"+
+		"resource \"google_compute_global_address\" \"test\" {\n"+
+		"  provider = google.default\n"+
+		"  purpose  = \"VPC_PEERING\"\n"+
+		"}\n"+
+		"\n"+
+		"resource \"google_compute_network\" \"main\" {\n"+
+		"  provider = google.default\n"+
+		"  name     = \"main\"\n"+
+		"}\n", string(files["google.tf"].Bytes()))
+
+	assert.Equal(t, ""+
+		"terraform {\n"+
+		"  required_providers {\n"+
+		"    google = \"3.16.0\"\n"+
+		"    null   = \"2.1.2\"\n"+
+		"  }\n"+
+		"}\n", string(files["versions.tf"].Bytes()))
+
+	files, err = tf.ToHCLFiles(SplitByFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"backend.tf", "main.tf", "network.tf", "versions.tf"}, fileNames(files))
+	assert.Contains(t, string(files["network.tf"].Bytes()), "google_compute_network")
+	assert.NotContains(t, string(files["main.tf"].Bytes()), "google_compute_network")
+
+	split := globals["split"].(starlark.Callable)
+	files, err = tf.ToHCLFiles(SplitByFunction(newThread(), split))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"backend.tf", "provider.tf", "resource.tf", "versions.tf"}, fileNames(files))
+}
+
+func TestTerraformToHCLFilesVersions(t *testing.T) {
+	tf := NewTerraform(nil)
+	tf.p.SetKey(starlark.String("null"), NewDict())
+	providers, _, _ := tf.p.Get(starlark.String("null"))
+
+	foo, bar := newTestProvider("foo"), newTestProvider("bar")
+	foo.meta.Version, bar.meta.Version = "2.1.2", "2.1.2"
+	providers.(*Dict).SetKey(starlark.String("foo"), foo)
+	providers.(*Dict).SetKey(starlark.String("bar"), bar)
+
+	files, err := tf.ToHCLFiles(SplitByProvider)
+	assert.NoError(t, err)
+	assert.Contains(t, string(files[VersionsFileName].Bytes()), "null = \"2.1.2\"")
+
+	bar.meta.Version = "2.1.0"
+	_, err = tf.ToHCLFiles(SplitByProvider)
+	assert.EqualError(t, err, `provider "null": conflicting versions "2.1.2" and "2.1.0"`)
+}
+
+func fileNames(files map[string]*hclwrite.File) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
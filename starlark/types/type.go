@@ -48,6 +48,10 @@ func NewTypeFromStarlark(typ string) (*Type, error) {
 		t.cty = cty.Map(cty.NilType)
 	case "Attribute":
 		t.cty = cty.String
+	case "tuple":
+		t.cty = cty.Tuple(nil)
+	case "NoneType":
+		t.cty = cty.DynamicPseudoType
 	default:
 		return nil, fmt.Errorf("unexpected %q type", typ)
 	}
@@ -77,6 +81,8 @@ func NewTypeFromCty(typ cty.Type) (*Type, error) {
 		t.typ = "int"
 	case cty.Bool:
 		t.typ = "bool"
+	case cty.DynamicPseudoType:
+		t.typ = "any"
 	}
 
 	if typ.IsMapType() || typ.IsObjectType() {
@@ -124,7 +130,8 @@ func (t *Type) Validate(v starlark.Value) error {
 			return nil
 		}
 	case *Attribute:
-		if t.cty == v.(*Attribute).t {
+		// the type of some function calls is only known by Terraform.
+		if t.cty == v.(*Attribute).t || v.(*Attribute).t == cty.DynamicPseudoType {
 			return nil
 		}
 
@@ -3,6 +3,7 @@ package types
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
@@ -14,6 +15,9 @@ type ValidationError struct {
 	Msg string
 	// CallStack of the instantiation of the value being validated.
 	CallStack starlark.CallStack
+	// Related are the other values involved in the error, such as the
+	// participants of a dependency cycle, with its call stacks.
+	Related ValidationErrors
 }
 
 // NewValidationError returns a new ValidationError.
@@ -28,6 +32,25 @@ func (e *ValidationError) Error() string {
 	return fmt.Sprintf("%s: %s", e.CallStack.At(1).Pos, e.Msg)
 }
 
+// Backtrace returns the error followed by the call stacks of the related
+// values, if any.
+func (e *ValidationError) Backtrace() string {
+	var b strings.Builder
+	b.WriteString(e.Error())
+	for _, r := range e.Related {
+		if len(r.CallStack) < 2 {
+			fmt.Fprintf(&b, "\n%s: not defined by the program", r.Msg)
+			continue
+		}
+
+		// the last frame is the builtin instantiating the value.
+		stack := r.CallStack[:len(r.CallStack)-1]
+		fmt.Fprintf(&b, "\n%s:\n%s", r.Msg, strings.TrimSuffix(stack.String(), "\n"))
+	}
+
+	return b.String()
+}
+
 // Value returns the error as a starlark.Value.
 func (e *ValidationError) Value() starlark.Value {
 	values := []starlark.Tuple{
@@ -92,6 +115,29 @@ func (t *Terraform) Validate() (errs ValidationErrors) {
 	}
 
 	errs = append(errs, t.p.Validate()...)
+	errs = append(errs, t.Duplicates()...)
+	errs = append(errs, t.Graph().Validate()...)
+	return
+}
+
+// Duplicates returns a ValidationError for every resource or data source
+// sharing the address with another one defined before.
+func (t *Terraform) Duplicates() (errs ValidationErrors) {
+	defined := make(map[string]*Resource)
+	for _, r := range t.Resources() {
+		addr := r.Address()
+		first, ok := defined[addr]
+		if !ok {
+			defined[addr] = r
+			continue
+		}
+
+		errs = append(errs, NewValidationError(r.CallStack(),
+			"duplicate resource address %q, already defined at %s",
+			addr, first.CallStack().At(1).Pos,
+		))
+	}
+
 	return
 }
 
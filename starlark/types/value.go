@@ -36,6 +36,65 @@ func NewValue(v starlark.Value) (*Value, error) {
 	return &Value{t: *t, v: v}, nil
 }
 
+// ctyToStarlark converts a cty.Value into a starlark.Value. Lists, sets and
+// tuples are converted into lists; maps and objects into dicts.
+func ctyToStarlark(v cty.Value) (starlark.Value, error) {
+	if !v.IsKnown() {
+		return nil, fmt.Errorf("unable to convert unknown value of type %s", v.Type().FriendlyName())
+	}
+
+	if v.IsNull() {
+		return starlark.None, nil
+	}
+
+	t := v.Type()
+	switch {
+	case t == cty.String:
+		return starlark.String(v.AsString()), nil
+	case t == cty.Bool:
+		return starlark.Bool(v.True()), nil
+	case t == cty.Number:
+		bf := v.AsBigFloat()
+		if bf.IsInt() {
+			i, _ := bf.Int(nil)
+			return starlark.MakeBigInt(i), nil
+		}
+
+		f, _ := bf.Float64()
+		return starlark.Float(f), nil
+	case t.IsListType() || t.IsSetType() || t.IsTupleType():
+		values := make([]starlark.Value, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			e, err := ctyToStarlark(ev)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, e)
+		}
+
+		return starlark.NewList(values), nil
+	case t.IsMapType() || t.IsObjectType():
+		dict := starlark.NewDict(v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			kv, ev := it.Element()
+			e, err := ctyToStarlark(ev)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := dict.SetKey(starlark.String(kv.AsString()), e); err != nil {
+				return nil, err
+			}
+		}
+
+		return dict, nil
+	}
+
+	return nil, fmt.Errorf("unexpected cty type %s", t.FriendlyName())
+}
+
 // Starlark returns the starlark.Value.
 func (v *Value) Starlark() starlark.Value {
 	return v.v
@@ -68,16 +127,36 @@ func (v *Value) Cty() cty.Value {
 			values[i] = MustValue(list.Index(i)).Cty()
 		}
 
+		if !sameType(values) {
+			return cty.TupleVal(values)
+		}
+
 		return cty.ListVal(values)
+	case "tuple":
+		tuple := v.v.(starlark.Tuple)
+		values := make([]cty.Value, tuple.Len())
+		for i := 0; i < tuple.Len(); i++ {
+			values[i] = MustValue(tuple.Index(i)).Cty()
+		}
+
+		return cty.TupleVal(values)
 	case "dict":
 		dict := v.v.(*starlark.Dict)
 		values := make(map[string]cty.Value)
+		var list []cty.Value
 		for _, t := range dict.Items() {
 			key := fmt.Sprintf("%s", MustValue(t.Index(0)).Interface())
 			values[key] = MustValue(t.Index(1)).Cty()
+			list = append(list, values[key])
+		}
+
+		if len(values) == 0 || !sameType(list) {
+			return cty.ObjectVal(values)
 		}
 
 		return cty.MapVal(values)
+	case "NoneType":
+		return cty.NullVal(cty.DynamicPseudoType)
 	case "Attribute":
 		return cty.StringVal(v.v.(*Attribute).GoString())
 	default:
@@ -85,6 +164,18 @@ func (v *Value) Cty() cty.Value {
 	}
 }
 
+// sameType returns true if all the given values have the same type, as
+// required by the lists and maps, otherwise tuples and objects are used.
+func sameType(values []cty.Value) bool {
+	for _, v := range values {
+		if !v.Type().Equals(values[0].Type()) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Interface returns the value as a Go value.
 func (v *Value) Interface() interface{} {
 	switch cast := v.v.(type) {
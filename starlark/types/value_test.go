@@ -135,3 +135,30 @@ func TestValuesForEach(t *testing.T) {
 
 	assert.Equal(t, result, []string{"bar", "foo"})
 }
+
+func TestCtyToStarlark(t *testing.T) {
+	testCases := []struct {
+		cty      cty.Value
+		expected string
+	}{
+		{cty.StringVal("foo"), `"foo"`},
+		{cty.NumberIntVal(42), `42`},
+		{cty.NumberFloatVal(4.2), `4.2`},
+		{cty.True, `True`},
+		{cty.NullVal(cty.String), `None`},
+		{cty.ListVal([]cty.Value{cty.StringVal("foo")}), `["foo"]`},
+		{cty.SetVal([]cty.Value{cty.NumberIntVal(1)}), `[1]`},
+		{cty.TupleVal([]cty.Value{cty.True, cty.StringVal("foo")}), `[True, "foo"]`},
+		{cty.MapVal(map[string]cty.Value{"foo": cty.NumberIntVal(1)}), `{"foo": 1}`},
+		{cty.ObjectVal(map[string]cty.Value{"foo": cty.StringVal("bar")}), `{"foo": "bar"}`},
+	}
+
+	for _, tc := range testCases {
+		v, err := ctyToStarlark(tc.cty)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, v.String())
+	}
+
+	_, err := ctyToStarlark(cty.UnknownVal(cty.String))
+	assert.Error(t, err)
+}
@@ -0,0 +1,366 @@
+package types
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "github.com/zclconf/go-cty-yaml"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"go.starlark.net/starlark"
+)
+
+// Vars represents the variables passed to a script from the command line,
+// using the flags `--var` and `--var-file`.
+//
+//   outline: types
+//     types:
+//       Vars
+//         Variables passed to the script using the flags `--var key=value`
+//         and `--var-file <file>`, available as the predeclared `vars`. The
+//         variable files can be JSON, YAML or Starlark files, in the latter
+//         the public globals are used as variables. The values defined with
+//         `--var` take precedence over the ones defined in files.
+//
+//         Any variable can be accessed as a field, eg.: `vars.region`, and
+//         should be declared, using `declare`, to set its type and default.
+//
+//         examples:
+//           vars.star
+//             Declaring and accessing variables.
+//
+//         methods:
+//           declare(name, type="", default=None, description="") value
+//             Declares a variable returning its value. The value provided
+//             from the command line is converted and validated against the
+//             type, if none the default is used, if no default is given, the
+//             variable is required.
+//             params:
+//               name string
+//                 Name of the variable.
+//               type string
+//                 Type of the variable, one of: `string`, `int`, `float`,
+//                 `bool`, `list` or `dict`. If empty the type of the default
+//                 is used.
+//               default <any>
+//                 Default value of the variable.
+//               description string
+//                 Description of the variable.
+//
+type Vars struct {
+	values   map[string]starlark.Value
+	raw      map[string]bool
+	declared map[string]*Variable
+}
+
+// Variable represents a variable declared in a script.
+type Variable struct {
+	Name        string
+	Type        string
+	Default     starlark.Value
+	Description string
+}
+
+var _ starlark.HasAttrs = &Vars{}
+
+// NewVars returns a new empty Vars.
+func NewVars() *Vars {
+	return &Vars{
+		values:   make(map[string]starlark.Value),
+		raw:      make(map[string]bool),
+		declared: make(map[string]*Variable),
+	}
+}
+
+// Set sets the value of a variable.
+func (v *Vars) Set(name string, value starlark.Value) {
+	v.values[name] = value
+	delete(v.raw, name)
+}
+
+// SetRaw sets the value of a variable from a string, the string is converted
+// to the type of the variable when is declared.
+func (v *Vars) SetRaw(name, value string) {
+	v.values[name] = starlark.String(value)
+	v.raw[name] = true
+}
+
+// SetFlag sets a variable from a string with the format `key=value`.
+func (v *Vars) SetFlag(flag string) error {
+	parts := strings.SplitN(flag, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("invalid variable %q, expected key=value", flag)
+	}
+
+	v.SetRaw(parts[0], parts[1])
+	return nil
+}
+
+// LoadFile loads the variables defined in a JSON, YAML or Starlark file.
+func (v *Vars) LoadFile(filename string) error {
+	values, err := readVarsFile(filename)
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+
+	for name, value := range values {
+		v.Set(name, value)
+	}
+
+	return nil
+}
+
+func readVarsFile(filename string) (starlark.StringDict, error) {
+	if filepath.Ext(filename) == ".star" {
+		globals, err := starlark.ExecFile(&starlark.Thread{}, filename, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for name := range globals {
+			if strings.HasPrefix(name, "_") {
+				delete(globals, name)
+			}
+		}
+
+		return globals, nil
+	}
+
+	var decode func([]byte) (starlark.Value, error)
+	switch filepath.Ext(filename) {
+	case ".json":
+		decode = decodeJSON
+	case ".yaml", ".yml":
+		decode = decodeYAML
+	default:
+		return nil, fmt.Errorf("unsupported file format, expected .json, .yaml or .star")
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := decode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := value.(*starlark.Dict)
+	if !ok {
+		return nil, fmt.Errorf("expected a dict at the top level, got %s", value.Type())
+	}
+
+	values := make(starlark.StringDict, dict.Len())
+	for _, item := range dict.Items() {
+		values[item.Index(0).(starlark.String).GoString()] = item.Index(1)
+	}
+
+	return values, nil
+}
+
+func decodeJSON(content []byte) (starlark.Value, error) {
+	typ, err := ctyjson.ImpliedType(content)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := ctyjson.Unmarshal(content, typ)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctyToStarlark(value)
+}
+
+func decodeYAML(content []byte) (starlark.Value, error) {
+	typ, err := yaml.ImpliedType(content)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := yaml.Unmarshal(content, typ)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctyToStarlark(value)
+}
+
+// Declared returns the variables declared in the script, sorted by name.
+func (v *Vars) Declared() []*Variable {
+	names := make([]string, 0, len(v.declared))
+	for name := range v.declared {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	output := make([]*Variable, len(names))
+	for i, name := range names {
+		output[i] = v.declared[name]
+	}
+
+	return output
+}
+
+// Attr honors the starlark.HasAttrs interface.
+func (v *Vars) Attr(name string) (starlark.Value, error) {
+	if name == "declare" {
+		return starlark.NewBuiltin("declare", v.declare), nil
+	}
+
+	if value, ok := v.values[name]; ok {
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("variable %q is not defined, use --var %s=<value>", name, name)
+}
+
+// AttrNames honors the starlark.HasAttrs interface.
+func (v *Vars) AttrNames() []string {
+	names := []string{"declare"}
+	for name := range v.values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names[1:])
+	return names
+}
+
+func (v *Vars) declare(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	variable := &Variable{Default: starlark.None}
+	err := starlark.UnpackArgs("declare", args, kwargs,
+		"name", &variable.Name,
+		"type?", &variable.Type,
+		"default?", &variable.Default,
+		"description?", &variable.Description,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if variable.Type == "" && variable.Default != starlark.None {
+		variable.Type = variable.Default.Type()
+	}
+
+	if err := validateVariableType(variable.Type); err != nil {
+		return nil, fmt.Errorf("declare: variable %q: %s", variable.Name, err)
+	}
+
+	value, err := v.resolve(variable)
+	if err != nil {
+		return nil, fmt.Errorf("declare: %s", err)
+	}
+
+	v.declared[variable.Name] = variable
+	v.Set(variable.Name, value)
+	return value, nil
+}
+
+func (v *Vars) resolve(variable *Variable) (starlark.Value, error) {
+	value, ok := v.values[variable.Name]
+	if !ok {
+		if variable.Default == starlark.None {
+			return nil, fmt.Errorf("variable %q is required, use --var %s=<value>", variable.Name, variable.Name)
+		}
+
+		value = variable.Default
+	} else if v.raw[variable.Name] {
+		var err error
+		value, err = parseVariable(variable.Type, string(value.(starlark.String)))
+		if err != nil {
+			return nil, fmt.Errorf("variable %q: %s", variable.Name, err)
+		}
+	}
+
+	value, err := convertVariable(variable.Type, value)
+	if err != nil {
+		return nil, fmt.Errorf("variable %q: %s", variable.Name, err)
+	}
+
+	return value, nil
+}
+
+func validateVariableType(typ string) error {
+	switch typ {
+	case "", "string", "int", "float", "bool", "list", "dict":
+		return nil
+	}
+
+	return fmt.Errorf("unexpected type %q", typ)
+}
+
+func parseVariable(typ, raw string) (starlark.Value, error) {
+	switch typ {
+	case "int":
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int %q", raw)
+		}
+
+		return starlark.MakeInt64(i), nil
+	case "float":
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", raw)
+		}
+
+		return starlark.Float(f), nil
+	case "bool":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool %q", raw)
+		}
+
+		return starlark.Bool(b), nil
+	case "list", "dict":
+		v, err := decodeJSON([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %s", typ, raw, err)
+		}
+
+		return v, nil
+	}
+
+	return starlark.String(raw), nil
+}
+
+func convertVariable(typ string, v starlark.Value) (starlark.Value, error) {
+	if typ == "" || v.Type() == typ {
+		return v, nil
+	}
+
+	if i, ok := v.(starlark.Int); ok && typ == "float" {
+		return i.Float(), nil
+	}
+
+	return nil, fmt.Errorf("expected %s, got %s", typ, v.Type())
+}
+
+// String honors the starlark.Value interface.
+func (v *Vars) String() string {
+	return "Vars"
+}
+
+// Type honors the starlark.Value interface.
+func (v *Vars) Type() string {
+	return "Vars"
+}
+
+// Freeze honors the starlark.Value interface.
+func (v *Vars) Freeze() {}
+
+// Truth honors the starlark.Value interface.
+func (v *Vars) Truth() starlark.Bool {
+	return len(v.values) != 0
+}
+
+// Hash honors the starlark.Value interface.
+func (v *Vars) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: Vars")
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+)
+
+func TestVars(t *testing.T) {
+	doTest(t, "testdata/vars.star")
+}
+
+func TestVarsLoadFile(t *testing.T) {
+	for _, filename := range []string{
+		"fixtures/vars/vars.json",
+		"fixtures/vars/vars.yaml",
+		"fixtures/vars/vars.star",
+	} {
+		vars := NewVars()
+		assert.NoError(t, vars.LoadFile(filename), filename)
+		assert.Equal(t, []string{
+			"declare", "ratio", "region", "replicas", "tags", "zones",
+		}, vars.AttrNames(), filename)
+
+		assert.Equal(t, starlark.String("eu-west-1"), vars.values["region"], filename)
+		assert.Equal(t, starlark.MakeInt(3), vars.values["replicas"], filename)
+		assert.Equal(t, starlark.Float(0.5), vars.values["ratio"], filename)
+		assert.Equal(t, `["a", "b"]`, vars.values["zones"].String(), filename)
+		assert.Equal(t, `{"env": "prod"}`, vars.values["tags"].String(), filename)
+	}
+}
+
+func TestVarsLoadFileError(t *testing.T) {
+	vars := NewVars()
+	err := vars.LoadFile("fixtures/vars/vars.tf")
+	assert.EqualError(t, err, "fixtures/vars/vars.tf: unsupported file format, expected .json, .yaml or .star")
+}
+
+func TestVarsSetFlag(t *testing.T) {
+	vars := NewVars()
+	assert.NoError(t, vars.SetFlag("foo=bar=qux"))
+	assert.Equal(t, starlark.String("bar=qux"), vars.values["foo"])
+	assert.True(t, vars.raw["foo"])
+
+	assert.EqualError(t, vars.SetFlag("foo"), `invalid variable "foo", expected key=value`)
+	assert.EqualError(t, vars.SetFlag("=foo"), `invalid variable "=foo", expected key=value`)
+}
+
+func newTestVars() *Vars {
+	vars := NewVars()
+	vars.SetRaw("region", "eu-west-1")
+	vars.SetRaw("replicas", "3")
+	vars.SetRaw("ratio", "0.5")
+	vars.SetRaw("enabled", "true")
+	vars.SetRaw("zones", `["a", "b"]`)
+	vars.SetRaw("tags", `{"env": "prod"}`)
+	vars.SetRaw("invalid_int", "foo")
+	vars.Set("count", starlark.MakeInt(2))
+	vars.Set("size", starlark.MakeInt(2))
+	return vars
+}
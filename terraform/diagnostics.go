@@ -0,0 +1,82 @@
+package terraform
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Diagnostic is an error or warning reported by Terraform, as encoded by the
+// `-json` flag of its commands.
+type Diagnostic struct {
+	Severity string           `json:"severity"`
+	Summary  string           `json:"summary"`
+	Detail   string           `json:"detail"`
+	Range    *DiagnosticRange `json:"range,omitempty"`
+}
+
+// DiagnosticRange is the range of the HCL file where a Diagnostic happened.
+type DiagnosticRange struct {
+	Filename string        `json:"filename"`
+	Start    DiagnosticPos `json:"start"`
+	End      DiagnosticPos `json:"end"`
+}
+
+// DiagnosticPos is a position in a HCL file.
+type DiagnosticPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// String honors the fmt.Stringer interface.
+func (r *DiagnosticRange) String() string {
+	return fmt.Sprintf("%s:%d:%d", r.Filename, r.Start.Line, r.Start.Column)
+}
+
+// ReadDiagnostics reads the diagnostics from the output of a Terraform
+// command with the `-json` flag. Both, the single document written by
+// `terraform validate -json` and the stream of JSON messages, one per line,
+// written by other commands, are supported.
+func ReadDiagnostics(r io.Reader) ([]*Diagnostic, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Diagnostics []*Diagnostic `json:"diagnostics"`
+	}
+
+	if err := json.Unmarshal(content, &doc); err == nil && doc.Diagnostics != nil {
+		return doc.Diagnostics, nil
+	}
+
+	var diags []*Diagnostic
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for i := 1; scanner.Scan(); i++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg struct {
+			Type       string      `json:"type"`
+			Diagnostic *Diagnostic `json:"diagnostic"`
+		}
+
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, fmt.Errorf("invalid diagnostics at line %d: %s", i, err)
+		}
+
+		if msg.Type == "diagnostic" && msg.Diagnostic != nil {
+			diags = append(diags, msg.Diagnostic)
+		}
+	}
+
+	return diags, scanner.Err()
+}
@@ -0,0 +1,41 @@
+package terraform
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadDiagnostics(t *testing.T) {
+	f, err := os.Open("testdata/validate.json")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	diags, err := ReadDiagnostics(f)
+	assert.NoError(t, err)
+	assert.Len(t, diags, 2)
+	assert.Equal(t, "error", diags[0].Severity)
+	assert.Equal(t, "Unsupported argument", diags[0].Summary)
+	assert.Equal(t, "main.tf:12:3", diags[0].Range.String())
+	assert.Equal(t, "warning", diags[1].Severity)
+	assert.Nil(t, diags[1].Range)
+}
+
+func TestReadDiagnosticsStream(t *testing.T) {
+	f, err := os.Open("testdata/plan.jsonl")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	diags, err := ReadDiagnostics(f)
+	assert.NoError(t, err)
+	assert.Len(t, diags, 1)
+	assert.Equal(t, "Invalid reference", diags[0].Summary)
+	assert.Equal(t, "google.tf:8:12", diags[0].Range.String())
+}
+
+func TestReadDiagnosticsError(t *testing.T) {
+	_, err := ReadDiagnostics(strings.NewReader("{}\nfoo\n"))
+	assert.EqualError(t, err, "invalid diagnostics at line 2: invalid character 'o' in literal false (expecting 'a')")
+}